@@ -0,0 +1,278 @@
+package accounts
+
+import (
+	"TechBlog/config"
+	"TechBlog/media"
+	"TechBlog/models"
+	"TechBlog/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidTransferTarget is returned when posts cannot be transferred to the chosen user
+	ErrInvalidTransferTarget = errors.New("posts cannot be transferred to this user")
+	// ErrPlaceholderAccount is returned for attempts to delete the placeholder
+	// account, which would take all anonymized content with it
+	ErrPlaceholderAccount = errors.New("the placeholder account cannot be deleted")
+	// ErrNotScheduled is returned by Execute when the request is no longer
+	// scheduled, because it was cancelled or carried out in the meantime
+	ErrNotScheduled = errors.New("the account deletion is no longer scheduled")
+)
+
+// ResolveTransferTarget finds the user that posts are transferred to
+func ResolveTransferTarget(db *gorm.DB, username string, deletingID uint) (*models.User, error) {
+	var target models.User
+	if err := db.Where("username = ?", username).First(&target).Error; err != nil {
+		return nil, ErrInvalidTransferTarget
+	}
+	if target.ID == deletingID || target.Placeholder {
+		return nil, ErrInvalidTransferTarget
+	}
+	return &target, nil
+}
+
+// PendingRequest returns the user's open deletion request, if any
+func PendingRequest(db *gorm.DB, userID uint) (*models.AccountDeletion, error) {
+	var request models.AccountDeletion
+	err := db.Where("user_id = ? AND status IN ?", userID,
+		[]string{models.DeletionPendingConfirmation, models.DeletionScheduled}).
+		Order("created_at DESC").
+		First(&request).Error
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// Schedule confirms a deletion request and starts its cooling-off period
+func Schedule(db *gorm.DB, request *models.AccountDeletion, coolingOff time.Duration) error {
+	now := time.Now()
+	executeAfter := now.Add(coolingOff)
+	request.Status = models.DeletionScheduled
+	request.ConfirmationHash = ""
+	request.ConfirmedAt = &now
+	request.ExecuteAfter = &executeAfter
+	return db.Save(request).Error
+}
+
+// Execute carries out a scheduled deletion in a single transaction. Posts
+// are moved to the transfer target or the placeholder user, or removed
+// together with everything else for a purge. The transaction first claims
+// the request, so that of several workers running it at once, only one
+// carries it out and the others get ErrNotScheduled.
+func Execute(db *gorm.DB, cfg *config.Config, request *models.AccountDeletion) error {
+	var avatar string
+	var mediaKeys []string
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		claim := tx.Model(&models.AccountDeletion{}).
+			Where("id = ? AND status = ?", request.ID, models.DeletionScheduled).
+			Update("status", models.DeletionProcessing)
+		if claim.Error != nil {
+			return claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			return ErrNotScheduled
+		}
+
+		var user models.User
+		if err := tx.Unscoped().First(&user, request.UserID).Error; err != nil {
+			return fmt.Errorf("failed to load user: %w", err)
+		}
+		if user.Placeholder {
+			return ErrPlaceholderAccount
+		}
+		avatar = user.Avatar
+
+		switch request.Mode {
+		case models.DeletionModeTransfer:
+			if request.TransferToID == nil {
+				return ErrInvalidTransferTarget
+			}
+			var target models.User
+			if err := tx.First(&target, *request.TransferToID).Error; err != nil {
+				return ErrInvalidTransferTarget
+			}
+			placeholder, err := deletedUser(tx)
+			if err != nil {
+				return err
+			}
+			if err := reassign(tx, &models.Post{}, user.ID, target.ID); err != nil {
+				return err
+			}
+			if err := reassign(tx, &models.Series{}, user.ID, target.ID); err != nil {
+				return err
+			}
+			// The transferred posts may embed the user's files
+			if err := reassign(tx, &models.Media{}, user.ID, target.ID); err != nil {
+				return err
+			}
+			if err := reassign(tx, &models.Comment{}, user.ID, placeholder.ID); err != nil {
+				return err
+			}
+		case models.DeletionModeAnonymize:
+			placeholder, err := deletedUser(tx)
+			if err != nil {
+				return err
+			}
+			if err := reassign(tx, &models.Post{}, user.ID, placeholder.ID); err != nil {
+				return err
+			}
+			if err := reassign(tx, &models.Series{}, user.ID, placeholder.ID); err != nil {
+				return err
+			}
+			if err := reassign(tx, &models.Comment{}, user.ID, placeholder.ID); err != nil {
+				return err
+			}
+			if err := reassign(tx, &models.Media{}, user.ID, placeholder.ID); err != nil {
+				return err
+			}
+		case models.DeletionModePurge:
+			userSeries := tx.Model(&models.Series{}).Select("id").Where("user_id = ?", user.ID)
+			if err := tx.Where("series_id IN (?)", userSeries).Delete(&models.SeriesPost{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("user_id = ?", user.ID).Delete(&models.Series{}).Error; err != nil {
+				return err
+			}
+			userPosts := tx.Unscoped().Model(&models.Post{}).Select("id").Where("user_id = ?", user.ID)
+			if err := tx.Unscoped().Where("user_id = ? OR post_id IN (?)", user.ID, userPosts).Delete(&models.Comment{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.Post{}).Error; err != nil {
+				return err
+			}
+			userMedia := tx.Unscoped().Model(&models.Media{}).Select("id").Where("user_id = ?", user.ID)
+			var variantKeys []string
+			if err := tx.Model(&models.MediaVariant{}).Where("media_id IN (?)", userMedia).Pluck("key", &variantKeys).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(&models.Media{}).Where("user_id = ?", user.ID).Pluck("key", &mediaKeys).Error; err != nil {
+				return err
+			}
+			mediaKeys = append(variantKeys, mediaKeys...)
+			if err := tx.Where("media_id IN (?)", userMedia).Delete(&models.MediaVariant{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.Media{}).Error; err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown deletion mode %q", request.Mode)
+		}
+
+		if err := tx.Unscoped().Delete(&user).Error; err != nil {
+			return fmt.Errorf("failed to delete user: %w", err)
+		}
+
+		now := time.Now()
+		request.Status = models.DeletionCompleted
+		request.CompletedAt = &now
+		request.Error = ""
+		return tx.Save(request).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	utils.RemoveAvatar(cfg.Storage.UploadDir, avatar)
+	removeMedia(cfg, mediaKeys)
+	return nil
+}
+
+// removeMedia deletes the stored files and image variants of a purged account. The records are
+// already gone, so failures are only logged.
+func removeMedia(cfg *config.Config, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	storage, err := media.FromConfig(cfg.Media, cfg.Storage.UploadDir)
+	if err != nil {
+		slog.Warn("failed to open the media storage to remove purged files", "error", err)
+		return
+	}
+	for _, key := range keys {
+		if err := storage.Delete(context.Background(), key); err != nil {
+			slog.Warn("failed to remove a purged media file", "key", key, "error", err)
+		}
+	}
+}
+
+// RunDue executes every scheduled deletion whose cooling-off period is over.
+// Deletions that another worker carries out first or that are cancelled in
+// the meantime are skipped.
+func RunDue(db *gorm.DB, cfg *config.Config) (int, error) {
+	var due []models.AccountDeletion
+	if err := db.Where("status = ? AND execute_after <= ?", models.DeletionScheduled, time.Now()).Find(&due).Error; err != nil {
+		return 0, err
+	}
+
+	executed := 0
+	for i := range due {
+		err := Execute(db, cfg, &due[i])
+		if errors.Is(err, ErrNotScheduled) {
+			continue
+		}
+		if err != nil {
+			db.Model(&models.AccountDeletion{}).
+				Where("id = ? AND status = ?", due[i].ID, models.DeletionScheduled).
+				Updates(map[string]interface{}{
+					"status": models.DeletionFailed,
+					"error":  err.Error(),
+				})
+			continue
+		}
+		executed++
+	}
+	return executed, nil
+}
+
+// deletedUser returns the placeholder account, creating it on first use. It
+// is found by its flag rather than its username, so that an account that
+// took the username before it was reserved never receives anonymized content.
+func deletedUser(tx *gorm.DB) (*models.User, error) {
+	var user models.User
+	err := tx.Where("placeholder = ?", true).First(&user).Error
+	if err == nil {
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to load placeholder user: %w", err)
+	}
+
+	password, err := utils.NewToken()
+	if err != nil {
+		return nil, err
+	}
+	username := models.DeletedUsername
+	var taken int64
+	if err := tx.Unscoped().Model(&models.User{}).Where("username = ?", username).Count(&taken).Error; err != nil {
+		return nil, fmt.Errorf("failed to load placeholder user: %w", err)
+	}
+	if taken > 0 {
+		username += "-" + password[:8]
+	}
+
+	user = models.User{
+		Username:    username,
+		Email:       username + "@users.invalid",
+		DisplayName: "Deleted user",
+		Placeholder: true,
+	}
+	user.SetPassword(password)
+	if err := tx.Create(&user).Error; err != nil {
+		return nil, fmt.Errorf("failed to create placeholder user: %w", err)
+	}
+	return &user, nil
+}
+
+// reassign moves every row of model owned by one user to another, including trashed rows
+func reassign(tx *gorm.DB, model interface{}, fromID, toID uint) error {
+	return tx.Unscoped().Model(model).Where("user_id = ?", fromID).Update("user_id", toID).Error
+}
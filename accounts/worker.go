@@ -0,0 +1,41 @@
+package accounts
+
+import (
+	"TechBlog/config"
+	"context"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// deletionInterval is how often the worker looks for deletions that are due
+const deletionInterval = 10 * time.Minute
+
+// StartDeletionWorker carries out scheduled account deletions once their
+// cooling-off period is over. It runs until ctx is cancelled and closes the
+// returned channel once the current run has finished.
+func StartDeletionWorker(ctx context.Context, db *gorm.DB, cfg *config.Config) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(deletionInterval)
+		defer ticker.Stop()
+
+		for {
+			executed, err := RunDue(db, cfg)
+			if err != nil {
+				slog.Error("account deletion run failed", "error", err)
+			} else if executed > 0 {
+				slog.Info("account deletion run finished", "removed", executed)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
@@ -0,0 +1,1439 @@
+package main
+
+import (
+	"TechBlog/accounts"
+	"TechBlog/config"
+	"TechBlog/connect"
+	"TechBlog/exports"
+	"TechBlog/mailer"
+	"TechBlog/migrations"
+	"TechBlog/models"
+	"TechBlog/trash"
+	"TechBlog/utils"
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// The tests in this file run the whole API, middleware included, against a
+// migrated in-memory SQLite database. Every test gets a database of its own.
+
+// testApp is a running API server with direct access to its database
+type testApp struct {
+	t      *testing.T
+	server *httptest.Server
+	router *gin.Engine
+	cfg    *config.Config
+	db     *connect.DBConfig
+	mail   *recordingMailer
+}
+
+// newTestApp migrates a fresh in-memory database and serves the API on it.
+// configure can adjust the configuration before the server is built.
+func newTestApp(t *testing.T, configure ...func(*config.Config)) *testApp {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cfg := config.Default()
+	cfg.Database.Driver = config.DriverSQLite
+	cfg.Database.DSN = ":memory:"
+	cfg.Session.Secret = "test-session-secret"
+	cfg.Server.FrontendDir = t.TempDir()
+	cfg.Storage.UploadDir = t.TempDir()
+	cfg.Storage.ExportDir = t.TempDir()
+	for _, fn := range configure {
+		fn(cfg)
+	}
+
+	dbConfig, err := connect.DBConnect(cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbConfig.Close() })
+
+	sqlDB, err := dbConfig.DB.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := migrations.New(sqlDB, dbConfig.Driver())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mail := &recordingMailer{}
+	previous := mailer.Default
+	mailer.Default = mail
+	t.Cleanup(func() { mailer.Default = previous })
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	router, err := newRouter(cfg, dbConfig, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return &testApp{t: t, server: server, router: router, cfg: cfg, db: dbConfig, mail: mail}
+}
+
+// recordingMailer keeps every message instead of sending it
+type recordingMailer struct {
+	mu     sync.Mutex
+	bodies []string
+}
+
+func (m *recordingMailer) Send(_ context.Context, _, _, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bodies = append(m.bodies, body)
+	return nil
+}
+
+var tokenPattern = regexp.MustCompile(`token=([0-9a-f]+)`)
+
+// lastToken returns the token of the link in the most recent message
+func (m *recordingMailer) lastToken(t *testing.T) string {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.bodies) == 0 {
+		t.Fatal("no mail was sent")
+	}
+	match := tokenPattern.FindStringSubmatch(m.bodies[len(m.bodies)-1])
+	if match == nil {
+		t.Fatalf("no token in mail: %s", m.bodies[len(m.bodies)-1])
+	}
+	return match[1]
+}
+
+// testClient is a browser-like client with its own session cookie. Like the
+// frontend, it fetches a CSRF token before its first state-changing request.
+type testClient struct {
+	app       *testApp
+	http      *http.Client
+	csrfToken string
+}
+
+func (app *testApp) client() *testClient {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		app.t.Fatal(err)
+	}
+	return &testClient{app: app, http: &http.Client{Jar: jar}}
+}
+
+// response is a decoded API response
+type response struct {
+	Status int
+	Header http.Header
+	Body   map[string]interface{}
+	List   []interface{}
+	Raw    []byte
+}
+
+// do sends body as JSON with the CSRF token of the session and decodes the response
+func (c *testClient) do(method, path string, body interface{}) response {
+	c.app.t.Helper()
+
+	header := http.Header{}
+	if method != http.MethodGet && method != http.MethodHead {
+		if c.csrfToken == "" {
+			c.csrfToken = c.send("GET", "/api/v1/csrf", nil, nil).expect(c.app.t, http.StatusOK).Body["csrf_token"].(string)
+		}
+		header.Set(utils.CSRFHeader, c.csrfToken)
+	}
+	res := c.send(method, path, body, header)
+	if strings.HasSuffix(path, "/login") && res.Status == http.StatusOK {
+		// Logging in issues a new token
+		c.csrfToken = ""
+	}
+	return res
+}
+
+// send sends body as JSON with header and decodes the response
+func (c *testClient) send(method, path string, body interface{}, header http.Header) response {
+	c.app.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			c.app.t.Fatal(err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, c.app.server.URL+path, reader)
+	if err != nil {
+		c.app.t.Fatal(err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.roundTrip(req)
+}
+
+// upload sends content as the file field of a multipart form, with the CSRF
+// token of the session
+func (c *testClient) upload(path, filename string, content []byte) response {
+	c.app.t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		c.app.t.Fatal(err)
+	}
+	part.Write(content)
+	if err := form.Close(); err != nil {
+		c.app.t.Fatal(err)
+	}
+
+	if c.csrfToken == "" {
+		c.csrfToken = c.send("GET", "/api/v1/csrf", nil, nil).expect(c.app.t, http.StatusOK).Body["csrf_token"].(string)
+	}
+	req, err := http.NewRequest("POST", c.app.server.URL+path, &body)
+	if err != nil {
+		c.app.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set(utils.CSRFHeader, c.csrfToken)
+	return c.roundTrip(req)
+}
+
+// roundTrip sends req and decodes the response
+func (c *testClient) roundTrip(req *http.Request) response {
+	c.app.t.Helper()
+	method, path := req.Method, req.URL.Path
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		c.app.t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		c.app.t.Fatal(err)
+	}
+	result := response{Status: res.StatusCode, Header: res.Header, Raw: raw}
+	if !strings.Contains(res.Header.Get("Content-Type"), "json") {
+		return result
+	}
+	if len(raw) > 0 && raw[0] == '[' {
+		err = json.Unmarshal(raw, &result.List)
+	} else if len(raw) > 0 {
+		err = json.Unmarshal(raw, &result.Body)
+	}
+	if err != nil {
+		c.app.t.Fatalf("%s %s: invalid JSON response %q: %v", method, path, raw, err)
+	}
+	return result
+}
+
+// expect fails the test unless the response has the given status
+func (r response) expect(t *testing.T, status int) response {
+	t.Helper()
+	if r.Status != status {
+		t.Fatalf("expected status %d, got %d: %v%v", status, r.Status, r.Body, r.List)
+	}
+	return r
+}
+
+// expectProblem fails the test unless the response is a problem document with code
+func (r response) expectProblem(t *testing.T, status int, code string) response {
+	t.Helper()
+	r.expect(t, status)
+	if contentType := r.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "application/problem+json") {
+		t.Fatalf("expected a problem document, got Content-Type %q", contentType)
+	}
+	if r.Body["code"] != code {
+		t.Fatalf("expected code %q, got %v", code, r.Body["code"])
+	}
+	return r
+}
+
+// id returns the ID of the object stored under key in the response
+func (r response) id(t *testing.T, key string) uint {
+	t.Helper()
+	object, ok := r.Body[key].(map[string]interface{})
+	if !ok {
+		t.Fatalf("response has no %s: %v", key, r.Body)
+	}
+	return uint(object["ID"].(float64))
+}
+
+// signUp registers a user and logs the returned client in as that user
+func (app *testApp) signUp(username string) *testClient {
+	app.t.Helper()
+	c := app.client()
+	c.do("POST", "/api/v1/signup", map[string]string{
+		"username": username,
+		"email":    username + "@example.com",
+		"password": "password123",
+	}).expect(app.t, http.StatusCreated)
+	c.do("POST", "/api/v1/login", map[string]string{
+		"username": username,
+		"password": "password123",
+	}).expect(app.t, http.StatusOK)
+	return c
+}
+
+// makeAdmin gives the user with username administrator rights
+func (app *testApp) makeAdmin(username string) {
+	app.t.Helper()
+	if err := app.db.DB.Model(&models.User{}).Where("username = ?", username).Update("is_admin", true).Error; err != nil {
+		app.t.Fatal(err)
+	}
+}
+
+// deleteAccount requests the deletion of the client's account with body,
+// confirms it through the emailed link and carries it out once the
+// cooling-off period is over
+func (c *testClient) deleteAccount(body map[string]string) {
+	t := c.app.t
+	t.Helper()
+	body["password"] = "password123"
+	c.do("POST", "/api/v1/users/me/deletion", body).expect(t, http.StatusAccepted)
+	c.do("POST", "/api/v1/users/me/deletion/confirm", map[string]string{"token": c.app.mail.lastToken(t)}).
+		expect(t, http.StatusOK)
+
+	if executed, err := accounts.RunDue(c.app.db.DB, c.app.cfg); err != nil || executed != 0 {
+		t.Fatalf("expected the deletion to wait for the cooling-off period, executed %d: %v", executed, err)
+	}
+	if err := c.app.db.DB.Model(&models.AccountDeletion{}).Where("status = ?", models.DeletionScheduled).
+		Update("execute_after", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if executed, err := accounts.RunDue(c.app.db.DB, c.app.cfg); err != nil || executed != 1 {
+		t.Fatalf("expected the deletion to be carried out, executed %d: %v", executed, err)
+	}
+}
+
+func TestHealthProbes(t *testing.T) {
+	app := newTestApp(t)
+	c := app.client()
+
+	c.do("GET", "/healthz", nil).expect(t, http.StatusOK)
+	ready := c.do("GET", "/readyz", nil).expect(t, http.StatusOK)
+	if checks := ready.Body["checks"].(map[string]interface{}); checks["migrations"] != "ok" {
+		t.Fatalf("expected migrations to be ok, got %v", checks)
+	}
+
+	// The probe only reads the schema: a database that was never migrated
+	// is reported, not initialized
+	if err := app.db.DB.Exec("DROP TABLE schema_migrations").Error; err != nil {
+		t.Fatal(err)
+	}
+	unready := c.do("GET", "/readyz", nil).expect(t, http.StatusServiceUnavailable)
+	if checks := unready.Body["checks"].(map[string]interface{}); checks["migrations"] != "not initialized" {
+		t.Fatalf("expected migrations to be not initialized, got %v", checks)
+	}
+	if app.db.DB.Migrator().HasTable("schema_migrations") {
+		t.Fatal("expected the probe not to create the schema_migrations table")
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	app := newTestApp(t)
+	app.router.GET("/api/v1/panic", func(c *gin.Context) { panic("boom") })
+
+	res := app.client().do("GET", "/api/v1/panic", nil).expectProblem(t, http.StatusInternalServerError, "internal_error")
+	if id := res.Header.Get(utils.RequestIDHeader); id == "" || res.Body["request_id"] != id {
+		t.Fatalf("expected the problem to carry request ID %q, got %v", id, res.Body)
+	}
+	if strings.Contains(string(res.Raw), "boom") {
+		t.Errorf("expected the panic to stay out of the response, got %s", res.Raw)
+	}
+}
+
+func TestSignupAndLogin(t *testing.T) {
+	app := newTestApp(t)
+	c := app.client()
+
+	c.do("POST", "/api/v1/signup", map[string]string{"username": "alice", "email": "not-an-email"}).
+		expectProblem(t, http.StatusBadRequest, "validation_failed")
+
+	credentials := map[string]string{"username": "alice", "email": "alice@example.com", "password": "password123"}
+	c.do("POST", "/api/v1/signup", credentials).expect(t, http.StatusCreated)
+	c.do("POST", "/api/v1/signup", credentials).expectProblem(t, http.StatusConflict, "unique_violation")
+
+	c.do("GET", "/api/v1/users/me", nil).expectProblem(t, http.StatusUnauthorized, "unauthorized")
+	c.do("POST", "/api/v1/login", map[string]string{"username": "alice", "password": "wrong-password"}).
+		expectProblem(t, http.StatusUnauthorized, "invalid_credentials")
+	c.do("POST", "/api/v1/login", map[string]string{"username": "nobody", "password": "password123"}).
+		expectProblem(t, http.StatusNotFound, "user_not_found")
+
+	c.do("POST", "/api/v1/login", map[string]string{"username": "alice", "password": "password123"}).expect(t, http.StatusOK)
+	me := c.do("GET", "/api/v1/users/me", nil).expect(t, http.StatusOK)
+	if user := me.Body["user"].(map[string]interface{}); user["Username"] != "alice" {
+		t.Fatalf("expected alice, got %v", user["Username"])
+	}
+}
+
+func TestPostLifecycle(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.signUp("alice")
+	bob := app.signUp("bob")
+
+	alice.do("POST", "/api/v1/posts/", map[string]string{"title": "  ", "body": "Body"}).
+		expectProblem(t, http.StatusBadRequest, "validation_failed")
+	postID := alice.do("POST", "/api/v1/posts/", map[string]string{"title": "First post", "body": "Hello"}).
+		expect(t, http.StatusCreated).id(t, "post")
+	path := fmt.Sprintf("/api/v1/posts/%d", postID)
+
+	post := app.client().do("GET", path, nil).expect(t, http.StatusOK)
+	if post.Body["Title"] != "First post" {
+		t.Fatalf("unexpected post %v", post.Body)
+	}
+	app.client().do("GET", "/api/v1/posts/not-a-number", nil).expectProblem(t, http.StatusNotFound, "post_not_found")
+
+	bob.do("PUT", path, map[string]string{"title": "Taken over", "body": "Mine now"}).
+		expectProblem(t, http.StatusNotFound, "post_not_found")
+	bob.do("DELETE", path, nil).expectProblem(t, http.StatusNotFound, "post_not_found")
+
+	updated := alice.do("PUT", path, map[string]string{"title": "Edited", "body": "Hello again"}).expect(t, http.StatusOK)
+	if title := updated.Body["post"].(map[string]interface{})["Title"]; title != "Edited" {
+		t.Fatalf("expected the edited title, got %v", title)
+	}
+	if mine := alice.do("GET", "/api/v1/posts/myposts", nil).expect(t, http.StatusOK); len(mine.List) != 1 {
+		t.Fatalf("expected one post, got %d", len(mine.List))
+	}
+
+	alice.do("DELETE", path, nil).expect(t, http.StatusOK)
+	app.client().do("GET", path, nil).expectProblem(t, http.StatusNotFound, "post_not_found")
+
+	trash := alice.do("GET", "/api/v1/trash", nil).expect(t, http.StatusOK)
+	if posts := trash.Body["posts"].([]interface{}); len(posts) != 1 {
+		t.Fatalf("expected the post in the trash, got %v", trash.Body)
+	}
+	alice.do("POST", fmt.Sprintf("/api/v1/trash/posts/%d/restore", postID), nil).expect(t, http.StatusOK)
+	app.client().do("GET", path, nil).expect(t, http.StatusOK)
+}
+
+func TestComments(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.signUp("alice")
+	bob := app.signUp("bob")
+
+	postID := alice.do("POST", "/api/v1/posts/", map[string]string{"title": "Post", "body": "Body"}).
+		expect(t, http.StatusCreated).id(t, "post")
+
+	bob.do("POST", "/api/v1/comments/", map[string]interface{}{"body": "Hi", "post_id": 999}).
+		expectProblem(t, http.StatusNotFound, "post_not_found")
+	commentID := bob.do("POST", "/api/v1/comments/", map[string]interface{}{"body": "Nice post", "post_id": postID}).
+		expect(t, http.StatusCreated).id(t, "comment")
+	path := fmt.Sprintf("/api/v1/comments/%d", commentID)
+
+	alice.do("PUT", path, map[string]string{"body": "Edited by someone else"}).
+		expectProblem(t, http.StatusNotFound, "comment_not_found")
+	bob.do("PUT", path, map[string]string{"body": "Really nice post"}).expect(t, http.StatusOK)
+
+	post := app.client().do("GET", fmt.Sprintf("/api/v1/posts/%d", postID), nil).expect(t, http.StatusOK)
+	comments := post.Body["Comments"].([]interface{})
+	if len(comments) != 1 || comments[0].(map[string]interface{})["Body"] != "Really nice post" {
+		t.Fatalf("unexpected comments %v", comments)
+	}
+
+	bob.do("DELETE", path, nil).expect(t, http.StatusOK)
+	bob.do("DELETE", path, nil).expectProblem(t, http.StatusNotFound, "comment_not_found")
+}
+
+// commentBodies returns the bodies of the comments shown on a post, sorted
+func commentBodies(t *testing.T, app *testApp, postID uint) []string {
+	t.Helper()
+	post := app.client().do("GET", fmt.Sprintf("/api/v1/posts/%d", postID), nil).expect(t, http.StatusOK)
+	bodies := []string{}
+	for _, comment := range post.Body["Comments"].([]interface{}) {
+		bodies = append(bodies, comment.(map[string]interface{})["Body"].(string))
+	}
+	sort.Strings(bodies)
+	return bodies
+}
+
+func TestTrash(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.signUp("alice")
+	bob := app.signUp("bob")
+
+	post := func(c *testClient, title string) uint {
+		return c.do("POST", "/api/v1/posts/", map[string]string{"title": title, "body": "Body"}).
+			expect(t, http.StatusCreated).id(t, "post")
+	}
+	comment := func(c *testClient, postID uint, body string) uint {
+		return c.do("POST", "/api/v1/comments/", map[string]interface{}{"body": body, "post_id": postID}).
+			expect(t, http.StatusCreated).id(t, "comment")
+	}
+	expectComments := func(postID uint, want ...string) {
+		t.Helper()
+		if got := commentBodies(t, app, postID); !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected comments %q, got %q", want, got)
+		}
+	}
+
+	// Restoring a post restores the comments deleted with it, but not those
+	// deleted on their own before
+	alices := post(alice, "Alice's post")
+	early := comment(bob, alices, "Deleted on its own")
+	bob.do("DELETE", fmt.Sprintf("/api/v1/comments/%d", early), nil).expect(t, http.StatusOK)
+	cascaded := comment(bob, alices, "Deleted with the post")
+	alice.do("DELETE", fmt.Sprintf("/api/v1/posts/%d", alices), nil).expect(t, http.StatusOK)
+	// The batch, not the deletion time, ties them together, so a database
+	// that stores times at a lower precision cannot break them apart
+	if err := app.db.DB.Exec("UPDATE comments SET deleted_at = ? WHERE id = ?", time.Now().Add(time.Millisecond), cascaded).Error; err != nil {
+		t.Fatal(err)
+	}
+	alice.do("POST", fmt.Sprintf("/api/v1/trash/posts/%d/restore", alices), nil).expect(t, http.StatusOK)
+	expectComments(alices, "Deleted with the post")
+	bob.do("POST", fmt.Sprintf("/api/v1/trash/comments/%d/restore", early), nil).expect(t, http.StatusOK)
+	expectComments(alices, "Deleted on its own", "Deleted with the post")
+
+	// Purging removes what has been in the trash since before the cutoff and
+	// nothing else
+	bobs := post(bob, "Bob's post")
+	comment(alice, bobs, "On Bob's post")
+	alice.do("DELETE", fmt.Sprintf("/api/v1/posts/%d", alices), nil).expect(t, http.StatusOK)
+	if purged, err := trash.Purge(app.db.DB, time.Now().Add(-time.Minute)); err != nil || purged != 0 {
+		t.Fatalf("expected nothing to be purged yet, got %d, %v", purged, err)
+	}
+	// Alice's post with its two comments
+	if purged, err := trash.Purge(app.db.DB, time.Now().Add(time.Minute)); err != nil || purged != 3 {
+		t.Fatalf("expected 3 items to be purged, got %d, %v", purged, err)
+	}
+	var trashed int64
+	for _, model := range []interface{}{&models.Post{}, &models.Comment{}, &models.User{}} {
+		var count int64
+		app.db.DB.Unscoped().Model(model).Where("deleted_at IS NOT NULL").Count(&count)
+		trashed += count
+	}
+	if trashed != 0 {
+		t.Fatalf("expected the trash to be empty, %d items are left", trashed)
+	}
+	expectComments(bobs, "On Bob's post")
+}
+
+func TestAuthors(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.signUp("alice")
+	app.signUp("bob")
+
+	for i := 0; i < 3; i++ {
+		alice.do("POST", "/api/v1/posts/", map[string]string{"title": fmt.Sprintf("Post %d", i), "body": "Body"}).
+			expect(t, http.StatusCreated)
+	}
+
+	list := app.client().do("GET", "/api/v1/authors", nil).expect(t, http.StatusOK)
+	authors := list.Body["authors"].([]interface{})
+	if len(authors) != 1 {
+		t.Fatalf("expected only alice to be listed, got %v", authors)
+	}
+	if author := authors[0].(map[string]interface{}); author["PostCount"] != float64(3) || author["LastPostAt"] == nil {
+		t.Fatalf("expected 3 posts and the time of the last one, got %v", author)
+	}
+
+	author := app.client().do("GET", "/api/v1/authors/alice", nil).expect(t, http.StatusOK)
+	if posts := author.Body["posts"].([]interface{}); len(posts) != 3 {
+		t.Fatalf("expected 3 posts, got %d", len(posts))
+	}
+	app.client().do("GET", "/api/v1/authors/nobody", nil).expectProblem(t, http.StatusNotFound, "author_not_found")
+
+	// A page far beyond the last one is empty rather than an overflowing offset
+	far := app.client().do("GET", "/api/v1/authors?page=9223372036854775807&per_page=50", nil).expect(t, http.StatusOK)
+	if authors := far.Body["authors"].([]interface{}); len(authors) != 0 || far.Body["page"] != float64(10000) {
+		t.Fatalf("expected an empty page 10000, got %v", far.Body)
+	}
+}
+
+func TestPostSummaries(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.signUp("alice")
+	bob := app.signUp("bob")
+
+	cover := alice.upload("/api/v1/media", "cover.png", pngImage(t, 800, 400)).expect(t, http.StatusCreated).id(t, "media")
+	notes := alice.upload("/api/v1/media", "notes.pdf", []byte("%PDF-1.4\n%%EOF\n")).expect(t, http.StatusCreated).id(t, "media")
+	theirs := bob.upload("/api/v1/media", "theirs.png", pngImage(t, 40, 30)).expect(t, http.StatusCreated).id(t, "media")
+
+	body := "# Intro\n\n![Cover](/api/v1/media/1/file)\n\nThe **first** paragraph links to [the docs](https://go.dev).\n\n" +
+		strings.Repeat("word ", 400)
+	for _, id := range []uint{notes, theirs, 999} {
+		alice.do("POST", "/api/v1/posts/", map[string]interface{}{"title": "Post", "body": body, "cover_media_id": id}).
+			expectProblem(t, http.StatusBadRequest, "validation_failed")
+	}
+	alice.do("POST", "/api/v1/posts/", map[string]interface{}{"title": "Post", "body": body, "excerpt": strings.Repeat("x", 281)}).
+		expectProblem(t, http.StatusBadRequest, "validation_failed")
+
+	created := alice.do("POST", "/api/v1/posts/", map[string]interface{}{"title": "Post", "body": body, "cover_media_id": cover}).
+		expect(t, http.StatusCreated)
+	post := created.Body["post"].(map[string]interface{})
+	if post["Excerpt"] != "The first paragraph links to the docs." || post["WordCount"] != 408.0 || post["ReadingTimeMinutes"] != 3.0 {
+		t.Fatalf("unexpected summary %v", post)
+	}
+	path := fmt.Sprintf("/api/v1/posts/%d", created.id(t, "post"))
+
+	// Listings show the summary instead of the body
+	list := app.client().do("GET", "/api/v1/posts", nil).expect(t, http.StatusOK)
+	summary := list.List[0].(map[string]interface{})
+	if _, ok := summary["Body"]; ok {
+		t.Errorf("expected no body in %v", summary)
+	}
+	if summary["Excerpt"] != post["Excerpt"] || summary["User"].(map[string]interface{})["Username"] != "alice" {
+		t.Errorf("unexpected summary %v", summary)
+	}
+	if _, ok := summary["User"].(map[string]interface{})["Email"]; ok {
+		t.Errorf("expected the email address of the author to be hidden")
+	}
+	coverImage := summary["Cover"].(map[string]interface{})
+	if src := fmt.Sprintf("/api/v1/media/%d/file?w=960&fmt=webp", cover); coverImage["Src"] != src || len(coverImage["Srcset"].([]interface{})) != 3 {
+		t.Errorf("unexpected cover %v", coverImage)
+	}
+	author := app.client().do("GET", "/api/v1/authors/alice", nil).expect(t, http.StatusOK)
+	if posts := author.Body["posts"].([]interface{}); posts[0].(map[string]interface{})["Cover"] == nil {
+		t.Errorf("expected the cover on the author page, got %v", posts)
+	}
+
+	// An explicit excerpt stays until it is cleared; omitted fields are kept
+	updated := alice.do("PUT", path, map[string]interface{}{"title": "Post", "body": "Short.", "excerpt": " Read this "}).
+		expect(t, http.StatusOK).Body["post"].(map[string]interface{})
+	if updated["Excerpt"] != "Read this" || updated["WordCount"] != 1.0 || updated["ReadingTimeMinutes"] != 1.0 || updated["CoverMediaID"] != float64(cover) {
+		t.Fatalf("unexpected update %v", updated)
+	}
+	updated = alice.do("PUT", path, map[string]interface{}{"title": "Post", "body": "Shorter."}).
+		expect(t, http.StatusOK).Body["post"].(map[string]interface{})
+	if updated["Excerpt"] != "Read this" {
+		t.Fatalf("expected the explicit excerpt to be kept, got %v", updated["Excerpt"])
+	}
+	updated = alice.do("PUT", path, map[string]interface{}{"title": "Post", "body": "Shorter.", "excerpt": "", "cover_media_id": 0}).
+		expect(t, http.StatusOK).Body["post"].(map[string]interface{})
+	if updated["Excerpt"] != "Shorter." || updated["CoverMediaID"] != nil {
+		t.Fatalf("expected a generated excerpt and no cover, got %v", updated)
+	}
+
+	// Deleting an image takes it off the posts it covers
+	alice.do("PUT", path, map[string]interface{}{"title": "Post", "body": "Shorter.", "cover_media_id": cover}).expect(t, http.StatusOK)
+	alice.do("DELETE", fmt.Sprintf("/api/v1/media/%d", cover), nil).expect(t, http.StatusOK)
+	mine := alice.do("GET", "/api/v1/posts/myposts", nil).expect(t, http.StatusOK)
+	if summary := mine.List[0].(map[string]interface{}); summary["CoverMediaID"] != nil || summary["Cover"] != nil {
+		t.Fatalf("expected the cover to be removed, got %v", summary)
+	}
+}
+
+func TestSeries(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.signUp("alice")
+	bob := app.signUp("bob")
+
+	var parts []uint
+	for i := 1; i <= 3; i++ {
+		parts = append(parts, alice.do("POST", "/api/v1/posts/", map[string]string{"title": fmt.Sprintf("Part %d", i), "body": "Body"}).
+			expect(t, http.StatusCreated).id(t, "post"))
+	}
+	bobs := bob.do("POST", "/api/v1/posts/", map[string]string{"title": "Bob's post", "body": "Body"}).
+		expect(t, http.StatusCreated).id(t, "post")
+
+	for _, ids := range [][]uint{{parts[0], parts[0]}, {parts[0], bobs}, {999}} {
+		alice.do("POST", "/api/v1/series", map[string]interface{}{"title": "Tutorial", "post_ids": ids}).
+			expectProblem(t, http.StatusBadRequest, "validation_failed")
+	}
+	created := alice.do("POST", "/api/v1/series", map[string]interface{}{
+		"title": "Tutorial", "description": "Step by step", "post_ids": parts[:2],
+	}).expect(t, http.StatusCreated)
+	seriesID := created.id(t, "series")
+	path := fmt.Sprintf("/api/v1/series/%d", seriesID)
+
+	// A post belongs to at most one series
+	alice.do("POST", "/api/v1/series", map[string]interface{}{"title": "Another", "post_ids": parts[1:2]}).
+		expectProblem(t, http.StatusBadRequest, "validation_failed")
+	bob.do("PUT", path+"/posts", map[string]interface{}{"post_ids": []uint{bobs}}).expectProblem(t, http.StatusNotFound, "series_not_found")
+
+	// Reordering also adds and removes posts
+	alice.do("PUT", path+"/posts", map[string]interface{}{"post_ids": []uint{parts[2], parts[0], parts[1]}}).expect(t, http.StatusOK)
+	alice.do("PUT", path, map[string]string{"title": "Go tutorial"}).expect(t, http.StatusOK)
+
+	toc := app.client().do("GET", path, nil).expect(t, http.StatusOK)
+	var titles []string
+	for _, entry := range toc.Body["Posts"].([]interface{}) {
+		titles = append(titles, entry.(map[string]interface{})["Title"].(string))
+	}
+	if toc.Body["Title"] != "Go tutorial" || strings.Join(titles, ", ") != "Part 3, Part 1, Part 2" {
+		t.Fatalf("unexpected series %v", toc.Body)
+	}
+
+	// Posts link to the parts before and after them
+	post := app.client().do("GET", fmt.Sprintf("/api/v1/posts/%d", parts[0]), nil).expect(t, http.StatusOK)
+	nav := post.Body["Series"].(map[string]interface{})
+	previous, next := nav["Previous"].(map[string]interface{}), nav["Next"].(map[string]interface{})
+	if nav["Position"] != 2.0 || previous["ID"] != float64(parts[2]) || next["ID"] != float64(parts[1]) || len(nav["Posts"].([]interface{})) != 3 {
+		t.Fatalf("unexpected navigation %v", nav)
+	}
+	if other := app.client().do("GET", fmt.Sprintf("/api/v1/posts/%d", bobs), nil).expect(t, http.StatusOK); other.Body["Series"] != nil {
+		t.Fatalf("expected no series, got %v", other.Body["Series"])
+	}
+
+	// Posts in the trash drop out of the series until they are restored
+	alice.do("DELETE", fmt.Sprintf("/api/v1/posts/%d", parts[2]), nil).expect(t, http.StatusOK)
+	nav = app.client().do("GET", fmt.Sprintf("/api/v1/posts/%d", parts[0]), nil).expect(t, http.StatusOK).Body["Series"].(map[string]interface{})
+	if nav["Position"] != 1.0 || nav["Previous"] != nil {
+		t.Fatalf("expected the post to come first, got %v", nav)
+	}
+	alice.do("POST", fmt.Sprintf("/api/v1/trash/posts/%d/restore", parts[2]), nil).expect(t, http.StatusOK)
+
+	alice.do("DELETE", path, nil).expect(t, http.StatusOK)
+	app.client().do("GET", path, nil).expectProblem(t, http.StatusNotFound, "series_not_found")
+	app.client().do("GET", fmt.Sprintf("/api/v1/posts/%d", parts[0]), nil).expect(t, http.StatusOK)
+}
+
+func TestEmailChange(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.signUp("alice")
+
+	alice.do("PUT", "/api/v1/users/me", map[string]string{"display_name": "Alice", "email": "new@example.com"}).
+		expect(t, http.StatusOK)
+	token := app.mail.lastToken(t)
+
+	alice.do("POST", "/api/v1/users/verify-email", map[string]string{"token": "wrong"}).
+		expectProblem(t, http.StatusNotFound, "invalid_token")
+	alice.do("POST", "/api/v1/users/verify-email", map[string]string{"token": token}).expect(t, http.StatusOK)
+
+	me := alice.do("GET", "/api/v1/users/me", nil).expect(t, http.StatusOK)
+	user := me.Body["user"].(map[string]interface{})
+	if user["Email"] != "new@example.com" || user["DisplayName"] != "Alice" || user["EmailVerified"] != true {
+		t.Fatalf("profile was not updated: %v", user)
+	}
+}
+
+func TestPasswordChange(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.signUp("alice")
+	laptop := app.client()
+	laptop.do("POST", "/api/v1/login", map[string]string{"username": "alice", "password": "password123"}).expect(t, http.StatusOK)
+
+	// A password that looks like a bcrypt hash is hashed like any other
+	var hash [60]byte
+	copy(hash[:], "$2a$10$")
+	for i := 7; i < len(hash); i++ {
+		hash[i] = 'a'
+	}
+	alice.do("PUT", "/api/v1/users/me/password", map[string]string{"current_password": "password123", "new_password": string(hash[:])}).
+		expect(t, http.StatusOK)
+	var user models.User
+	if err := app.db.DB.Where("username = ?", "alice").First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.Password == string(hash[:]) || bcrypt.CompareHashAndPassword([]byte(user.Password), hash[:]) != nil {
+		t.Fatalf("expected the new password to be hashed, stored %q", user.Password)
+	}
+
+	// The session that changed the password stays signed in, the others do not
+	alice.do("GET", "/api/v1/users/me", nil).expect(t, http.StatusOK)
+	laptop.do("GET", "/api/v1/users/me", nil).expectProblem(t, http.StatusUnauthorized, "unauthorized")
+	laptop.do("POST", "/api/v1/login", map[string]string{"username": "alice", "password": string(hash[:])}).expect(t, http.StatusOK)
+	laptop.do("GET", "/api/v1/users/me", nil).expect(t, http.StatusOK)
+}
+
+func TestAdminRoutes(t *testing.T) {
+	app := newTestApp(t)
+	admin := app.signUp("admin")
+
+	admin.do("GET", "/api/v1/admin/trash", nil).expectProblem(t, http.StatusForbidden, "forbidden")
+	app.client().do("GET", "/api/v1/admin/trash", nil).expectProblem(t, http.StatusUnauthorized, "unauthorized")
+
+	app.makeAdmin("admin")
+	admin.do("GET", "/api/v1/admin/trash", nil).expect(t, http.StatusOK)
+	admin.do("GET", "/api/v1/admin/deletions", nil).expect(t, http.StatusOK)
+}
+
+func TestAccountDeletion(t *testing.T) {
+	// owners maps the title of every post, the body of every comment and the
+	// name of every series and file that is left to the username of its owner
+	type owners map[string]string
+
+	for _, test := range []struct {
+		mode string
+		want owners
+	}{
+		{"transfer", owners{
+			"Published": "carol", "Trashed": "carol", "Bob's post": "bob",
+			"On my post": "deleted-user", "On bob's post": "deleted-user", "Bob on alice": "bob",
+			"Tutorial": "carol", "photo.png": "carol",
+		}},
+		{"anonymize", owners{
+			"Published": "deleted-user", "Trashed": "deleted-user", "Bob's post": "bob",
+			"On my post": "deleted-user", "On bob's post": "deleted-user", "Bob on alice": "bob",
+			"Tutorial": "deleted-user", "photo.png": "deleted-user",
+		}},
+		{"purge", owners{"Bob's post": "bob"}},
+	} {
+		t.Run(test.mode, func(t *testing.T) {
+			app := newTestApp(t)
+			alice := app.signUp("alice")
+			bob := app.signUp("bob")
+			app.signUp("carol")
+
+			post := func(c *testClient, title string) uint {
+				return c.do("POST", "/api/v1/posts/", map[string]string{"title": title, "body": "Body"}).
+					expect(t, http.StatusCreated).id(t, "post")
+			}
+			comment := func(c *testClient, body string, postID uint) {
+				c.do("POST", "/api/v1/comments/", map[string]interface{}{"body": body, "post_id": postID}).
+					expect(t, http.StatusCreated)
+			}
+			published, trashed, bobs := post(alice, "Published"), post(alice, "Trashed"), post(bob, "Bob's post")
+			comment(alice, "On my post", published)
+			comment(alice, "On bob's post", bobs)
+			comment(bob, "Bob on alice", published)
+			alice.do("POST", "/api/v1/series", map[string]interface{}{"title": "Tutorial", "post_ids": []uint{published, trashed}}).
+				expect(t, http.StatusCreated)
+			alice.upload("/api/v1/media", "photo.png", pngImage(t, 40, 30)).expect(t, http.StatusCreated)
+			alice.do("DELETE", fmt.Sprintf("/api/v1/posts/%d", trashed), nil).expect(t, http.StatusOK)
+
+			var file models.Media
+			if err := app.db.DB.First(&file).Error; err != nil {
+				t.Fatal(err)
+			}
+			stored := filepath.Join(app.cfg.Storage.UploadDir, filepath.FromSlash(file.Key))
+			if _, err := os.Stat(stored); err != nil {
+				t.Fatal(err)
+			}
+
+			body := map[string]string{"mode": test.mode}
+			if test.mode == "transfer" {
+				body["transfer_to"] = "carol"
+			}
+			alice.deleteAccount(body)
+
+			usernames := map[uint]string{}
+			var users []models.User
+			app.db.DB.Unscoped().Find(&users)
+			for _, user := range users {
+				if user.Username == "alice" {
+					t.Fatal("expected alice to be deleted")
+				}
+				usernames[user.ID] = user.Username
+				if user.Placeholder {
+					usernames[user.ID] = models.DeletedUsername
+				}
+			}
+
+			got := owners{}
+			var posts []models.Post
+			app.db.DB.Unscoped().Find(&posts)
+			for _, p := range posts {
+				got[p.Title] = usernames[p.UserID]
+			}
+			var comments []models.Comment
+			app.db.DB.Unscoped().Find(&comments)
+			for _, c := range comments {
+				got[c.Body] = usernames[c.UserID]
+			}
+			var series []models.Series
+			app.db.DB.Find(&series)
+			for _, s := range series {
+				got[s.Title] = usernames[s.UserID]
+			}
+			var files []models.Media
+			app.db.DB.Unscoped().Find(&files)
+			for _, f := range files {
+				got[f.Filename] = usernames[f.UserID]
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("owners after deletion = %v, want %v", got, test.want)
+			}
+
+			var members int64
+			app.db.DB.Model(&models.SeriesPost{}).Count(&members)
+			if _, err := os.Stat(stored); test.mode == "purge" {
+				if members != 0 || !os.IsNotExist(err) {
+					t.Errorf("expected the series posts and the stored file to be removed, %d posts left, stat: %v", members, err)
+				}
+			} else if members != 2 || err != nil {
+				t.Errorf("expected the series posts and the stored file to be kept, %d posts left, stat: %v", members, err)
+			}
+
+			var deletion models.AccountDeletion
+			if err := app.db.DB.First(&deletion).Error; err != nil || deletion.Status != models.DeletionCompleted {
+				t.Errorf("expected the deletion to be completed: %+v %v", deletion, err)
+			}
+		})
+	}
+}
+
+func TestAccountDeletionRunsOnce(t *testing.T) {
+	app := newTestApp(t)
+	db := app.db.DB
+
+	// scheduled confirms a deletion of c's account that is due and returns
+	// it as a worker would load it
+	scheduled := func(c *testClient) models.AccountDeletion {
+		t.Helper()
+		c.do("POST", "/api/v1/users/me/deletion", map[string]string{"mode": "purge", "password": "password123"}).
+			expect(t, http.StatusAccepted)
+		c.do("POST", "/api/v1/users/me/deletion/confirm", map[string]string{"token": app.mail.lastToken(t)}).
+			expect(t, http.StatusOK)
+		var deletion models.AccountDeletion
+		if err := db.Model(&deletion).Where("status = ?", models.DeletionScheduled).
+			Update("execute_after", time.Now().Add(-time.Minute)).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Where("status = ?", models.DeletionScheduled).First(&deletion).Error; err != nil {
+			t.Fatal(err)
+		}
+		return deletion
+	}
+	status := func(id uint) string {
+		var deletion models.AccountDeletion
+		db.First(&deletion, id)
+		return deletion.Status
+	}
+
+	// A worker that loaded the deletion before another carried it out
+	// leaves it completed
+	alices := scheduled(app.signUp("alice"))
+	if executed, err := accounts.RunDue(db, app.cfg); err != nil || executed != 1 {
+		t.Fatalf("expected the deletion to be carried out, executed %d: %v", executed, err)
+	}
+	if err := accounts.Execute(db, app.cfg, &alices); !errors.Is(err, accounts.ErrNotScheduled) {
+		t.Fatalf("expected ErrNotScheduled, got %v", err)
+	}
+	if got := status(alices.ID); got != models.DeletionCompleted {
+		t.Fatalf("expected the deletion to stay completed, got %q", got)
+	}
+
+	// A deletion cancelled after a worker loaded it is not carried out
+	bob := app.signUp("bob")
+	bobs := scheduled(bob)
+	bob.do("DELETE", "/api/v1/users/me/deletion", nil).expect(t, http.StatusOK)
+	if err := accounts.Execute(db, app.cfg, &bobs); !errors.Is(err, accounts.ErrNotScheduled) {
+		t.Fatalf("expected ErrNotScheduled, got %v", err)
+	}
+	if got := status(bobs.ID); got != models.DeletionCancelled {
+		t.Fatalf("expected the deletion to stay cancelled, got %q", got)
+	}
+	bob.do("GET", "/api/v1/users/me", nil).expect(t, http.StatusOK)
+}
+
+func TestDeletedUserPlaceholder(t *testing.T) {
+	app := newTestApp(t)
+	admin := app.signUp("admin")
+	app.makeAdmin("admin")
+
+	for _, username := range []string{"deleted-user", "Deleted-User"} {
+		body := map[string]string{"username": username, "email": "squatter@example.com", "password": "password123"}
+		app.client().do("POST", "/api/v1/signup", body).expectProblem(t, http.StatusBadRequest, "validation_failed")
+		admin.do("POST", "/api/v1/users", body).expectProblem(t, http.StatusBadRequest, "validation_failed")
+	}
+
+	// An account that took the name before it was reserved gets nothing
+	squatter := models.User{Username: "deleted-user", Email: "squatter@example.com", Password: "password123"}
+	if err := app.db.DB.Create(&squatter).Error; err != nil {
+		t.Fatal(err)
+	}
+	bob := app.signUp("bob")
+	bob.do("POST", "/api/v1/posts/", map[string]string{"title": "Kept", "body": "Text"}).expect(t, http.StatusCreated)
+	bob.deleteAccount(map[string]string{"mode": "anonymize"})
+
+	var placeholder models.User
+	if err := app.db.DB.Where("placeholder = ?", true).First(&placeholder).Error; err != nil {
+		t.Fatal(err)
+	}
+	if placeholder.ID == squatter.ID {
+		t.Fatal("expected a new placeholder account, not the one named deleted-user")
+	}
+	var post models.Post
+	if err := app.db.DB.Where("title = ?", "Kept").First(&post).Error; err != nil || post.UserID != placeholder.ID {
+		t.Fatalf("expected the post to belong to the placeholder: %+v %v", post, err)
+	}
+
+	// The placeholder can neither receive posts nor be deleted
+	carol := app.signUp("carol")
+	carol.do("POST", "/api/v1/users/me/deletion", map[string]string{
+		"mode": "transfer", "transfer_to": placeholder.Username, "password": "password123",
+	}).expectProblem(t, http.StatusBadRequest, "invalid_transfer_target")
+	admin.do("POST", fmt.Sprintf("/api/v1/admin/users/%d/deletion", placeholder.ID), map[string]string{
+		"mode": "purge", "confirm_username": placeholder.Username,
+	}).expectProblem(t, http.StatusConflict, "placeholder_account")
+}
+
+func TestLegacyAPIAlias(t *testing.T) {
+	app := newTestApp(t)
+	c := app.signUp("alice")
+
+	legacy := c.do("GET", "/api/posts", nil).expect(t, http.StatusOK)
+	if deprecation := legacy.Header.Get("Deprecation"); !strings.HasPrefix(deprecation, "@") {
+		t.Fatalf("expected a Deprecation date, got %q", deprecation)
+	}
+	if legacy.Header.Get("Sunset") == "" {
+		t.Fatal("expected a Sunset header")
+	}
+	if link := legacy.Header.Get("Link"); link != `</api/v1/posts>; rel="successor-version"` {
+		t.Fatalf("expected a link to the v1 route, got %q", link)
+	}
+
+	// The alias shares the session and the guards of v1
+	c.do("GET", "/api/users/me", nil).expect(t, http.StatusOK)
+	app.client().do("GET", "/api/users/me", nil).expectProblem(t, http.StatusUnauthorized, "unauthorized")
+
+	current := c.do("GET", "/api/v1/posts", nil).expect(t, http.StatusOK)
+	if deprecation := current.Header.Get("Deprecation"); deprecation != "" {
+		t.Fatalf("expected v1 not to be deprecated, got %q", deprecation)
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	app := newTestApp(t, func(cfg *config.Config) {
+		cfg.RateLimit.Policies[config.PolicyLogin] = config.RateLimitPolicy{Limit: 2, PeriodSeconds: 60}
+	})
+	c := app.client()
+	credentials := map[string]string{"username": "nobody", "password": "password123"}
+
+	first := c.do("POST", "/api/v1/login", credentials).expectProblem(t, http.StatusNotFound, "user_not_found")
+	if limit, remaining := first.Header.Get("RateLimit-Limit"), first.Header.Get("RateLimit-Remaining"); limit != "2" || remaining != "1" {
+		t.Fatalf("expected limit 2 with 1 remaining, got %q and %q", limit, remaining)
+	}
+	// The deprecated alias shares the buckets of v1
+	c.do("POST", "/api/login", credentials).expectProblem(t, http.StatusNotFound, "user_not_found")
+
+	limited := c.do("POST", "/api/v1/login", credentials).expectProblem(t, http.StatusTooManyRequests, "rate_limited")
+	if retryAfter := limited.Header.Get("Retry-After"); retryAfter != "30" {
+		t.Fatalf("expected to retry after 30 seconds, got %q", retryAfter)
+	}
+	// A made-up bearer token does not get a bucket of its own
+	for i := 0; i < 3; i++ {
+		bearer := http.Header{"Authorization": {fmt.Sprintf("Bearer random-%d", i)}}
+		c.send("POST", "/api/v1/login", credentials, bearer).expectProblem(t, http.StatusTooManyRequests, "rate_limited")
+	}
+
+	// Other routes have policies of their own
+	c.do("GET", "/api/v1/posts", nil).expect(t, http.StatusOK)
+}
+
+func TestCSRF(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.signUp("alice")
+	post := map[string]string{"title": "First post", "body": "Hello"}
+
+	alice.send("POST", "/api/v1/posts/", post, nil).expectProblem(t, http.StatusForbidden, "invalid_csrf_token")
+	forged := http.Header{utils.CSRFHeader: {"not-the-token"}}
+	alice.send("POST", "/api/v1/posts/", post, forged).expectProblem(t, http.StatusForbidden, "invalid_csrf_token")
+	alice.send("POST", "/api/posts/", post, nil).expectProblem(t, http.StatusForbidden, "invalid_csrf_token")
+	alice.send("GET", "/api/v1/users/me", nil, nil).expect(t, http.StatusOK)
+
+	token := alice.send("GET", "/api/v1/csrf", nil, nil).expect(t, http.StatusOK)
+	if token.Body["authenticated"] != true || token.Header.Get("Cache-Control") != "no-store" {
+		t.Fatalf("unexpected token response %v %v", token.Body, token.Header)
+	}
+	valid := http.Header{utils.CSRFHeader: {token.Body["csrf_token"].(string)}}
+	alice.send("POST", "/api/v1/posts/", post, valid).expect(t, http.StatusCreated)
+
+	// A bearer header does not stand in for the token of a cookie session
+	bearer := http.Header{"Authorization": {"Bearer some-token"}}
+	alice.send("POST", "/api/v1/posts/", post, bearer).expectProblem(t, http.StatusForbidden, "invalid_csrf_token")
+
+	// Logging in again invalidates the previous token
+	alice.do("POST", "/api/v1/login", map[string]string{"username": "alice", "password": "password123"}).expect(t, http.StatusOK)
+	alice.send("POST", "/api/v1/posts/", post, valid).expectProblem(t, http.StatusForbidden, "invalid_csrf_token")
+	alice.do("POST", "/api/v1/posts/", post).expect(t, http.StatusCreated)
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	app := newTestApp(t)
+	c := app.client()
+	if err := os.WriteFile(filepath.Join(app.cfg.Server.FrontendDir, "index.html"), []byte("<!doctype html>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	frontend := c.do("GET", "/dashboard", nil).expect(t, http.StatusOK)
+	for header, want := range map[string]string{
+		"Strict-Transport-Security": "max-age=15552000",
+		"X-Content-Type-Options":    "nosniff",
+		"X-Frame-Options":           "DENY",
+		"Referrer-Policy":           "strict-origin-when-cross-origin",
+		"Reporting-Endpoints":       `csp-endpoint="/api/v1/csp-report"`,
+	} {
+		if got := frontend.Header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+	csp := frontend.Header.Get("Content-Security-Policy")
+	if !strings.HasPrefix(csp, "default-src 'self'") || !strings.Contains(csp, "frame-ancestors 'none'; report-uri /api/v1/csp-report") {
+		t.Errorf("unexpected frontend policy %q", csp)
+	}
+
+	if csp := c.do("GET", "/api/v1/posts", nil).Header.Get("Content-Security-Policy"); !strings.HasPrefix(csp, "default-src 'none'") {
+		t.Errorf("unexpected API policy %q", csp)
+	}
+
+	// The docs page gets a fresh nonce for its inline tags on every request
+	var nonces []string
+	for i := 0; i < 2; i++ {
+		docs := c.do("GET", "/api/v1/docs", nil).expect(t, http.StatusOK)
+		match := regexp.MustCompile(`'nonce-([^']+)'`).FindStringSubmatch(docs.Header.Get("Content-Security-Policy"))
+		if match == nil || !bytes.Contains(docs.Raw, []byte(`<style nonce="`+match[1]+`">`)) {
+			t.Fatalf("expected the page to carry the nonce of its policy %q", docs.Header.Get("Content-Security-Policy"))
+		}
+		nonces = append(nonces, match[1])
+	}
+	if nonces[0] == nonces[1] {
+		t.Error("expected a new nonce for every request")
+	}
+
+	reportOnly := newTestApp(t, func(cfg *config.Config) { cfg.Security.CSPReportOnly = true }).client()
+	res := reportOnly.do("GET", "/api/v1/posts", nil)
+	if res.Header.Get("Content-Security-Policy") != "" || res.Header.Get("Content-Security-Policy-Report-Only") == "" {
+		t.Errorf("expected only a report-only policy, got %v", res.Header)
+	}
+}
+
+func TestCSPReports(t *testing.T) {
+	app := newTestApp(t)
+	c := app.client()
+
+	c.do("POST", "/api/v1/csp-report", map[string]interface{}{
+		"csp-report": map[string]string{"document-uri": "http://localhost/", "violated-directive": "script-src 'self'", "blocked-uri": "inline"},
+	}).expect(t, http.StatusNoContent)
+	c.do("POST", "/api/v1/csp-report", []map[string]interface{}{
+		{"type": "csp-violation", "body": map[string]string{"documentURL": "http://localhost/", "effectiveDirective": "img-src", "blockedURL": "http://evil.example/x.png"}},
+		{"type": "deprecation", "body": map[string]string{}},
+	}).expect(t, http.StatusNoContent)
+	c.do("POST", "/api/v1/csp-report", map[string]string{"hello": "world"}).expectProblem(t, http.StatusBadRequest, "invalid_report")
+}
+
+// pngImage encodes a blank image of the given size
+func pngImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestMedia(t *testing.T) {
+	app := newTestApp(t, func(cfg *config.Config) { cfg.Media.MaxUploadMB = 1 })
+	alice := app.signUp("alice")
+	bob := app.signUp("bob")
+
+	uploaded := alice.upload("/api/v1/media", "../diagram.png", pngImage(t, 40, 30)).expect(t, http.StatusCreated)
+	file := uploaded.Body["media"].(map[string]interface{})
+	if file["Filename"] != "diagram.png" || file["ContentType"] != "image/png" || file["Width"] != 40.0 || file["Height"] != 30.0 {
+		t.Fatalf("unexpected media %v", file)
+	}
+	if _, ok := file["Key"]; ok {
+		t.Error("expected the storage key to be hidden")
+	}
+	url := file["URL"].(string)
+	if !strings.HasPrefix(url, "/uploads/media/") || !strings.HasSuffix(url, ".png") {
+		t.Fatalf("unexpected URL %q", url)
+	}
+	mediaID := uploaded.id(t, "media")
+	path := fmt.Sprintf("/api/v1/media/%d", mediaID)
+
+	// The type is detected from the content, not the name
+	alice.upload("/api/v1/media", "notes.png", []byte("just some text")).
+		expectProblem(t, http.StatusUnsupportedMediaType, "unsupported_media_type")
+	alice.upload("/api/v1/media", "huge.pdf", bytes.Repeat([]byte{'x'}, 2<<20)).
+		expectProblem(t, http.StatusRequestEntityTooLarge, "file_too_large")
+	alice.do("POST", "/api/v1/media", map[string]string{"file": "nope"}).expectProblem(t, http.StatusBadRequest, "file_required")
+
+	list := alice.do("GET", "/api/v1/media", nil).expect(t, http.StatusOK)
+	if items := list.Body["media"].([]interface{}); len(items) != 1 || list.Body["total"] != 1.0 {
+		t.Fatalf("expected one file, got %v", list.Body)
+	}
+	bob.do("GET", path, nil).expectProblem(t, http.StatusNotFound, "media_not_found")
+	bob.do("DELETE", path, nil).expectProblem(t, http.StatusNotFound, "media_not_found")
+
+	// Anyone can follow the file route to the stored content
+	content := app.client().do("GET", path+"/file", nil).expect(t, http.StatusOK)
+	if !bytes.Equal(content.Raw, pngImage(t, 40, 30)) {
+		t.Fatal("expected the file route to serve the uploaded image")
+	}
+
+	alice.do("DELETE", path, nil).expect(t, http.StatusOK)
+	alice.do("GET", path, nil).expectProblem(t, http.StatusNotFound, "media_not_found")
+	app.client().do("GET", url, nil).expect(t, http.StatusNotFound)
+
+	// Photos are stored without their metadata
+	var photo bytes.Buffer
+	if err := jpeg.Encode(&photo, image.NewGray(image.Rect(0, 0, 40, 30)), nil); err != nil {
+		t.Fatal(err)
+	}
+	secret := "taken at 52.5200 N 13.4050 E"
+	comment := append([]byte{0xFF, 0xFE, 0, byte(len(secret) + 2)}, secret...)
+	withComment := append(append(append([]byte{}, photo.Bytes()[:2]...), comment...), photo.Bytes()[2:]...)
+	uploaded = alice.upload("/api/v1/media", "photo.jpg", withComment).expect(t, http.StatusCreated)
+	path = fmt.Sprintf("/api/v1/media/%d", uploaded.id(t, "media"))
+	content = app.client().do("GET", path+"/file", nil).expect(t, http.StatusOK)
+	if bytes.Contains(content.Raw, []byte(secret)) {
+		t.Fatal("expected the comment to be stripped")
+	}
+	if size := uploaded.Body["media"].(map[string]interface{})["Size"]; size != float64(len(content.Raw)) {
+		t.Errorf("expected the size of the stored file, got %v", size)
+	}
+	alice.upload("/api/v1/media", "broken.jpg", withComment[:len(withComment)/2]).
+		expectProblem(t, http.StatusBadRequest, "validation_failed")
+}
+
+func TestImageVariants(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.signUp("alice")
+	reader := app.client()
+
+	screenshot := alice.upload("/api/v1/media", "screenshot.png", pngImage(t, 800, 600)).expect(t, http.StatusCreated)
+	path := fmt.Sprintf("/api/v1/media/%d/file", screenshot.id(t, "media"))
+
+	for query, want := range map[string]string{
+		"?w=320":                "png 320x240",
+		"?w=320&h=320":          "png 320x320",
+		"?w=640&fmt=webp":       "webp 640x480",
+		"?h=320&fmt=jpeg":       "jpeg 427x320",
+		"?w=1920&h=960&fmt=png": "png 800x400",
+	} {
+		variant := reader.do("GET", path+query, nil).expect(t, http.StatusOK)
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(variant.Raw))
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		if got := fmt.Sprintf("%s %dx%d", format, cfg.Width, cfg.Height); got != want {
+			t.Errorf("%s gave %s, want %s", query, got, want)
+		}
+	}
+
+	// Variants are generated once and then served from the storage
+	reader.do("GET", path+"?w=320", nil).expect(t, http.StatusOK)
+	var variants int64
+	app.db.DB.Model(&models.MediaVariant{}).Count(&variants)
+	if variants != 5 {
+		t.Fatalf("expected 5 stored variants, got %d", variants)
+	}
+
+	reader.do("GET", path+"?w=321", nil).expectProblem(t, http.StatusBadRequest, "validation_failed")
+	reader.do("GET", path+"?fmt=gif", nil).expectProblem(t, http.StatusBadRequest, "validation_failed")
+	reader.do("GET", path+"?w=big", nil).expectProblem(t, http.StatusBadRequest, "validation_failed")
+	pdf := alice.upload("/api/v1/media", "notes.pdf", []byte("%PDF-1.4\n%%EOF\n")).expect(t, http.StatusCreated)
+	reader.do("GET", fmt.Sprintf("/api/v1/media/%d/file?w=320", pdf.id(t, "media")), nil).
+		expectProblem(t, http.StatusBadRequest, "media_not_transformable")
+
+	// Rendered posts offer the image at every allowed width up to its own
+	created := alice.do("POST", "/api/v1/posts/", map[string]string{
+		"title": "Screenshots",
+		"body":  "![The dashboard](" + path + ")\n\n<script>alert(1)</script>",
+	}).expect(t, http.StatusCreated)
+	post := reader.do("GET", fmt.Sprintf("/api/v1/posts/%d", created.id(t, "post")), nil).expect(t, http.StatusOK)
+	html := post.Body["HTML"].(string)
+	for _, want := range []string{
+		`<img src="` + path + `?w=960&amp;fmt=webp" alt="The dashboard"`,
+		`width="800" height="600"`,
+		`srcset="` + path + `?w=320&amp;fmt=webp 320w, ` + path + `?w=640&amp;fmt=webp 640w, ` + path + `?w=960&amp;fmt=webp 800w"`,
+		`sizes="(max-width: 800px) 100vw, 800px"`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected %q in %s", want, html)
+		}
+	}
+	if strings.Contains(html, "<script>") {
+		t.Errorf("expected raw HTML to be left out of %s", html)
+	}
+
+	// Deleting an image deletes its variants
+	alice.do("DELETE", strings.TrimSuffix(path, "/file"), nil).expect(t, http.StatusOK)
+	app.db.DB.Model(&models.MediaVariant{}).Count(&variants)
+	if variants != 0 {
+		t.Fatalf("expected the variants to be deleted, %d are left", variants)
+	}
+}
+
+func TestTableOfContents(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.signUp("alice")
+
+	created := alice.do("POST", "/api/v1/posts/", map[string]string{
+		"title": "Deploying",
+		"body":  "[[toc]]\n\n## Build\n\n### Docker\n\n## Build\n\nDone.",
+	}).expect(t, http.StatusCreated)
+	post := app.client().do("GET", fmt.Sprintf("/api/v1/posts/%d", created.id(t, "post")), nil).expect(t, http.StatusOK)
+
+	html := post.Body["HTML"].(string)
+	for _, want := range []string{`<nav class="toc">`, `<a href="#user-content-docker">Docker</a>`, `<h2 id="user-content-build">`, `<h2 id="user-content-build-1">`} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected %q in %s", want, html)
+		}
+	}
+	toc, _ := json.Marshal(post.Body["TOC"])
+	want := `[{"Children":[{"Children":[],"ID":"user-content-docker","Level":3,"Title":"Docker"}],"ID":"user-content-build","Level":2,"Title":"Build"},` +
+		`{"Children":[],"ID":"user-content-build-1","Level":2,"Title":"Build"}]`
+	if string(toc) != want {
+		t.Errorf("TOC = %s, want %s", toc, want)
+	}
+	if excerpt := created.Body["post"].(map[string]interface{})["Excerpt"]; excerpt != "Done." {
+		t.Errorf("expected the placeholder to be left out of the excerpt, got %q", excerpt)
+	}
+}
+
+func TestDataExportRequest(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.signUp("alice")
+
+	alice.do("POST", "/api/v1/users/me/export", nil).expect(t, http.StatusAccepted)
+	alice.do("POST", "/api/v1/users/me/export", nil).expectProblem(t, http.StatusConflict, "export_in_progress")
+
+	exports := alice.do("GET", "/api/v1/users/me/exports", nil).expect(t, http.StatusOK)
+	if len(exports.List) != 1 || exports.List[0].(map[string]interface{})["Status"] != models.ExportPending {
+		t.Fatalf("expected one pending export, got %v", exports.List)
+	}
+}
+
+func TestDataExportArchive(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.signUp("alice")
+	bob := app.signUp("bob")
+
+	postID := alice.do("POST", "/api/v1/posts/", map[string]string{"title": "Hello, World!", "body": "First *post*"}).
+		expect(t, http.StatusCreated).id(t, "post")
+	alice.do("POST", "/api/v1/comments/", map[string]interface{}{"body": "Thanks for reading", "post_id": postID}).
+		expect(t, http.StatusCreated)
+	alice.upload("/api/v1/media", "photo.png", pngImage(t, 40, 30)).expect(t, http.StatusCreated)
+	bob.do("POST", "/api/v1/posts/", map[string]string{"title": "Not alice's", "body": "Other"}).expect(t, http.StatusCreated)
+
+	exportID := alice.do("POST", "/api/v1/users/me/export", nil).expect(t, http.StatusAccepted).id(t, "export")
+	download := fmt.Sprintf("/api/v1/users/me/exports/%d/download", exportID)
+	alice.do("GET", download, nil).expectProblem(t, http.StatusConflict, "export_not_ready")
+
+	if built, err := exports.RunPending(app.db.DB, app.cfg); err != nil || built != 1 {
+		t.Fatalf("expected one export to be built, built %d: %v", built, err)
+	}
+	bob.do("GET", download, nil).expectProblem(t, http.StatusNotFound, "export_not_found")
+	archive := alice.do("GET", download, nil).expect(t, http.StatusOK)
+
+	reader, err := zip.NewReader(bytes.NewReader(archive.Raw), int64(len(archive.Raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := map[string]string{}
+	for _, f := range reader.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[f.Name] = string(content)
+	}
+
+	for name, want := range map[string]string{
+		"README.txt":   "personal data",
+		"profile.json": `"Username": "alice"`,
+		"posts.json":   `"Title": "Hello, World!"`,
+		fmt.Sprintf("posts/%d-hello-world.md", postID): "title: \"Hello, World!\"",
+		"comments.json":          `"Body": "Thanks for reading"`,
+		"series.json":            "[]",
+		"media.json":             `"Filename": "photo.png"`,
+		"account_deletions.json": "[]",
+		"data_exports.json":      `"Status": "processing"`,
+	} {
+		content, ok := contents[name]
+		if !ok {
+			t.Errorf("archive lacks %s, has %v", name, reflect.ValueOf(contents).MapKeys())
+		} else if !strings.Contains(content, want) {
+			t.Errorf("expected %q in %s:\n%s", want, name, content)
+		}
+	}
+	if markdown := contents[fmt.Sprintf("posts/%d-hello-world.md", postID)]; !strings.HasSuffix(markdown, "---\n\nFirst *post*\n") {
+		t.Errorf("expected the body after the front matter:\n%s", markdown)
+	}
+	if len(contents) != 9 || strings.Contains(contents["posts.json"], "Not alice's") {
+		t.Errorf("expected only alice's data in the archive, got %v", reflect.ValueOf(contents).MapKeys())
+	}
+	if strings.Contains(contents["profile.json"], "Password") {
+		t.Errorf("expected no password hash in the profile:\n%s", contents["profile.json"])
+	}
+}
+
+func TestDataExportStalled(t *testing.T) {
+	app := newTestApp(t)
+	alice := app.signUp("alice")
+
+	exportID := alice.do("POST", "/api/v1/users/me/export", nil).expect(t, http.StatusAccepted).id(t, "export")
+
+	// A worker claimed the export and died while building it
+	startedAt := time.Now().Add(-app.cfg.Accounts.ExportTimeout() / 2)
+	claim := func() {
+		if err := app.db.DB.Model(&models.DataExport{}).Where("id = ?", exportID).
+			Updates(map[string]interface{}{"status": models.ExportProcessing, "started_at": startedAt}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	claim()
+	if built, err := exports.RunPending(app.db.DB, app.cfg); err != nil || built != 0 {
+		t.Fatalf("expected the export to be left to its worker, built %d: %v", built, err)
+	}
+	alice.do("POST", "/api/v1/users/me/export", nil).expectProblem(t, http.StatusConflict, "export_in_progress")
+
+	startedAt = time.Now().Add(-app.cfg.Accounts.ExportTimeout() - time.Minute)
+	claim()
+	if built, err := exports.RunPending(app.db.DB, app.cfg); err != nil || built != 1 {
+		t.Fatalf("expected the stalled export to be built again, built %d: %v", built, err)
+	}
+	var export models.DataExport
+	if err := app.db.DB.First(&export, exportID).Error; err != nil || export.Status != models.ExportReady {
+		t.Fatalf("expected the export to be ready: %+v %v", export, err)
+	}
+	alice.do("POST", "/api/v1/users/me/export", nil).expect(t, http.StatusAccepted)
+}
+
+func TestMigrationsRollBack(t *testing.T) {
+	app := newTestApp(t)
+
+	sqlDB, err := app.db.DB.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := migrations.New(sqlDB, app.db.Driver())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := migrations.Load(app.db.Driver())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rolledBack, err := migrator.Down(context.Background(), len(all))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rolledBack != len(all) {
+		t.Fatalf("rolled back %d of %d migrations", rolledBack, len(all))
+	}
+	if applied, err := migrator.Up(context.Background()); err != nil || applied != len(all) {
+		t.Fatalf("applied %d of %d migrations: %v", applied, len(all), err)
+	}
+}
@@ -0,0 +1,201 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// Codes shared by many endpoints. Endpoint-specific codes such as
+// post_not_found are passed to the constructors directly.
+const (
+	CodeInvalidJSON        = "invalid_json"
+	CodeValidationFailed   = "validation_failed"
+	CodeUnauthorized       = "unauthorized"
+	CodeForbidden          = "forbidden"
+	CodeNotFound           = "not_found"
+	CodeUniqueViolation    = "unique_violation"
+	CodeForeignKeyViolated = "foreign_key_violation"
+	CodeConstraintViolated = "constraint_violation"
+	CodeRateLimited        = "rate_limited"
+	CodeInternal           = "internal_error"
+)
+
+// FieldError describes why one field of a request failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error is an error that is reported to API clients with a status code, a
+// stable machine-readable code and a human-readable message. Err is the
+// underlying cause; it is logged but never shown to clients.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Fields  []FieldError
+	// Extra holds additional members of the response body
+	Extra map[string]interface{}
+	Err   error
+}
+
+// Error returns the message and the cause
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return e.Code + ": " + e.Message
+}
+
+// Unwrap returns the cause
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// With returns a copy of e with an additional member in the response body
+func (e *Error) With(key string, value interface{}) *Error {
+	copied := *e
+	copied.Extra = map[string]interface{}{key: value}
+	for k, v := range e.Extra {
+		copied.Extra[k] = v
+	}
+	return &copied
+}
+
+// New returns an error with the given status, code and message
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// BadRequest reports a request that cannot be processed as sent
+func BadRequest(code, message string) *Error {
+	return New(http.StatusBadRequest, code, message)
+}
+
+// Unauthorized reports a request that needs a logged-in user
+func Unauthorized() *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, "Unauthorized. Please log in.")
+}
+
+// Forbidden reports a request the logged-in user may not make
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, message)
+}
+
+// NotFound reports a missing resource
+func NotFound(code, message string) *Error {
+	return New(http.StatusNotFound, code, message)
+}
+
+// Conflict reports a request that conflicts with the current state
+func Conflict(code, message string) *Error {
+	return New(http.StatusConflict, code, message)
+}
+
+// TooManyRequests reports a client that exceeded a rate limit
+func TooManyRequests(message string) *Error {
+	return New(http.StatusTooManyRequests, CodeRateLimited, message)
+}
+
+// Internal reports an unexpected failure caused by err
+func Internal(message string, err error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Message: message, Err: err}
+}
+
+// Invalid converts an error from binding a request body into a 400 response,
+// listing every field that failed validation
+func Invalid(err error) *Error {
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		fields := make([]FieldError, 0, len(validationErrors))
+		for _, fe := range validationErrors {
+			fields = append(fields, FieldError{Field: fe.Field(), Code: fe.Tag(), Message: fieldMessage(fe)})
+		}
+		return &Error{
+			Status:  http.StatusBadRequest,
+			Code:    CodeValidationFailed,
+			Message: "The request contains invalid fields.",
+			Fields:  fields,
+			Err:     err,
+		}
+	}
+
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeError):
+		return &Error{
+			Status:  http.StatusBadRequest,
+			Code:    CodeValidationFailed,
+			Message: "The request contains invalid fields.",
+			Fields:  []FieldError{{Field: typeError.Field, Code: "type", Message: "must be a " + typeError.Type.String()}},
+			Err:     err,
+		}
+	case errors.As(err, &syntaxError), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return &Error{Status: http.StatusBadRequest, Code: CodeInvalidJSON, Message: "The request body is not valid JSON.", Err: err}
+	}
+	return &Error{Status: http.StatusBadRequest, Code: CodeValidationFailed, Message: "Invalid request data", Err: err}
+}
+
+// InvalidField reports a single field that failed a rule checked outside of
+// request binding
+func InvalidField(field, code, message string) *Error {
+	return &Error{
+		Status:  http.StatusBadRequest,
+		Code:    CodeValidationFailed,
+		Message: "The request contains invalid fields.",
+		Fields:  []FieldError{{Field: field, Code: code, Message: message}},
+	}
+}
+
+// FromDB converts a database error. A missing record becomes notFound,
+// constraint violations become 409 or 400 responses and anything else is an
+// internal error with message.
+func FromDB(err error, notFound *Error, message string) *Error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound) && notFound != nil:
+		copied := *notFound
+		copied.Err = err
+		return &copied
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return &Error{Status: http.StatusConflict, Code: CodeUniqueViolation, Message: "A record with these values already exists.", Err: err}
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return &Error{Status: http.StatusConflict, Code: CodeForeignKeyViolated, Message: "The request refers to a record that does not exist or is still referenced.", Err: err}
+	case errors.Is(err, gorm.ErrCheckConstraintViolated):
+		return &Error{Status: http.StatusBadRequest, Code: CodeConstraintViolated, Message: "The request violates a data constraint.", Err: err}
+	}
+	return Internal(message, err)
+}
+
+// fieldMessage describes a failed validation rule in words
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required", "required_if", "required_with", "required_without":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "url", "http_url":
+		return "must be a valid URL"
+	case "min":
+		if fe.Kind().String() == "string" {
+			return "must be at least " + fe.Param() + " characters long"
+		}
+		return "must be at least " + fe.Param()
+	case "max":
+		if fe.Kind().String() == "string" {
+			return "must be at most " + fe.Param() + " characters long"
+		}
+		return "must be at most " + fe.Param()
+	case "oneof":
+		return "must be one of: " + strings.Join(strings.Fields(fe.Param()), ", ")
+	}
+	return "failed the " + fe.Tag() + " rule"
+}
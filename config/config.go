@@ -0,0 +1,830 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Environments
+const (
+	Development = "development"
+	Production  = "production"
+)
+
+// Database drivers
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// Log formats
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+)
+
+// Rate limit stores
+const (
+	RateLimitStoreMemory   = "memory"
+	RateLimitStoreDatabase = "database"
+	RateLimitStoreRedis    = "redis"
+)
+
+// Rate limit policies. PolicyAPI applies to every API route without a policy of its own.
+const (
+	PolicyAPI      = "api"
+	PolicyLogin    = "login"
+	PolicySignup   = "signup"
+	PolicyPosts    = "posts"
+	PolicyComments = "comments"
+	PolicyMedia    = "media"
+)
+
+// Trace exporters
+const (
+	TracingExporterNone   = "none"
+	TracingExporterStdout = "stdout"
+	TracingExporterOTLP   = "otlp"
+)
+
+// Config holds every setting of the application
+type Config struct {
+	Env       string          `yaml:"env" toml:"env"`
+	AppURL    string          `yaml:"app_url" toml:"app_url"`
+	Server    ServerConfig    `yaml:"server" toml:"server"`
+	Database  DatabaseConfig  `yaml:"database" toml:"database"`
+	Session   SessionConfig   `yaml:"session" toml:"session"`
+	CORS      CORSConfig      `yaml:"cors" toml:"cors"`
+	Mail      MailConfig      `yaml:"mail" toml:"mail"`
+	Storage   StorageConfig   `yaml:"storage" toml:"storage"`
+	Accounts  AccountsConfig  `yaml:"accounts" toml:"accounts"`
+	Log       LogConfig       `yaml:"log" toml:"log"`
+	Metrics   MetricsConfig   `yaml:"metrics" toml:"metrics"`
+	Tracing   TracingConfig   `yaml:"tracing" toml:"tracing"`
+	API       APIConfig       `yaml:"api" toml:"api"`
+	RateLimit RateLimitConfig `yaml:"rate_limit" toml:"rate_limit"`
+	Security  SecurityConfig  `yaml:"security" toml:"security"`
+	Media     MediaConfig     `yaml:"media" toml:"media"`
+}
+
+// ServerConfig configures the HTTP server
+type ServerConfig struct {
+	Port        string `yaml:"port" toml:"port"`
+	FrontendDir string `yaml:"frontend_dir" toml:"frontend_dir"`
+	// ShutdownTimeoutSeconds is how long in-flight requests may take to
+	// finish after the server is asked to stop
+	ShutdownTimeoutSeconds int `yaml:"shutdown_timeout_seconds" toml:"shutdown_timeout_seconds"`
+	// TrustedProxies are the addresses or CIDR ranges of reverse proxies
+	// whose X-Forwarded-For header is believed. The client IP of requests
+	// from anywhere else is the address of the connection.
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies"`
+}
+
+// ShutdownTimeout is how long the server waits for in-flight requests when stopping
+func (s ServerConfig) ShutdownTimeout() time.Duration {
+	return time.Duration(s.ShutdownTimeoutSeconds) * time.Second
+}
+
+// DatabaseConfig configures the database connection
+type DatabaseConfig struct {
+	// Driver is postgres or sqlite
+	Driver string `yaml:"driver" toml:"driver"`
+	// DSN is the connection string. For Postgres it replaces the host, port,
+	// user, password, name and sslmode settings; for SQLite it is the path
+	// of the database file or :memory: for a database that only lives as
+	// long as the process.
+	DSN      string `yaml:"dsn" toml:"dsn"`
+	Host     string `yaml:"host" toml:"host"`
+	Port     int    `yaml:"port" toml:"port"`
+	User     string `yaml:"user" toml:"user"`
+	Password string `yaml:"password" toml:"password"`
+	Name     string `yaml:"name" toml:"name"`
+	SSLMode  string `yaml:"sslmode" toml:"sslmode"`
+	// MigrateOnStart applies pending migrations when the server starts
+	// instead of refusing to start
+	MigrateOnStart bool `yaml:"migrate_on_start" toml:"migrate_on_start"`
+	// SlowQueryMs is the duration in milliseconds above which queries are
+	// logged as slow; 0 disables slow query logging
+	SlowQueryMs int `yaml:"slow_query_ms" toml:"slow_query_ms"`
+}
+
+// SlowQueryThreshold is the duration above which queries are logged as slow
+func (d DatabaseConfig) SlowQueryThreshold() time.Duration {
+	return time.Duration(d.SlowQueryMs) * time.Millisecond
+}
+
+// SessionConfig configures the session cookie
+type SessionConfig struct {
+	Name          string `yaml:"name" toml:"name"`
+	Secret        string `yaml:"secret" toml:"secret"`
+	MaxAgeSeconds int    `yaml:"max_age_seconds" toml:"max_age_seconds"`
+	// SameSite is lax, strict or none; none requires Secure
+	SameSite string `yaml:"same_site" toml:"same_site"`
+	// Secure restricts the cookie to HTTPS
+	Secure bool `yaml:"secure" toml:"secure"`
+	// HttpOnly hides the cookie from scripts. It is off by default because
+	// the bundled frontend reads the cookie to tell whether a user is logged
+	// in and deletes it to log out.
+	HttpOnly bool `yaml:"http_only" toml:"http_only"`
+}
+
+// SameSiteMode is SameSite as an http.SameSite value
+func (s SessionConfig) SameSiteMode() http.SameSite {
+	switch strings.ToLower(s.SameSite) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+// CORSConfig configures cross-origin requests from the frontend
+type CORSConfig struct {
+	AllowOrigins []string `yaml:"allow_origins" toml:"allow_origins"`
+}
+
+// MailConfig configures outgoing email. Mail is logged instead of sent when SMTPHost is empty.
+type MailConfig struct {
+	SMTPHost     string `yaml:"smtp_host" toml:"smtp_host"`
+	SMTPPort     string `yaml:"smtp_port" toml:"smtp_port"`
+	SMTPUser     string `yaml:"smtp_user" toml:"smtp_user"`
+	SMTPPassword string `yaml:"smtp_password" toml:"smtp_password"`
+	From         string `yaml:"from" toml:"from"`
+}
+
+// StorageConfig configures where files are written
+type StorageConfig struct {
+	UploadDir string `yaml:"upload_dir" toml:"upload_dir"`
+	ExportDir string `yaml:"export_dir" toml:"export_dir"`
+}
+
+// Media stores
+const (
+	MediaStoreLocal = "local"
+	MediaStoreS3    = "s3"
+)
+
+// MediaConfig configures the files users upload to embed in their posts
+type MediaConfig struct {
+	// Store is local, which writes below the upload directory, or s3, which
+	// works with any S3-compatible service
+	Store string `yaml:"store" toml:"store"`
+	// MaxUploadMB caps the size of one file
+	MaxUploadMB int `yaml:"max_upload_mb" toml:"max_upload_mb"`
+	// AllowedTypes are the accepted MIME types, as detected from the content
+	AllowedTypes []string `yaml:"allowed_types" toml:"allowed_types"`
+	// SignedURLTTLSeconds is how long signed URLs to files in a private
+	// bucket stay valid
+	SignedURLTTLSeconds int `yaml:"signed_url_ttl_seconds" toml:"signed_url_ttl_seconds"`
+	// ImageWidths are the sizes, in pixels, that images may be resized to.
+	// Crops take their height from the same list, so that only a bounded
+	// number of variants can be generated for each image.
+	ImageWidths []int    `yaml:"image_widths" toml:"image_widths"`
+	S3          S3Config `yaml:"s3" toml:"s3"`
+}
+
+// MaxUploadBytes is MaxUploadMB in bytes
+func (m MediaConfig) MaxUploadBytes() int64 {
+	return int64(m.MaxUploadMB) << 20
+}
+
+// SignedURLTTL is how long signed URLs stay valid
+func (m MediaConfig) SignedURLTTL() time.Duration {
+	return time.Duration(m.SignedURLTTLSeconds) * time.Second
+}
+
+// S3Config locates the bucket of the s3 media store
+type S3Config struct {
+	// Endpoint is the base URL of the service, e.g. https://s3.eu-west-1.amazonaws.com
+	// or http://localhost:9000 for MinIO
+	Endpoint string `yaml:"endpoint" toml:"endpoint"`
+	Region   string `yaml:"region" toml:"region"`
+	Bucket   string `yaml:"bucket" toml:"bucket"`
+	// PathStyle addresses the bucket as endpoint/bucket rather than as a
+	// subdomain, as MinIO and most other compatible services expect
+	PathStyle       bool   `yaml:"path_style" toml:"path_style"`
+	AccessKeyID     string `yaml:"access_key_id" toml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key" toml:"secret_access_key"`
+	// PublicURL, when set, is the public base URL of the bucket, such as a
+	// CDN. Without it the bucket is treated as private and files are served
+	// through signed URLs.
+	PublicURL string `yaml:"public_url" toml:"public_url"`
+}
+
+// AccountsConfig configures retention and account lifecycle periods
+type AccountsConfig struct {
+	TrashRetentionDays       int `yaml:"trash_retention_days" toml:"trash_retention_days"`
+	DeletionCoolingOffDays   int `yaml:"deletion_cooling_off_days" toml:"deletion_cooling_off_days"`
+	ExportDownloadValidHours int `yaml:"export_download_valid_hours" toml:"export_download_valid_hours"`
+	// ExportTimeoutMinutes is how long an export may be processing before it
+	// is assumed to have died with its worker and is built again
+	ExportTimeoutMinutes int `yaml:"export_timeout_minutes" toml:"export_timeout_minutes"`
+}
+
+// LogConfig configures the application log
+type LogConfig struct {
+	// Level is debug, info, warn or error. Database queries are logged at debug.
+	Level string `yaml:"level" toml:"level"`
+	// Format is json or text
+	Format string `yaml:"format" toml:"format"`
+}
+
+// MetricsConfig configures the Prometheus endpoint
+type MetricsConfig struct {
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+	// Token, when set, must be sent as "Authorization: Bearer <token>" to read the metrics
+	Token string `yaml:"token" toml:"token"`
+}
+
+// TracingConfig configures OpenTelemetry tracing
+type TracingConfig struct {
+	// Exporter is none, stdout or otlp
+	Exporter string `yaml:"exporter" toml:"exporter"`
+	// Endpoint is the OTLP/HTTP URL, e.g. http://localhost:4318. When empty
+	// the standard OTEL_EXPORTER_OTLP_* environment variables apply.
+	Endpoint    string  `yaml:"endpoint" toml:"endpoint"`
+	ServiceName string  `yaml:"service_name" toml:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio"`
+}
+
+// APIConfig configures the versions of the API
+type APIConfig struct {
+	// LegacySunset is the date, as YYYY-MM-DD, after which the unversioned
+	// /api/ routes may be removed. It is announced in the Sunset header of
+	// their responses; when empty the header is left out.
+	LegacySunset string `yaml:"legacy_sunset" toml:"legacy_sunset"`
+}
+
+// LegacySunsetDate is LegacySunset as a time, or the zero time when it is not set
+func (a APIConfig) LegacySunsetDate() time.Time {
+	date, _ := time.Parse(time.DateOnly, a.LegacySunset)
+	return date
+}
+
+// RateLimitConfig configures request throttling
+type RateLimitConfig struct {
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+	// Store is memory, database or redis. The memory store counts per
+	// process; the others share the counts between server instances.
+	Store string `yaml:"store" toml:"store"`
+	// RedisURL locates the redis store, e.g. redis://localhost:6379/0
+	RedisURL string `yaml:"redis_url" toml:"redis_url"`
+	// Policies are the token buckets by name, see the Policy constants
+	Policies map[string]RateLimitPolicy `yaml:"policies" toml:"policies"`
+}
+
+// RateLimitPolicy lets Limit requests through at once and refills them
+// steadily over PeriodSeconds
+type RateLimitPolicy struct {
+	Limit         int `yaml:"limit" toml:"limit"`
+	PeriodSeconds int `yaml:"period_seconds" toml:"period_seconds"`
+}
+
+// Period is how long an empty bucket takes to refill
+func (p RateLimitPolicy) Period() time.Duration {
+	return time.Duration(p.PeriodSeconds) * time.Second
+}
+
+// SecurityConfig configures the security headers of every response
+type SecurityConfig struct {
+	// HSTSMaxAgeSeconds is how long browsers only connect over HTTPS after
+	// seeing the Strict-Transport-Security header; 0 leaves the header out
+	HSTSMaxAgeSeconds     int  `yaml:"hsts_max_age_seconds" toml:"hsts_max_age_seconds"`
+	HSTSIncludeSubdomains bool `yaml:"hsts_include_subdomains" toml:"hsts_include_subdomains"`
+	// CSP is the Content-Security-Policy of the frontend. The API and its
+	// documentation page have stricter policies of their own.
+	CSP string `yaml:"csp" toml:"csp"`
+	// CSPReportOnly sends the policies as Content-Security-Policy-Report-Only,
+	// so that violations are reported but not blocked
+	CSPReportOnly bool `yaml:"csp_report_only" toml:"csp_report_only"`
+	// FrameAncestors lists who may show the site in a frame, such as 'none',
+	// 'self' or an origin
+	FrameAncestors    string `yaml:"frame_ancestors" toml:"frame_ancestors"`
+	ReferrerPolicy    string `yaml:"referrer_policy" toml:"referrer_policy"`
+	PermissionsPolicy string `yaml:"permissions_policy" toml:"permissions_policy"`
+}
+
+// TrashRetention is how long deleted items stay restorable before they are purged
+func (a AccountsConfig) TrashRetention() time.Duration {
+	return time.Duration(a.TrashRetentionDays) * 24 * time.Hour
+}
+
+// DeletionCoolingOff is how long a confirmed account deletion waits before it is carried out
+func (a AccountsConfig) DeletionCoolingOff() time.Duration {
+	return time.Duration(a.DeletionCoolingOffDays) * 24 * time.Hour
+}
+
+// ExportTTL is how long a finished data export can be downloaded
+func (a AccountsConfig) ExportTTL() time.Duration {
+	return time.Duration(a.ExportDownloadValidHours) * time.Hour
+}
+
+// ExportTimeout is how long an export may be processing before it is built again
+func (a AccountsConfig) ExportTimeout() time.Duration {
+	return time.Duration(a.ExportTimeoutMinutes) * time.Minute
+}
+
+// IsProduction reports whether the application runs in production
+func (c *Config) IsProduction() bool {
+	return c.Env == Production
+}
+
+// Default returns the configuration used for local development
+func Default() *Config {
+	return &Config{
+		Env:    Development,
+		AppURL: "http://localhost:5173",
+		Server: ServerConfig{
+			Port:                   "8005",
+			FrontendDir:            "./frontend/build",
+			ShutdownTimeoutSeconds: 15,
+		},
+		Database: DatabaseConfig{
+			Driver:      DriverPostgres,
+			Host:        "localhost",
+			Port:        5432,
+			SSLMode:     "disable",
+			SlowQueryMs: 200,
+		},
+		Session: SessionConfig{
+			Name:          "mysession",
+			MaxAgeSeconds: 30 * 24 * 60 * 60,
+			SameSite:      "lax",
+		},
+		CORS: CORSConfig{
+			AllowOrigins: []string{"http://localhost:5173"},
+		},
+		Mail: MailConfig{
+			SMTPPort: "587",
+			From:     "TechBlog <no-reply@localhost>",
+		},
+		Storage: StorageConfig{
+			UploadDir: "./uploads",
+			ExportDir: "./data/exports",
+		},
+		Accounts: AccountsConfig{
+			TrashRetentionDays:       30,
+			DeletionCoolingOffDays:   14,
+			ExportDownloadValidHours: 72,
+			ExportTimeoutMinutes:     30,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: LogFormatJSON,
+		},
+		Metrics: MetricsConfig{
+			Enabled: true,
+		},
+		Tracing: TracingConfig{
+			Exporter:    TracingExporterNone,
+			ServiceName: "techblog",
+			SampleRatio: 1,
+		},
+		API: APIConfig{
+			LegacySunset: "2027-04-30",
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: true,
+			Store:   RateLimitStoreMemory,
+			Policies: map[string]RateLimitPolicy{
+				PolicyAPI:      {Limit: 300, PeriodSeconds: 60},
+				PolicyLogin:    {Limit: 10, PeriodSeconds: 300},
+				PolicySignup:   {Limit: 10, PeriodSeconds: 3600},
+				PolicyPosts:    {Limit: 10, PeriodSeconds: 600},
+				PolicyComments: {Limit: 20, PeriodSeconds: 600},
+				PolicyMedia:    {Limit: 30, PeriodSeconds: 3600},
+			},
+		},
+		Media: MediaConfig{
+			Store:               MediaStoreLocal,
+			MaxUploadMB:         10,
+			AllowedTypes:        []string{"image/jpeg", "image/png", "image/gif", "image/webp", "application/pdf"},
+			SignedURLTTLSeconds: 3600,
+			ImageWidths:         []int{320, 640, 960, 1280, 1920},
+			S3: S3Config{
+				Region: "us-east-1",
+			},
+		},
+		Security: SecurityConfig{
+			HSTSMaxAgeSeconds: 180 * 24 * 60 * 60,
+			// The frontend's dialogs set inline styles
+			CSP:               "default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' data: https:; font-src 'self' data:; connect-src 'self'; object-src 'none'; base-uri 'self'; form-action 'self'",
+			FrameAncestors:    "'none'",
+			ReferrerPolicy:    "strict-origin-when-cross-origin",
+			PermissionsPolicy: "camera=(), microphone=(), geolocation=(), payment=(), usb=()",
+		},
+	}
+}
+
+// Load builds the configuration from, in increasing order of precedence,
+// the defaults, an optional YAML or TOML file, environment variables and
+// command-line flags. The file is named by the -config flag or CONFIG_FILE.
+// The configuration flags are added to fs, so a command can register its own
+// flags first and read its positional arguments from fs.Args afterwards.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := Default()
+
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file")
+	env := fs.String("env", "", "environment: development or production")
+	port := fs.String("port", "", "HTTP port to listen on")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		if err := loadFile(cfg, *configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(cfg, os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	if *env != "" {
+		cfg.Env = *env
+	}
+	if *port != "" {
+		cfg.Server.Port = *port
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Development needs no secrets; production is rejected by Validate above
+	if cfg.Session.Secret == "" {
+		cfg.Session.Secret = "insecure-development-session-secret"
+	}
+	return cfg, nil
+}
+
+// loadFile merges a YAML or TOML file, chosen by its extension, into cfg
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("config file %s must have a .yaml, .yml or .toml extension", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv overrides cfg with the environment variables that are set
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) error {
+	var errs []error
+
+	str := func(dst *string, key string) {
+		if v, ok := lookup(key); ok && v != "" {
+			*dst = v
+		}
+	}
+	num := func(dst *int, key string) {
+		if v, ok := lookup(key); ok && v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s must be a whole number, got %q", key, v))
+				return
+			}
+			*dst = n
+		}
+	}
+	fraction := func(dst *float64, key string) {
+		if v, ok := lookup(key); ok && v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s must be a number, got %q", key, v))
+				return
+			}
+			*dst = f
+		}
+	}
+	boolean := func(dst *bool, key string) {
+		if v, ok := lookup(key); ok && v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s must be true or false, got %q", key, v))
+				return
+			}
+			*dst = b
+		}
+	}
+	fallback := func(dst *string, key string) {
+		if v, ok := lookup(key); ok && v != "" && *dst == "" {
+			*dst = v
+		}
+	}
+	numbers := func(dst *[]int, key string) {
+		if v, ok := lookup(key); ok && v != "" {
+			var items []int
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item == "" {
+					continue
+				}
+				n, err := strconv.Atoi(item)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s must be a comma-separated list of whole numbers, got %q", key, v))
+					return
+				}
+				items = append(items, n)
+			}
+			*dst = items
+		}
+	}
+	list := func(dst *[]string, key string) {
+		if v, ok := lookup(key); ok && v != "" {
+			var items []string
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			*dst = items
+		}
+	}
+
+	str(&cfg.Env, "APP_ENV")
+	str(&cfg.AppURL, "APP_URL")
+
+	str(&cfg.Server.Port, "PORT")
+	str(&cfg.Server.FrontendDir, "FRONTEND_DIR")
+	num(&cfg.Server.ShutdownTimeoutSeconds, "SHUTDOWN_TIMEOUT_SECONDS")
+	list(&cfg.Server.TrustedProxies, "TRUSTED_PROXIES")
+
+	str(&cfg.Database.Driver, "DB_DRIVER")
+	str(&cfg.Database.DSN, "DB_DSN")
+	str(&cfg.Database.Host, "DB_HOST")
+	num(&cfg.Database.Port, "DB_PORT")
+	str(&cfg.Database.User, "DB_USER")
+	str(&cfg.Database.Password, "DB_PASSWORD")
+	str(&cfg.Database.Name, "DB_NAME")
+	str(&cfg.Database.SSLMode, "DB_SSLMODE")
+	boolean(&cfg.Database.MigrateOnStart, "DB_MIGRATE_ON_START")
+	num(&cfg.Database.SlowQueryMs, "DB_SLOW_QUERY_MS")
+
+	// USER, PASS and DBNAME are the names used before the config package
+	// existed. USER is also set by most shells, so they only fill in values
+	// that are not configured any other way.
+	fallback(&cfg.Database.User, "USER")
+	fallback(&cfg.Database.Password, "PASS")
+	fallback(&cfg.Database.Name, "DBNAME")
+
+	str(&cfg.Session.Name, "SESSION_NAME")
+	str(&cfg.Session.Secret, "SESSION_SECRET")
+	num(&cfg.Session.MaxAgeSeconds, "SESSION_MAX_AGE_SECONDS")
+	str(&cfg.Session.SameSite, "SESSION_SAME_SITE")
+	boolean(&cfg.Session.Secure, "SESSION_SECURE")
+	boolean(&cfg.Session.HttpOnly, "SESSION_HTTP_ONLY")
+
+	list(&cfg.CORS.AllowOrigins, "CORS_ALLOW_ORIGINS")
+
+	str(&cfg.Mail.SMTPHost, "SMTP_HOST")
+	str(&cfg.Mail.SMTPPort, "SMTP_PORT")
+	str(&cfg.Mail.SMTPUser, "SMTP_USER")
+	str(&cfg.Mail.SMTPPassword, "SMTP_PASS")
+	str(&cfg.Mail.From, "MAIL_FROM")
+
+	str(&cfg.Storage.UploadDir, "UPLOAD_DIR")
+	str(&cfg.Storage.ExportDir, "EXPORT_DIR")
+
+	num(&cfg.Accounts.TrashRetentionDays, "TRASH_RETENTION_DAYS")
+	num(&cfg.Accounts.DeletionCoolingOffDays, "ACCOUNT_DELETION_COOLING_OFF_DAYS")
+	num(&cfg.Accounts.ExportDownloadValidHours, "EXPORT_TTL_HOURS")
+	num(&cfg.Accounts.ExportTimeoutMinutes, "EXPORT_TIMEOUT_MINUTES")
+
+	str(&cfg.Log.Level, "LOG_LEVEL")
+	str(&cfg.Log.Format, "LOG_FORMAT")
+
+	boolean(&cfg.Metrics.Enabled, "METRICS_ENABLED")
+	str(&cfg.Metrics.Token, "METRICS_TOKEN")
+
+	str(&cfg.Tracing.Exporter, "TRACING_EXPORTER")
+	str(&cfg.Tracing.Endpoint, "TRACING_ENDPOINT")
+	str(&cfg.Tracing.ServiceName, "TRACING_SERVICE_NAME")
+	fraction(&cfg.Tracing.SampleRatio, "TRACING_SAMPLE_RATIO")
+
+	str(&cfg.API.LegacySunset, "API_LEGACY_SUNSET")
+
+	boolean(&cfg.RateLimit.Enabled, "RATE_LIMIT_ENABLED")
+	str(&cfg.RateLimit.Store, "RATE_LIMIT_STORE")
+	str(&cfg.RateLimit.RedisURL, "REDIS_URL")
+
+	str(&cfg.Media.Store, "MEDIA_STORE")
+	num(&cfg.Media.MaxUploadMB, "MEDIA_MAX_UPLOAD_MB")
+	list(&cfg.Media.AllowedTypes, "MEDIA_ALLOWED_TYPES")
+	num(&cfg.Media.SignedURLTTLSeconds, "MEDIA_SIGNED_URL_TTL_SECONDS")
+	numbers(&cfg.Media.ImageWidths, "MEDIA_IMAGE_WIDTHS")
+	str(&cfg.Media.S3.Endpoint, "S3_ENDPOINT")
+	str(&cfg.Media.S3.Region, "S3_REGION")
+	str(&cfg.Media.S3.Bucket, "S3_BUCKET")
+	boolean(&cfg.Media.S3.PathStyle, "S3_PATH_STYLE")
+	str(&cfg.Media.S3.AccessKeyID, "S3_ACCESS_KEY_ID")
+	str(&cfg.Media.S3.SecretAccessKey, "S3_SECRET_ACCESS_KEY")
+	str(&cfg.Media.S3.PublicURL, "S3_PUBLIC_URL")
+
+	num(&cfg.Security.HSTSMaxAgeSeconds, "HSTS_MAX_AGE_SECONDS")
+	boolean(&cfg.Security.HSTSIncludeSubdomains, "HSTS_INCLUDE_SUBDOMAINS")
+	str(&cfg.Security.CSP, "CSP")
+	boolean(&cfg.Security.CSPReportOnly, "CSP_REPORT_ONLY")
+	str(&cfg.Security.FrameAncestors, "FRAME_ANCESTORS")
+	str(&cfg.Security.ReferrerPolicy, "REFERRER_POLICY")
+	str(&cfg.Security.PermissionsPolicy, "PERMISSIONS_POLICY")
+
+	return errors.Join(errs...)
+}
+
+// Validate checks the configuration and reports every problem at once
+func (c *Config) Validate() error {
+	var problems []string
+
+	if c.Env != Development && c.Env != Production {
+		problems = append(problems, fmt.Sprintf("env must be %q or %q, got %q", Development, Production, c.Env))
+	}
+	if c.Server.Port == "" {
+		problems = append(problems, "server port is required (PORT)")
+	}
+	if c.Server.ShutdownTimeoutSeconds < 1 {
+		problems = append(problems, "shutdown timeout must be at least 1 second (SHUTDOWN_TIMEOUT_SECONDS)")
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				problems = append(problems, fmt.Sprintf("trusted proxy %q is neither an IP address nor a CIDR range (TRUSTED_PROXIES)", proxy))
+			}
+		}
+	}
+	switch c.Database.Driver {
+	case DriverPostgres:
+		if c.Database.DSN == "" {
+			if c.Database.User == "" {
+				problems = append(problems, "database user is required (DB_USER)")
+			}
+			if c.Database.Name == "" {
+				problems = append(problems, "database name is required (DB_NAME)")
+			}
+			if c.Database.Port <= 0 || c.Database.Port > 65535 {
+				problems = append(problems, fmt.Sprintf("database port %d is out of range (DB_PORT)", c.Database.Port))
+			}
+		}
+	case DriverSQLite:
+		if c.Database.DSN == "" {
+			problems = append(problems, "the SQLite database file is required, or :memory: (DB_DSN)")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("database driver must be %q or %q, got %q (DB_DRIVER)", DriverPostgres, DriverSQLite, c.Database.Driver))
+	}
+	if c.Session.MaxAgeSeconds < 1 {
+		problems = append(problems, "sessions must last at least 1 second (SESSION_MAX_AGE_SECONDS)")
+	}
+	switch strings.ToLower(c.Session.SameSite) {
+	case "lax", "strict":
+	case "none":
+		if !c.Session.Secure {
+			problems = append(problems, "a SameSite=None session cookie must be Secure (SESSION_SECURE)")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("session SameSite must be lax, strict or none, got %q (SESSION_SAME_SITE)", c.Session.SameSite))
+	}
+	if c.Database.SlowQueryMs < 0 {
+		problems = append(problems, "slow query threshold cannot be negative (DB_SLOW_QUERY_MS)")
+	}
+	if c.Accounts.TrashRetentionDays < 1 {
+		problems = append(problems, "trash retention must be at least 1 day (TRASH_RETENTION_DAYS)")
+	}
+	if c.Accounts.DeletionCoolingOffDays < 0 {
+		problems = append(problems, "account deletion cooling-off period cannot be negative (ACCOUNT_DELETION_COOLING_OFF_DAYS)")
+	}
+	if c.Accounts.ExportDownloadValidHours < 1 {
+		problems = append(problems, "export downloads must stay valid for at least 1 hour (EXPORT_TTL_HOURS)")
+	}
+	if c.Accounts.ExportTimeoutMinutes < 1 {
+		problems = append(problems, "exports must be given at least 1 minute to build (EXPORT_TIMEOUT_MINUTES)")
+	}
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		problems = append(problems, fmt.Sprintf("log level must be debug, info, warn or error, got %q (LOG_LEVEL)", c.Log.Level))
+	}
+	if c.Log.Format != LogFormatJSON && c.Log.Format != LogFormatText {
+		problems = append(problems, fmt.Sprintf("log format must be %q or %q, got %q (LOG_FORMAT)", LogFormatJSON, LogFormatText, c.Log.Format))
+	}
+	switch c.Tracing.Exporter {
+	case TracingExporterNone, TracingExporterStdout, TracingExporterOTLP:
+	default:
+		problems = append(problems, fmt.Sprintf("tracing exporter must be %s, %s or %s, got %q (TRACING_EXPORTER)",
+			TracingExporterNone, TracingExporterStdout, TracingExporterOTLP, c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		problems = append(problems, fmt.Sprintf("tracing sample ratio must be between 0 and 1, got %g (TRACING_SAMPLE_RATIO)", c.Tracing.SampleRatio))
+	}
+	if c.RateLimit.Enabled {
+		switch c.RateLimit.Store {
+		case RateLimitStoreMemory, RateLimitStoreDatabase:
+		case RateLimitStoreRedis:
+			if u, err := url.Parse(c.RateLimit.RedisURL); err != nil || (u.Scheme != "redis" && u.Scheme != "rediss" && u.Scheme != "unix") {
+				problems = append(problems, fmt.Sprintf("the redis rate limit store needs a redis:// or rediss:// URL, got %q (REDIS_URL)", c.RateLimit.RedisURL))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("rate limit store must be %s, %s or %s, got %q (RATE_LIMIT_STORE)",
+				RateLimitStoreMemory, RateLimitStoreDatabase, RateLimitStoreRedis, c.RateLimit.Store))
+		}
+		for _, name := range []string{PolicyAPI, PolicyLogin, PolicySignup, PolicyPosts, PolicyComments, PolicyMedia} {
+			policy, ok := c.RateLimit.Policies[name]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("rate limit policy %q is missing", name))
+			} else if policy.Limit < 1 || policy.PeriodSeconds < 1 {
+				problems = append(problems, fmt.Sprintf("rate limit policy %q needs a limit and a period of at least 1", name))
+			}
+		}
+	}
+	if c.API.LegacySunset != "" {
+		if _, err := time.Parse(time.DateOnly, c.API.LegacySunset); err != nil {
+			problems = append(problems, fmt.Sprintf("legacy API sunset must be a date like 2027-04-30, got %q (API_LEGACY_SUNSET)", c.API.LegacySunset))
+		}
+	}
+	switch c.Media.Store {
+	case MediaStoreLocal:
+	case MediaStoreS3:
+		if c.Media.S3.Endpoint == "" || c.Media.S3.Bucket == "" || c.Media.S3.Region == "" {
+			problems = append(problems, "the s3 media store needs an endpoint, a bucket and a region (S3_ENDPOINT, S3_BUCKET, S3_REGION)")
+		} else if u, err := url.Parse(c.Media.S3.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("S3 endpoint must be an http or https URL, got %q (S3_ENDPOINT)", c.Media.S3.Endpoint))
+		}
+		if c.Media.S3.AccessKeyID == "" || c.Media.S3.SecretAccessKey == "" {
+			problems = append(problems, "the s3 media store needs credentials (S3_ACCESS_KEY_ID, S3_SECRET_ACCESS_KEY)")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("media store must be %q or %q, got %q (MEDIA_STORE)", MediaStoreLocal, MediaStoreS3, c.Media.Store))
+	}
+	if c.Media.MaxUploadMB < 1 {
+		problems = append(problems, "media uploads must be allowed at least 1 MB (MEDIA_MAX_UPLOAD_MB)")
+	}
+	if len(c.Media.AllowedTypes) == 0 {
+		problems = append(problems, "at least one media type must be allowed (MEDIA_ALLOWED_TYPES)")
+	}
+	// Signed S3 URLs cannot be valid for longer than a week
+	if c.Media.SignedURLTTLSeconds < 1 || c.Media.SignedURLTTLSeconds > 7*24*60*60 {
+		problems = append(problems, "signed media URLs must be valid for between 1 second and 7 days (MEDIA_SIGNED_URL_TTL_SECONDS)")
+	}
+	// Every width doubles as a crop height, so the number of variants of an
+	// image grows with the square of the list
+	if len(c.Media.ImageWidths) == 0 || len(c.Media.ImageWidths) > 10 {
+		problems = append(problems, "between 1 and 10 image widths must be allowed (MEDIA_IMAGE_WIDTHS)")
+	}
+	for _, width := range c.Media.ImageWidths {
+		if width < 1 {
+			problems = append(problems, fmt.Sprintf("image widths must be positive, got %d (MEDIA_IMAGE_WIDTHS)", width))
+		}
+	}
+
+	if c.Security.HSTSMaxAgeSeconds < 0 {
+		problems = append(problems, "HSTS max age cannot be negative (HSTS_MAX_AGE_SECONDS)")
+	}
+	if strings.Contains(c.Security.CSP, "frame-ancestors") {
+		problems = append(problems, "set frame-ancestors with FRAME_ANCESTORS rather than in the policy (CSP)")
+	}
+	if c.Security.FrameAncestors == "" {
+		problems = append(problems, "frame ancestors cannot be empty; use 'none' to forbid framing (FRAME_ANCESTORS)")
+	}
+
+	if c.IsProduction() {
+		if len(c.Session.Secret) < 32 {
+			problems = append(problems, "a session secret of at least 32 characters is required in production (SESSION_SECRET)")
+		}
+		if c.Database.Driver == DriverPostgres && c.Database.DSN == "" && c.Database.Password == "" {
+			problems = append(problems, "a database password is required in production (DB_PASSWORD)")
+		}
+		if c.Mail.SMTPHost == "" {
+			problems = append(problems, "an SMTP server is required in production (SMTP_HOST)")
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
@@ -1,8 +1,14 @@
 package connect
 
 import (
-	"TechBlog/models"
+	"TechBlog/config"
+	"TechBlog/logging"
+	"TechBlog/metrics"
+	"TechBlog/tracing"
 	"fmt"
+	"strings"
+
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -12,26 +18,78 @@ type DBConfig struct {
 	DB *gorm.DB
 }
 
-// DBConnect initializes the database connection and migrates models
-func DBConnect(user string, pass string, dbname string) (*DBConfig, error) {
-	dsn := fmt.Sprintf(
-		"host=localhost user=%s password=%s dbname=%s port=5432 sslmode=disable",
-		user, pass, dbname,
-	)
+// DBConnect initializes the database connection. The schema is managed by
+// the migrations package. Queries are logged through slog, measured for the
+// metrics endpoint and traced.
+func DBConnect(cfg config.DatabaseConfig) (*DBConfig, error) {
+	var dialector gorm.Dialector
+	switch cfg.Driver {
+	case config.DriverSQLite:
+		dialector = sqlite.Open(sqliteDSN(cfg.DSN))
+	default:
+		dsn := cfg.DSN
+		if dsn == "" {
+			dsn = fmt.Sprintf(
+				"host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
+				cfg.Host, cfg.User, cfg.Password, cfg.Name, cfg.Port, cfg.SSLMode,
+			)
+		}
+		dialector = postgres.Open(dsn)
+	}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger:         logging.NewGormLogger(cfg.SlowQueryThreshold()),
+		TranslateError: true,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to the database: %w", err)
 	}
 
-	err = db.AutoMigrate(
-		&models.User{},
-		&models.Post{},
-		&models.Comment{},
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	if cfg.Driver == config.DriverSQLite && isMemory(cfg.DSN) {
+		// Every connection to :memory: opens a database of its own, so all
+		// queries have to share one connection
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	if err := db.Use(metrics.GormPlugin{}); err != nil {
+		return nil, fmt.Errorf("failed to register query metrics: %w", err)
+	}
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		return nil, fmt.Errorf("failed to register query tracing: %w", err)
 	}
 
 	return &DBConfig{DB: db}, nil
 }
+
+// Driver returns the name of the database driver, config.DriverPostgres or config.DriverSQLite
+func (d *DBConfig) Driver() string {
+	return d.DB.Dialector.Name()
+}
+
+// sqliteDSN enables foreign keys, which SQLite leaves off by default, and
+// waits for locks held by other connections instead of failing right away
+func sqliteDSN(dsn string) string {
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return dsn + separator + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+}
+
+// isMemory reports whether a SQLite DSN names an in-memory database
+func isMemory(dsn string) bool {
+	return dsn == ":memory:" || strings.Contains(dsn, "mode=memory")
+}
+
+// Close closes the underlying connection pool
+func (d *DBConfig) Close() error {
+	sqlDB, err := d.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
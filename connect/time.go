@@ -0,0 +1,65 @@
+package connect
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+// sqliteTimeLayouts are the formats SQLite drivers store timestamps in
+var sqliteTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	time.RFC3339Nano,
+}
+
+// NullTime is a nullable timestamp for the results of aggregates such as
+// MAX(created_at). Postgres returns those as timestamps, but SQLite only
+// knows the type of table columns and returns them as text.
+type NullTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+// Scan implements sql.Scanner
+func (t *NullTime) Scan(value interface{}) error {
+	t.Time, t.Valid = time.Time{}, false
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		t.Time, t.Valid = v, true
+		return nil
+	case []byte:
+		return t.parse(string(v))
+	case string:
+		return t.parse(v)
+	}
+	return fmt.Errorf("cannot scan %T into a timestamp", value)
+}
+
+// Value implements driver.Valuer
+func (t NullTime) Value() (driver.Value, error) {
+	if !t.Valid {
+		return nil, nil
+	}
+	return t.Time, nil
+}
+
+// Ptr returns the timestamp, or nil when it is NULL
+func (t NullTime) Ptr() *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func (t *NullTime) parse(value string) error {
+	for _, layout := range sqliteTimeLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			t.Time, t.Valid = parsed, true
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot parse %q as a timestamp", value)
+}
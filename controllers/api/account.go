@@ -0,0 +1,293 @@
+package api
+
+import (
+	"TechBlog/accounts"
+	"TechBlog/apierror"
+	"TechBlog/config"
+	"TechBlog/connect"
+	"TechBlog/mailer"
+	"TechBlog/models"
+	"TechBlog/utils"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// deletionRequestBody is the request body for starting an account deletion
+type deletionRequestBody struct {
+	Mode       string `json:"mode" binding:"required,oneof=transfer anonymize purge"`
+	TransferTo string `json:"transfer_to" binding:"required_if=Mode transfer"`
+}
+
+// RegisterAccountRoutes sets up routes for the logged-in user to delete their account
+func RegisterAccountRoutes(router *gin.RouterGroup, dbConfig *connect.DBConfig, cfg *config.Config) {
+	deletionRoutes := router.Group("/users/me/deletion")
+	{
+		deletionRoutes.GET("", func(c *gin.Context) {
+			handleGetAccountDeletion(c, dbConfig)
+		})
+		deletionRoutes.POST("", func(c *gin.Context) {
+			handleRequestAccountDeletion(c, dbConfig, cfg)
+		})
+		deletionRoutes.POST("/confirm", func(c *gin.Context) {
+			handleConfirmAccountDeletion(c, dbConfig, cfg)
+		})
+		deletionRoutes.DELETE("", func(c *gin.Context) {
+			handleCancelAccountDeletion(c, dbConfig)
+		})
+	}
+}
+
+// RegisterAdminAccountRoutes sets up routes for administrators to delete accounts
+func RegisterAdminAccountRoutes(router *gin.RouterGroup, dbConfig *connect.DBConfig, cfg *config.Config) {
+	router.GET("/deletions", func(c *gin.Context) {
+		handleAdminGetAccountDeletions(c, dbConfig)
+	})
+
+	router.POST("/users/:id/deletion", func(c *gin.Context) {
+		handleAdminDeleteAccount(c, dbConfig, cfg)
+	})
+
+	router.DELETE("/deletions/:deletionId", func(c *gin.Context) {
+		handleAdminCancelAccountDeletion(c, dbConfig)
+	})
+}
+
+// newDeletionRequest validates a deletion request body for user and builds the request record
+func newDeletionRequest(c *gin.Context, dbConfig *connect.DBConfig, reqBody deletionRequestBody, user models.User, requestedBy uint) (*models.AccountDeletion, bool) {
+	if _, err := accounts.PendingRequest(dbConfig.DB.WithContext(c.Request.Context()), user.ID); err == nil {
+		c.Error(apierror.Conflict("deletion_already_pending", "An account deletion is already pending for this user."))
+		return nil, false
+	}
+
+	request := &models.AccountDeletion{
+		UserID:        user.ID,
+		Username:      user.Username,
+		RequestedByID: requestedBy,
+		Mode:          reqBody.Mode,
+		Status:        models.DeletionPendingConfirmation,
+	}
+
+	if reqBody.Mode == models.DeletionModeTransfer {
+		target, err := accounts.ResolveTransferTarget(dbConfig.DB.WithContext(c.Request.Context()), reqBody.TransferTo, user.ID)
+		if err != nil {
+			c.Error(&apierror.Error{Status: http.StatusBadRequest, Code: "invalid_transfer_target", Message: "Posts cannot be transferred to this user.", Err: err})
+			return nil, false
+		}
+		request.TransferToID = &target.ID
+	}
+
+	return request, true
+}
+
+// handleGetAccountDeletion returns the logged-in user's pending deletion request
+func handleGetAccountDeletion(c *gin.Context, dbConfig *connect.DBConfig) {
+	userID, ok := utils.SessionUserID(c)
+	if !ok {
+		c.Error(apierror.Unauthorized())
+		return
+	}
+
+	request, err := accounts.PendingRequest(dbConfig.DB.WithContext(c.Request.Context()), userID)
+	if err != nil {
+		c.Error(apierror.NotFound("deletion_not_found", "No account deletion is pending."))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deletion": request})
+}
+
+// handleRequestAccountDeletion starts deleting the logged-in user's account.
+// The request only takes effect after it is confirmed through the emailed
+// link and the cooling-off period has passed.
+func handleRequestAccountDeletion(c *gin.Context, dbConfig *connect.DBConfig, cfg *config.Config) {
+	var reqBody struct {
+		deletionRequestBody
+		Password string `json:"password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.Error(apierror.Invalid(err))
+		return
+	}
+
+	user, ok := loadSessionUser(c, dbConfig)
+	if !ok {
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(reqBody.Password)); err != nil {
+		c.Error(apierror.New(http.StatusUnauthorized, "invalid_credentials", "Incorrect password, please try again"))
+		return
+	}
+
+	request, ok := newDeletionRequest(c, dbConfig, reqBody.deletionRequestBody, user, user.ID)
+	if !ok {
+		return
+	}
+
+	token, err := utils.NewToken()
+	if err != nil {
+		c.Error(apierror.Internal("Failed to generate confirmation token", err))
+		return
+	}
+	request.ConfirmationHash = utils.HashToken(token)
+
+	if err := dbConfig.DB.WithContext(c.Request.Context()).Create(request).Error; err != nil {
+		c.Error(apierror.FromDB(err, nil, "Failed to request account deletion"))
+		return
+	}
+
+	link := fmt.Sprintf("%s/account/delete?token=%s", cfg.AppURL, token)
+	body := fmt.Sprintf("Hi %s,\n\nWe received a request to delete your account. Confirm it by opening this link:\n\n%s\n\n"+
+		"Your account will be deleted %d days after you confirm. If you did not make this request, change your password.\n",
+		user.Username, link, cfg.Accounts.DeletionCoolingOffDays)
+	if err := mailer.Send(c.Request.Context(), user.Email, "Confirm your account deletion", body); err != nil {
+		utils.Logger(c).Warn("failed to send account deletion email", "user_id", user.ID, "error", err)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":  "Check your email to confirm the account deletion.",
+		"deletion": request,
+	})
+}
+
+// handleConfirmAccountDeletion confirms a deletion request with the emailed token and starts the cooling-off period
+func handleConfirmAccountDeletion(c *gin.Context, dbConfig *connect.DBConfig, cfg *config.Config) {
+	var reqBody struct {
+		Token string `json:"token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.Error(apierror.Invalid(err))
+		return
+	}
+
+	userID, ok := utils.SessionUserID(c)
+	if !ok {
+		c.Error(apierror.Unauthorized())
+		return
+	}
+
+	var request models.AccountDeletion
+	if err := dbConfig.DB.WithContext(c.Request.Context()).Where("user_id = ? AND status = ? AND confirmation_hash = ?",
+		userID, models.DeletionPendingConfirmation, utils.HashToken(reqBody.Token)).
+		First(&request).Error; err != nil {
+		c.Error(apierror.NotFound("invalid_token", "Invalid confirmation token"))
+		return
+	}
+
+	if err := accounts.Schedule(dbConfig.DB.WithContext(c.Request.Context()), &request, cfg.Accounts.DeletionCoolingOff()); err != nil {
+		c.Error(apierror.FromDB(err, nil, "Failed to confirm account deletion"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Account deletion confirmed. You can cancel it until it is carried out.",
+		"deletion": request,
+	})
+}
+
+// handleCancelAccountDeletion cancels the logged-in user's pending deletion request
+func handleCancelAccountDeletion(c *gin.Context, dbConfig *connect.DBConfig) {
+	userID, ok := utils.SessionUserID(c)
+	if !ok {
+		c.Error(apierror.Unauthorized())
+		return
+	}
+
+	request, err := accounts.PendingRequest(dbConfig.DB.WithContext(c.Request.Context()), userID)
+	if err != nil {
+		c.Error(apierror.NotFound("deletion_not_found", "No account deletion is pending."))
+		return
+	}
+
+	if err := dbConfig.DB.WithContext(c.Request.Context()).Model(request).Update("status", models.DeletionCancelled).Error; err != nil {
+		c.Error(apierror.FromDB(err, nil, "Failed to cancel account deletion"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deletion cancelled."})
+}
+
+// handleAdminGetAccountDeletions lists account deletion requests (admin only)
+func handleAdminGetAccountDeletions(c *gin.Context, dbConfig *connect.DBConfig) {
+	query := dbConfig.DB.WithContext(c.Request.Context()).Order("created_at DESC")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var requests []models.AccountDeletion
+	if err := query.Find(&requests).Error; err != nil {
+		c.Error(apierror.FromDB(err, nil, "Failed to retrieve account deletions"))
+		return
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+// handleAdminDeleteAccount schedules the deletion of any account (admin only).
+// The administrator confirms by repeating the username of the account.
+func handleAdminDeleteAccount(c *gin.Context, dbConfig *connect.DBConfig, cfg *config.Config) {
+	var reqBody struct {
+		deletionRequestBody
+		ConfirmUsername string `json:"confirm_username" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.Error(apierror.Invalid(err))
+		return
+	}
+
+	var user models.User
+	if err := dbConfig.DB.WithContext(c.Request.Context()).Where("id = ?", c.Param("id")).First(&user).Error; err != nil {
+		c.Error(apierror.NotFound("user_not_found", "User not found"))
+		return
+	}
+
+	if user.Placeholder {
+		c.Error(apierror.Conflict("placeholder_account", "The placeholder account holds all anonymized content and cannot be deleted."))
+		return
+	}
+
+	if reqBody.ConfirmUsername != user.Username {
+		c.Error(apierror.BadRequest("confirmation_mismatch", "confirm_username does not match the account being deleted."))
+		return
+	}
+
+	adminID, _ := utils.SessionUserID(c)
+	request, ok := newDeletionRequest(c, dbConfig, reqBody.deletionRequestBody, user, adminID)
+	if !ok {
+		return
+	}
+
+	if err := accounts.Schedule(dbConfig.DB.WithContext(c.Request.Context()), request, cfg.Accounts.DeletionCoolingOff()); err != nil {
+		c.Error(apierror.FromDB(err, nil, "Failed to schedule account deletion"))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":  "Account deletion scheduled.",
+		"deletion": request,
+	})
+}
+
+// handleAdminCancelAccountDeletion cancels a pending deletion request (admin only)
+func handleAdminCancelAccountDeletion(c *gin.Context, dbConfig *connect.DBConfig) {
+	result := dbConfig.DB.WithContext(c.Request.Context()).Model(&models.AccountDeletion{}).
+		Where("id = ? AND status IN ?", c.Param("deletionId"),
+			[]string{models.DeletionPendingConfirmation, models.DeletionScheduled}).
+		Update("status", models.DeletionCancelled)
+	if result.Error != nil {
+		c.Error(apierror.FromDB(result.Error, nil, "Failed to cancel account deletion"))
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.Error(apierror.NotFound("deletion_not_found", "No pending account deletion found with this ID."))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deletion cancelled."})
+}
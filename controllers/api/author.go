@@ -0,0 +1,159 @@
+package api
+
+import (
+	"TechBlog/apierror"
+	"TechBlog/config"
+	"TechBlog/connect"
+	"TechBlog/media"
+	"TechBlog/models"
+	"TechBlog/services"
+	"TechBlog/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authorProfile is the public view of a user shown on author pages. It
+// deliberately leaves out private fields such as the email address.
+type authorProfile struct {
+	ID          uint
+	Username    string
+	DisplayName string
+	Bio         string
+	Website     string
+	SocialLinks map[string]string
+	Avatars     map[string]string
+	PostCount   int64
+	LastPostAt  *time.Time
+	CreatedAt   time.Time
+}
+
+// authorRow is a row of the author directory query
+type authorRow struct {
+	ID          uint
+	Username    string
+	DisplayName string
+	Bio         string
+	Avatar      string
+	CreatedAt   time.Time
+	PostCount   int64
+	LastPostAt  connect.NullTime
+}
+
+// RegisterAuthorRoutes sets up the public author pages
+func RegisterAuthorRoutes(router *gin.RouterGroup, dbConfig *connect.DBConfig, cfg *config.Config, storage media.Storage) {
+	files := newMediaService(dbConfig, storage, cfg)
+
+	router.GET("/authors", func(c *gin.Context) {
+		handleGetAuthors(c, dbConfig)
+	})
+
+	router.GET("/authors/:username", func(c *gin.Context) {
+		handleGetAuthor(c, dbConfig, files)
+	})
+}
+
+// handleGetAuthors lists everyone who has published a post, most recently active first
+func handleGetAuthors(c *gin.Context, dbConfig *connect.DBConfig) {
+	page := parsePagination(c)
+
+	var total int64
+	if err := dbConfig.DB.WithContext(c.Request.Context()).Model(&models.User{}).
+		Where("EXISTS (SELECT 1 FROM posts WHERE posts.user_id = users.id AND posts.deleted_at IS NULL)").
+		Count(&total).Error; err != nil {
+		c.Error(apierror.FromDB(err, nil, "Failed to retrieve authors"))
+		return
+	}
+
+	var rows []authorRow
+	if err := dbConfig.DB.WithContext(c.Request.Context()).Model(&models.User{}).
+		Select("users.id, users.username, users.display_name, users.bio, users.avatar, users.created_at, " +
+			"COUNT(posts.id) AS post_count, MAX(posts.created_at) AS last_post_at").
+		Joins("JOIN posts ON posts.user_id = users.id AND posts.deleted_at IS NULL").
+		Group("users.id").
+		Order("last_post_at DESC, post_count DESC, users.username").
+		Limit(page.PerPage).
+		Offset(page.Offset()).
+		Scan(&rows).Error; err != nil {
+		c.Error(apierror.FromDB(err, nil, "Failed to retrieve authors"))
+		return
+	}
+
+	authors := make([]authorProfile, 0, len(rows))
+	for _, row := range rows {
+		authors = append(authors, authorProfile{
+			ID:          row.ID,
+			Username:    row.Username,
+			DisplayName: row.DisplayName,
+			Bio:         row.Bio,
+			Avatars:     utils.AvatarURLs(row.Avatar),
+			PostCount:   row.PostCount,
+			LastPostAt:  row.LastPostAt.Ptr(),
+			CreatedAt:   row.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, page.response(gin.H{"authors": authors}, total))
+}
+
+// handleGetAuthor returns an author's public profile with a page of summaries
+// of their posts
+func handleGetAuthor(c *gin.Context, dbConfig *connect.DBConfig, files *services.MediaService) {
+	page := parsePagination(c)
+
+	var user models.User
+	if err := dbConfig.DB.WithContext(c.Request.Context()).Where("username = ?", c.Param("username")).First(&user).Error; err != nil {
+		c.Error(apierror.NotFound("author_not_found", "Author not found"))
+		return
+	}
+
+	var stats struct {
+		PostCount  int64
+		LastPostAt connect.NullTime
+	}
+	if err := dbConfig.DB.WithContext(c.Request.Context()).Model(&models.Post{}).
+		Select("COUNT(*) AS post_count, MAX(created_at) AS last_post_at").
+		Where("user_id = ?", user.ID).
+		Scan(&stats).Error; err != nil {
+		c.Error(apierror.FromDB(err, nil, "Failed to retrieve author posts"))
+		return
+	}
+
+	var posts []models.Post
+	if err := dbConfig.DB.WithContext(c.Request.Context()).
+		Where("user_id = ?", user.ID).
+		Order("created_at DESC").
+		Limit(page.PerPage).
+		Offset(page.Offset()).
+		Find(&posts).Error; err != nil {
+		c.Error(apierror.FromDB(err, nil, "Failed to retrieve author posts"))
+		return
+	}
+	for i := range posts {
+		posts[i].User = user
+	}
+	summaries, err := summarizePosts(c.Request.Context(), posts, files, apiPrefix(c, "/authors/:username"))
+	if err != nil {
+		c.Error(apierror.FromDB(err, nil, "Failed to retrieve author posts"))
+		return
+	}
+
+	author := authorProfile{
+		ID:          user.ID,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		Website:     user.Website,
+		SocialLinks: user.SocialLinks,
+		Avatars:     utils.AvatarURLs(user.Avatar),
+		PostCount:   stats.PostCount,
+		LastPostAt:  stats.LastPostAt.Ptr(),
+		CreatedAt:   user.CreatedAt,
+	}
+
+	c.JSON(http.StatusOK, page.response(gin.H{
+		"author": author,
+		"posts":  summaries,
+	}, stats.PostCount))
+}
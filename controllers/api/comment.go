@@ -1,32 +1,40 @@
 package api
 
 import (
+	"TechBlog/apierror"
 	"TechBlog/connect"
-	"TechBlog/models"
+	"TechBlog/services"
+	"TechBlog/utils"
 	"net/http"
 
-	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 )
 
 // RegisterCommentRoutes sets up routes for comments
 func RegisterCommentRoutes(router *gin.RouterGroup, dbConfig *connect.DBConfig) {
+	comments := newCommentService(dbConfig)
+
 	commentRoutes := router.Group("/comments")
 	{
 		commentRoutes.POST("/", func(c *gin.Context) {
-			handleCreateComment(c, dbConfig)
+			handleCreateComment(c, comments)
 		})
 		commentRoutes.PUT("/:commentId", func(c *gin.Context) {
-			handleUpdateComment(c, dbConfig)
+			handleUpdateComment(c, comments)
 		})
 		commentRoutes.DELETE("/:commentId", func(c *gin.Context) {
-			handleDeleteComment(c, dbConfig)
+			handleDeleteComment(c, comments)
 		})
 	}
 }
 
+// commentIDParam parses the comment ID from the URL
+func commentIDParam(c *gin.Context) (uint, bool) {
+	return idParam(c, "commentId", apierror.NotFound("comment_not_found", "Comment not found"))
+}
+
 // handleCreateComment handles creating a comment
-func handleCreateComment(c *gin.Context, dbConfig *connect.DBConfig) {
+func handleCreateComment(c *gin.Context, comments *services.CommentService) {
 	// Parse request body
 	var reqBody struct {
 		Body   string `json:"body" binding:"required"`
@@ -34,33 +42,20 @@ func handleCreateComment(c *gin.Context, dbConfig *connect.DBConfig) {
 	}
 
 	if err := c.ShouldBindJSON(&reqBody); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Comment body and associated post ID are required.", "error": err.Error()})
+		c.Error(apierror.Invalid(err))
 		return
 	}
 
 	// Retrieve the logged-in user's ID from the session
-	session := sessions.Default(c)
-	userID := session.Get("user_id")
-	if userID == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized. Please log in."})
-		return
-	}
-
-	userIDUint, ok := userID.(uint)
+	userID, ok := utils.SessionUserID(c)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Invalid user ID in session."})
+		c.Error(apierror.Unauthorized())
 		return
 	}
 
-	// Create the new comment
-	comment := models.Comment{
-		Body:   reqBody.Body,
-		PostID: reqBody.PostID,
-		UserID: userIDUint,
-	}
-
-	if err := dbConfig.DB.Create(&comment).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create comment.", "error": err.Error()})
+	comment, err := comments.Create(c.Request.Context(), userID, reqBody.PostID, reqBody.Body)
+	if err != nil {
+		c.Error(serviceError(err, "Failed to create comment."))
 		return
 	}
 
@@ -71,35 +66,22 @@ func handleCreateComment(c *gin.Context, dbConfig *connect.DBConfig) {
 }
 
 // handleDeleteComment handles deleting a comment by its ID
-func handleDeleteComment(c *gin.Context, dbConfig *connect.DBConfig) {
-	// Extract comment ID from the URL
-	commentID := c.Param("commentId")
-
+func handleDeleteComment(c *gin.Context, comments *services.CommentService) {
 	// Retrieve the logged-in user's ID from the session
-	session := sessions.Default(c)
-	userID := session.Get("user_id")
-	if userID == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized. Please log in."})
-		return
-	}
-
-	// Convert userID to uint
-	userIDUint, ok := userID.(uint)
+	userID, ok := utils.SessionUserID(c)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Invalid user ID in session."})
+		c.Error(apierror.Unauthorized())
 		return
 	}
 
-	// Check if the comment exists and belongs to the user
-	var comment models.Comment
-	if err := dbConfig.DB.Where("id = ? AND user_id = ?", commentID, userIDUint).First(&comment).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"message": "Comment not found or you are not authorized to delete this comment."})
+	commentID, ok := commentIDParam(c)
+	if !ok {
 		return
 	}
 
-	// Delete the comment
-	if err := dbConfig.DB.Delete(&comment).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to delete comment.", "error": err.Error()})
+	// Only the author of the comment may delete it
+	if err := comments.Delete(c.Request.Context(), commentID, userID); err != nil {
+		c.Error(serviceError(err, "Failed to delete comment."))
 		return
 	}
 
@@ -107,21 +89,17 @@ func handleDeleteComment(c *gin.Context, dbConfig *connect.DBConfig) {
 	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully."})
 }
 
-func handleUpdateComment(c *gin.Context, dbConfig *connect.DBConfig) {
-	// Extract comment ID from the URL
-	commentID := c.Param("commentId")
-
+// handleUpdateComment handles editing the body of a comment
+func handleUpdateComment(c *gin.Context, comments *services.CommentService) {
 	// Retrieve the logged-in user's ID from the session
-	session := sessions.Default(c)
-	userID := session.Get("user_id")
-	if userID == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized. Please log in."})
+	userID, ok := utils.SessionUserID(c)
+	if !ok {
+		c.Error(apierror.Unauthorized())
 		return
 	}
 
-	userIDUint, ok := userID.(uint)
+	commentID, ok := commentIDParam(c)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Invalid user ID in session."})
 		return
 	}
 
@@ -130,21 +108,14 @@ func handleUpdateComment(c *gin.Context, dbConfig *connect.DBConfig) {
 	}
 
 	if err := c.ShouldBindJSON(&reqBody); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Comment body is required.", "error": err.Error()})
-		return
-	}
-
-	// Check if the comment exists and belongs to the user
-	var comment models.Comment
-	if err := dbConfig.DB.Where("id = ? AND user_id = ?", commentID, userIDUint).First(&comment).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"message": "Comment not found or you are not authorized to update this comment."})
+		c.Error(apierror.Invalid(err))
 		return
 	}
 
-	// Update the comment
-	comment.Body = reqBody.Body
-	if err := dbConfig.DB.Save(&comment).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update comment.", "error": err.Error()})
+	// Only the author of the comment may update it
+	comment, err := comments.Update(c.Request.Context(), commentID, userID, reqBody.Body)
+	if err != nil {
+		c.Error(serviceError(err, "Failed to update comment."))
 		return
 	}
 
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"TechBlog/apierror"
+	"TechBlog/metrics"
+	"TechBlog/utils"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCSPReportBytes caps the size of a violation report request
+const maxCSPReportBytes = 64 << 10
+
+// directivePattern matches the directive names counted in the metrics, so
+// that arbitrary report contents cannot create new series
+var directivePattern = regexp.MustCompile(`^[a-z-]{1,32}$`)
+
+// cspViolation is a Content-Security-Policy violation, in either report format
+type cspViolation struct {
+	DocumentURL string
+	BlockedURL  string
+	Directive   string
+	Disposition string
+	SourceFile  string
+	LineNumber  int
+}
+
+// RegisterCSPReportRoutes sets up the endpoint that collects the
+// Content-Security-Policy violations reported by browsers
+func RegisterCSPReportRoutes(router *gin.RouterGroup) {
+	router.POST("/csp-report", handleCSPReport)
+}
+
+// handleCSPReport logs and counts the violations of a report sent through
+// report-uri (application/csp-report) or report-to (application/reports+json)
+func handleCSPReport(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxCSPReportBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.Error(apierror.New(http.StatusRequestEntityTooLarge, "report_too_large", "The report is too large."))
+			return
+		}
+		c.Error(apierror.BadRequest("invalid_report", "The report could not be read."))
+		return
+	}
+
+	violations, err := parseCSPReport(body)
+	if err != nil {
+		c.Error(apierror.BadRequest("invalid_report", "The report is not a CSP violation report."))
+		return
+	}
+
+	for _, v := range violations {
+		directive := v.Directive
+		if !directivePattern.MatchString(directive) {
+			directive = "other"
+		}
+		metrics.CSPViolations.WithLabelValues(directive).Inc()
+		utils.Logger(c).Warn("content security policy violation",
+			"directive", v.Directive,
+			"blocked_url", v.BlockedURL,
+			"document_url", v.DocumentURL,
+			"disposition", v.Disposition,
+			"source_file", v.SourceFile,
+			"line_number", v.LineNumber,
+		)
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// parseCSPReport decodes the violations of a report in the report-uri format,
+// a single {"csp-report": {...}} object, or the Reporting API format, an
+// array of reports of which only the csp-violation ones are kept
+func parseCSPReport(body []byte) ([]cspViolation, error) {
+	if trimmed := strings.TrimSpace(string(body)); strings.HasPrefix(trimmed, "[") {
+		var reports []struct {
+			Type string `json:"type"`
+			Body struct {
+				DocumentURL        string `json:"documentURL"`
+				BlockedURL         string `json:"blockedURL"`
+				EffectiveDirective string `json:"effectiveDirective"`
+				Disposition        string `json:"disposition"`
+				SourceFile         string `json:"sourceFile"`
+				LineNumber         int    `json:"lineNumber"`
+			} `json:"body"`
+		}
+		if err := json.Unmarshal(body, &reports); err != nil {
+			return nil, err
+		}
+		var violations []cspViolation
+		for _, r := range reports {
+			if r.Type != "csp-violation" {
+				continue
+			}
+			violations = append(violations, cspViolation{
+				DocumentURL: r.Body.DocumentURL,
+				BlockedURL:  r.Body.BlockedURL,
+				Directive:   r.Body.EffectiveDirective,
+				Disposition: r.Body.Disposition,
+				SourceFile:  r.Body.SourceFile,
+				LineNumber:  r.Body.LineNumber,
+			})
+		}
+		return violations, nil
+	}
+
+	var report struct {
+		Report *struct {
+			DocumentURI        string `json:"document-uri"`
+			BlockedURI         string `json:"blocked-uri"`
+			EffectiveDirective string `json:"effective-directive"`
+			ViolatedDirective  string `json:"violated-directive"`
+			Disposition        string `json:"disposition"`
+			SourceFile         string `json:"source-file"`
+			LineNumber         int    `json:"line-number"`
+		} `json:"csp-report"`
+	}
+	if err := json.Unmarshal(body, &report); err != nil {
+		return nil, err
+	}
+	if report.Report == nil {
+		return nil, errors.New("no csp-report member")
+	}
+	directive := report.Report.EffectiveDirective
+	if directive == "" {
+		// Older browsers only send the violated directive with its sources
+		directive, _, _ = strings.Cut(report.Report.ViolatedDirective, " ")
+	}
+	return []cspViolation{{
+		DocumentURL: report.Report.DocumentURI,
+		BlockedURL:  report.Report.BlockedURI,
+		Directive:   directive,
+		Disposition: report.Report.Disposition,
+		SourceFile:  report.Report.SourceFile,
+		LineNumber:  report.Report.LineNumber,
+	}}, nil
+}
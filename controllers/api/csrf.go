@@ -0,0 +1,32 @@
+package api
+
+import (
+	"TechBlog/apierror"
+	"TechBlog/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterCSRFRoutes sets up the endpoint that hands the CSRF token to the frontend
+func RegisterCSRFRoutes(router *gin.RouterGroup) {
+	router.GET("/csrf", handleCSRF)
+}
+
+// handleCSRF returns the CSRF token of the session, which state-changing
+// requests must send in the X-CSRF-Token header
+func handleCSRF(c *gin.Context) {
+	token, err := utils.CSRFToken(c)
+	if err != nil {
+		c.Error(apierror.Internal("Failed to create a CSRF token", err))
+		return
+	}
+	_, authenticated := utils.SessionUserID(c)
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"csrf_token":    token,
+		"header":        utils.CSRFHeader,
+		"authenticated": authenticated,
+	})
+}
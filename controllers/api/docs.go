@@ -0,0 +1,45 @@
+package api
+
+import (
+	"TechBlog/apierror"
+	"TechBlog/openapi"
+	"TechBlog/utils"
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// docsCSP is the Content-Security-Policy of the documentation page, whose
+// inline style and script tags carry the nonce of the request
+const docsCSP = "default-src 'none'; script-src 'self' 'nonce-" + utils.NoncePlaceholder + "'; style-src 'nonce-" + utils.NoncePlaceholder + "'; connect-src 'self'; img-src 'self' data:; base-uri 'none'; form-action 'none'"
+
+// RegisterDocsRoutes serves the OpenAPI document and the page that renders
+// it. security are the headers of the API, which the page adjusts.
+func RegisterDocsRoutes(router *gin.RouterGroup, security utils.SecurityHeaders) {
+	router.GET("/openapi.json", handleOpenAPI)
+	router.GET("/docs", utils.WithSecurityHeaders(security.WithCSP(docsCSP)), handleDocs)
+	router.GET("/docs/docs.js", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/javascript; charset=utf-8", openapi.DocsJS)
+	})
+}
+
+// handleOpenAPI returns the OpenAPI document
+func handleOpenAPI(c *gin.Context) {
+	spec, err := openapi.JSON()
+	if err != nil {
+		c.Error(apierror.Internal("Failed to load the API description", err))
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", spec)
+}
+
+// handleDocs renders the documentation page with the CSP nonce of the request
+func handleDocs(c *gin.Context) {
+	var page bytes.Buffer
+	if err := openapi.RenderDocs(&page, utils.CSPNonce(c)); err != nil {
+		c.Error(apierror.Internal("Failed to render the API docs", err))
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+}
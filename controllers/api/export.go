@@ -0,0 +1,130 @@
+package api
+
+import (
+	"TechBlog/apierror"
+	"TechBlog/connect"
+	"TechBlog/models"
+	"TechBlog/utils"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RegisterExportRoutes sets up routes for the logged-in user to export their data
+func RegisterExportRoutes(router *gin.RouterGroup, dbConfig *connect.DBConfig) {
+	router.POST("/users/me/export", func(c *gin.Context) {
+		userID, _ := utils.SessionUserID(c)
+		handleRequestExport(c, dbConfig, userID)
+	})
+
+	router.GET("/users/me/exports", func(c *gin.Context) {
+		userID, _ := utils.SessionUserID(c)
+		handleGetExports(c, dbConfig, func(db *gorm.DB) *gorm.DB {
+			return db.Where("user_id = ?", userID)
+		})
+	})
+
+	router.GET("/users/me/exports/:exportId/download", func(c *gin.Context) {
+		userID, _ := utils.SessionUserID(c)
+		handleDownloadExport(c, dbConfig, func(db *gorm.DB) *gorm.DB {
+			return db.Where("user_id = ?", userID)
+		})
+	})
+}
+
+// RegisterAdminExportRoutes sets up routes for administrators to export any user's data
+func RegisterAdminExportRoutes(router *gin.RouterGroup, dbConfig *connect.DBConfig) {
+	router.POST("/users/:id/export", func(c *gin.Context) {
+		var user models.User
+		if err := dbConfig.DB.WithContext(c.Request.Context()).Unscoped().Where("id = ?", c.Param("id")).First(&user).Error; err != nil {
+			c.Error(apierror.NotFound("user_not_found", "User not found"))
+			return
+		}
+		handleRequestExport(c, dbConfig, user.ID)
+	})
+
+	router.GET("/exports", func(c *gin.Context) {
+		handleGetExports(c, dbConfig, func(db *gorm.DB) *gorm.DB {
+			if userID := c.Query("user_id"); userID != "" {
+				return db.Where("user_id = ?", userID)
+			}
+			return db
+		})
+	})
+
+	router.GET("/exports/:exportId/download", func(c *gin.Context) {
+		handleDownloadExport(c, dbConfig, func(db *gorm.DB) *gorm.DB {
+			return db
+		})
+	})
+}
+
+// handleRequestExport queues a data export for userID. The archive is built in the background.
+func handleRequestExport(c *gin.Context, dbConfig *connect.DBConfig, userID uint) {
+	requestedBy, ok := utils.SessionUserID(c)
+	if !ok {
+		c.Error(apierror.Unauthorized())
+		return
+	}
+
+	var count int64
+	if err := dbConfig.DB.WithContext(c.Request.Context()).Model(&models.DataExport{}).
+		Where("user_id = ? AND status IN ?", userID, []string{models.ExportPending, models.ExportProcessing}).
+		Count(&count).Error; err != nil {
+		c.Error(apierror.FromDB(err, nil, "Failed to request data export"))
+		return
+	}
+	if count > 0 {
+		c.Error(apierror.Conflict("export_in_progress", "A data export is already being prepared."))
+		return
+	}
+
+	export := models.DataExport{
+		UserID:        userID,
+		RequestedByID: requestedBy,
+		Status:        models.ExportPending,
+	}
+	if err := dbConfig.DB.WithContext(c.Request.Context()).Create(&export).Error; err != nil {
+		c.Error(apierror.FromDB(err, nil, "Failed to request data export"))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Data export requested. It will be available to download once it is ready.",
+		"export":  export,
+	})
+}
+
+// handleGetExports lists data exports matching scope, newest first
+func handleGetExports(c *gin.Context, dbConfig *connect.DBConfig, scope func(*gorm.DB) *gorm.DB) {
+	var exports []models.DataExport
+	if err := dbConfig.DB.WithContext(c.Request.Context()).Scopes(scope).Order("created_at DESC").Find(&exports).Error; err != nil {
+		c.Error(apierror.FromDB(err, nil, "Failed to retrieve data exports"))
+		return
+	}
+
+	c.JSON(http.StatusOK, exports)
+}
+
+// handleDownloadExport sends a finished export archive while its download window is open
+func handleDownloadExport(c *gin.Context, dbConfig *connect.DBConfig, scope func(*gorm.DB) *gorm.DB) {
+	var export models.DataExport
+	if err := dbConfig.DB.WithContext(c.Request.Context()).Scopes(scope).Where("id = ?", c.Param("exportId")).First(&export).Error; err != nil {
+		c.Error(apierror.NotFound("export_not_found", "Data export not found"))
+		return
+	}
+
+	switch {
+	case export.Status == models.ExportPending || export.Status == models.ExportProcessing:
+		c.Error(apierror.Conflict("export_not_ready", "This data export is still being prepared.").With("status", export.Status))
+		return
+	case export.Status != models.ExportReady || export.ExpiresAt == nil || time.Now().After(*export.ExpiresAt):
+		c.Error(apierror.New(http.StatusGone, "export_expired", "This data export is no longer available for download.").With("status", export.Status))
+		return
+	}
+
+	c.FileAttachment(export.FilePath, fmt.Sprintf("techblog-export-%d.zip", export.ID))
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"TechBlog/connect"
+	"TechBlog/migrations"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readinessTimeout bounds how long the readiness checks may take
+const readinessTimeout = 2 * time.Second
+
+// RegisterHealthRoutes sets up the liveness and readiness probes for load balancers
+func RegisterHealthRoutes(router *gin.RouterGroup, dbConfig *connect.DBConfig) {
+	router.GET("/healthz", handleHealthz)
+
+	router.GET("/readyz", func(c *gin.Context) {
+		handleReadyz(c, dbConfig)
+	})
+}
+
+// handleHealthz reports that the process is running
+func handleHealthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// handleReadyz reports whether the server can handle requests: the database
+// must answer and every migration must be applied
+func handleReadyz(c *gin.Context, dbConfig *connect.DBConfig) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := gin.H{"database": "ok", "migrations": "ok"}
+	ready := true
+
+	sqlDB, err := dbConfig.DB.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		checks["database"] = err.Error()
+		checks["migrations"] = "unknown"
+		ready = false
+	} else if migrator, err := migrations.New(sqlDB, dbConfig.Driver()); err != nil {
+		checks["migrations"] = err.Error()
+		ready = false
+	} else if pending, err := migrator.Pending(ctx); errors.Is(err, migrations.ErrNotInitialized) {
+		checks["migrations"] = "not initialized"
+		ready = false
+	} else if err != nil {
+		checks["migrations"] = err.Error()
+		ready = false
+	} else if pending > 0 {
+		checks["migrations"] = fmt.Sprintf("%d pending", pending)
+		ready = false
+	}
+
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "checks": checks})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready", "checks": checks})
+}
@@ -0,0 +1,259 @@
+package api
+
+import (
+	"TechBlog/apierror"
+	"TechBlog/config"
+	"TechBlog/connect"
+	"TechBlog/media"
+	"TechBlog/models"
+	"TechBlog/repository"
+	"TechBlog/services"
+	"TechBlog/utils"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// multipartOverhead is allowed on top of the file size for the rest of an
+// upload request, such as the part headers
+const multipartOverhead = 64 << 10
+
+// newMediaService builds a MediaService on the GORM repository and storage
+func newMediaService(dbConfig *connect.DBConfig, storage media.Storage, cfg *config.Config) *services.MediaService {
+	return services.NewMediaService(repository.NewMediaRepository(dbConfig.DB), storage, cfg.Media)
+}
+
+// RegisterMediaRoutes sets up routes for the logged-in user to upload and manage files
+func RegisterMediaRoutes(router *gin.RouterGroup, dbConfig *connect.DBConfig, cfg *config.Config, storage media.Storage) {
+	files := newMediaService(dbConfig, storage, cfg)
+
+	mediaRoutes := router.Group("/media")
+	{
+		mediaRoutes.POST("", func(c *gin.Context) {
+			handleUploadMedia(c, files, cfg)
+		})
+		mediaRoutes.GET("", func(c *gin.Context) {
+			handleListMedia(c, files)
+		})
+		mediaRoutes.GET("/:mediaId", func(c *gin.Context) {
+			handleGetMedia(c, files)
+		})
+		mediaRoutes.DELETE("/:mediaId", func(c *gin.Context) {
+			handleDeleteMedia(c, files)
+		})
+	}
+}
+
+// RegisterPublicMediaRoutes sets up the route that serves uploaded files to anyone
+func RegisterPublicMediaRoutes(router *gin.RouterGroup, dbConfig *connect.DBConfig, cfg *config.Config, storage media.Storage) {
+	files := newMediaService(dbConfig, storage, cfg)
+
+	router.GET("/media/:mediaId/file", func(c *gin.Context) {
+		handleMediaFile(c, files)
+	})
+}
+
+// mediaIDParam parses the media ID from the URL
+func mediaIDParam(c *gin.Context) (uint, bool) {
+	return idParam(c, "mediaId", apierror.NotFound("media_not_found", "Media not found"))
+}
+
+// mediaResponse is a file record with the URL to embed it with
+type mediaResponse struct {
+	models.Media
+	// URL is the permanent public URL of the file or, when the storage is
+	// private, the API route that redirects to a signed URL
+	URL string
+}
+
+// apiPrefix returns the prefix of the API version that is serving c, such as
+// /api/v1, from the route of c below it, so that links in a response stay on
+// the version it was requested from
+func apiPrefix(c *gin.Context, route string) string {
+	path := c.FullPath()
+	return path[:strings.LastIndex(path, route)]
+}
+
+// newMediaResponse adds the embed URL to m
+func newMediaResponse(c *gin.Context, files *services.MediaService, m models.Media) mediaResponse {
+	url := files.URL(m)
+	if url == "" {
+		url = fmt.Sprintf("%s/media/%d/file", apiPrefix(c, "/media"), m.ID)
+	}
+	return mediaResponse{Media: m, URL: url}
+}
+
+// handleUploadMedia stores a file sent as the file field of a multipart form
+func handleUploadMedia(c *gin.Context, files *services.MediaService, cfg *config.Config) {
+	userID, ok := utils.SessionUserID(c)
+	if !ok {
+		c.Error(apierror.Unauthorized())
+		return
+	}
+
+	maxSize := cfg.Media.MaxUploadBytes()
+	tooLarge := apierror.New(http.StatusRequestEntityTooLarge, "file_too_large",
+		fmt.Sprintf("Files may be at most %d MB.", cfg.Media.MaxUploadMB)).With("max_bytes", maxSize)
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+multipartOverhead)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytes *http.MaxBytesError
+		if errors.As(err, &maxBytes) {
+			c.Error(tooLarge)
+			return
+		}
+		c.Error(&apierror.Error{Status: http.StatusBadRequest, Code: "file_required", Message: "A file is required in the file field of a multipart form", Err: err})
+		return
+	}
+	if fileHeader.Size > maxSize {
+		c.Error(tooLarge)
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.Error(&apierror.Error{Status: http.StatusBadRequest, Code: "file_required", Message: "Failed to read the file", Err: err})
+		return
+	}
+	defer file.Close()
+
+	m, err := files.Upload(c.Request.Context(), userID, services.Upload{
+		Filename: fileHeader.Filename,
+		File:     file,
+		Size:     fileHeader.Size,
+	})
+	if err != nil {
+		c.Error(serviceError(err, "Failed to store the file"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"media":   newMediaResponse(c, files, m),
+		"message": "File uploaded successfully",
+	})
+}
+
+// handleListMedia returns a page of the logged-in user's files, newest first
+func handleListMedia(c *gin.Context, files *services.MediaService) {
+	userID, ok := utils.SessionUserID(c)
+	if !ok {
+		c.Error(apierror.Unauthorized())
+		return
+	}
+
+	page := parsePagination(c)
+	list, total, err := files.ListByUser(c.Request.Context(), userID, page.Offset(), page.PerPage)
+	if err != nil {
+		c.Error(serviceError(err, "Failed to retrieve media"))
+		return
+	}
+
+	responses := make([]mediaResponse, len(list))
+	for i, m := range list {
+		responses[i] = newMediaResponse(c, files, m)
+	}
+	c.JSON(http.StatusOK, page.response(gin.H{"media": responses}, total))
+}
+
+// handleGetMedia returns one of the logged-in user's files
+func handleGetMedia(c *gin.Context, files *services.MediaService) {
+	userID, ok := utils.SessionUserID(c)
+	if !ok {
+		c.Error(apierror.Unauthorized())
+		return
+	}
+	mediaID, ok := mediaIDParam(c)
+	if !ok {
+		return
+	}
+
+	m, err := files.GetOwned(c.Request.Context(), mediaID, userID)
+	if err != nil {
+		c.Error(serviceError(err, "Failed to retrieve media"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"media": newMediaResponse(c, files, m)})
+}
+
+// handleDeleteMedia removes one of the logged-in user's files. Posts that
+// embed it show a broken image afterwards.
+func handleDeleteMedia(c *gin.Context, files *services.MediaService) {
+	userID, ok := utils.SessionUserID(c)
+	if !ok {
+		c.Error(apierror.Unauthorized())
+		return
+	}
+	mediaID, ok := mediaIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := files.Delete(c.Request.Context(), mediaID, userID); err != nil {
+		c.Error(serviceError(err, "Failed to delete media"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Media deleted successfully"})
+}
+
+// handleMediaFile redirects to the file of a media record: to its public URL
+// or to a freshly signed one. The w, h and fmt parameters select a resized,
+// cropped or converted variant of an image instead. Redirects to signed URLs
+// are cached for less than the signature stays valid.
+func handleMediaFile(c *gin.Context, files *services.MediaService) {
+	mediaID, ok := mediaIDParam(c)
+	if !ok {
+		return
+	}
+	transform, ok := transformParams(c)
+	if !ok {
+		return
+	}
+
+	m, err := files.Get(c.Request.Context(), mediaID)
+	if err != nil {
+		c.Error(serviceError(err, "Failed to retrieve media"))
+		return
+	}
+	var url string
+	if transform == (media.Transform{}) {
+		url, err = files.FileURL(c.Request.Context(), m)
+	} else {
+		url, err = files.VariantURL(c.Request.Context(), m, transform)
+	}
+	if err != nil {
+		c.Error(serviceError(err, "Failed to prepare the media file"))
+		return
+	}
+
+	if files.URL(m) != "" {
+		c.Header("Cache-Control", "public, max-age=86400")
+	} else {
+		c.Header("Cache-Control", "private, max-age="+strconv.Itoa(int(files.SignedURLTTL().Seconds()/2)))
+	}
+	c.Redirect(http.StatusFound, url)
+}
+
+// transformParams parses the variant requested in the query. The service
+// checks the values against the allowlists.
+func transformParams(c *gin.Context) (media.Transform, bool) {
+	var t media.Transform
+	for param, dst := range map[string]*int{"w": &t.Width, "h": &t.Height} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			c.Error(apierror.InvalidField(param, "invalid_size", "must be a positive whole number"))
+			return media.Transform{}, false
+		}
+		*dst = n
+	}
+	t.Format = strings.ToLower(c.Query("fmt"))
+	return t, true
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"TechBlog/apierror"
+	"TechBlog/config"
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// RegisterMetricsRoutes exposes the Prometheus metrics when they are enabled
+func RegisterMetricsRoutes(router *gin.RouterGroup, cfg *config.Config) {
+	if !cfg.Metrics.Enabled {
+		return
+	}
+
+	handler := gin.WrapH(promhttp.Handler())
+	router.GET("/metrics", func(c *gin.Context) {
+		handleMetrics(c, cfg, handler)
+	})
+}
+
+// handleMetrics serves the metrics, requiring the configured bearer token if there is one
+func handleMetrics(c *gin.Context, cfg *config.Config, handler gin.HandlerFunc) {
+	if cfg.Metrics.Token != "" {
+		expected := "Bearer " + cfg.Metrics.Token
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte(expected)) != 1 {
+			c.Error(apierror.New(http.StatusUnauthorized, "invalid_metrics_token", "A valid metrics token is required."))
+			return
+		}
+	}
+
+	handler(c)
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPerPage = 10
+	maxPerPage     = 50
+	// maxPage keeps the offset of the last page far from overflowing
+	maxPage = 10000
+)
+
+// pagination holds the page requested through the page and per_page query parameters
+type pagination struct {
+	Page    int
+	PerPage int
+}
+
+// parsePagination reads page and per_page from the query string, falling back
+// to sane defaults for missing or out-of-range values
+func parsePagination(c *gin.Context) pagination {
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	if page > maxPage {
+		page = maxPage
+	}
+
+	perPage, err := strconv.Atoi(c.Query("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = defaultPerPage
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+
+	return pagination{Page: page, PerPage: perPage}
+}
+
+// Offset returns the number of rows to skip for the requested page
+func (p pagination) Offset() int {
+	return (p.Page - 1) * p.PerPage
+}
+
+// response adds the pagination metadata to a response body
+func (p pagination) response(body gin.H, total int64) gin.H {
+	body["page"] = p.Page
+	body["per_page"] = p.PerPage
+	body["total"] = total
+	return body
+}
@@ -1,126 +1,252 @@
 package api
 
 import (
+	"TechBlog/apierror"
+	"TechBlog/config"
 	"TechBlog/connect"
+	"TechBlog/markdown"
+	"TechBlog/media"
 	"TechBlog/models"
-	"github.com/gin-contrib/sessions"
+	"TechBlog/services"
+	"TechBlog/utils"
+	"context"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"time"
 )
 
-func RegisterPublicPostRoutes(router *gin.RouterGroup, dbConfig *connect.DBConfig) {
+func RegisterPublicPostRoutes(router *gin.RouterGroup, dbConfig *connect.DBConfig, cfg *config.Config, storage media.Storage) {
+	posts := newPostService(dbConfig)
+	files := newMediaService(dbConfig, storage, cfg)
+	series := newSeriesService(dbConfig)
+
 	router.GET("/posts", func(c *gin.Context) {
-		handleGetAllPosts(c, dbConfig)
+		handleGetAllPosts(c, posts, files)
 	})
 
 	router.GET("/posts/:postId", func(c *gin.Context) {
-		handleGetPostByID(c, dbConfig)
+		handleGetPostByID(c, posts, files, series)
 	})
 }
 
 // RegisterPostRoutes sets up routes for post-related actions
-func RegisterPostRoutes(router *gin.RouterGroup, dbConfig *connect.DBConfig) {
+func RegisterPostRoutes(router *gin.RouterGroup, dbConfig *connect.DBConfig, cfg *config.Config, storage media.Storage) {
+	posts := newPostService(dbConfig)
+	files := newMediaService(dbConfig, storage, cfg)
+
 	postRoutes := router.Group("/posts")
 	{
 		postRoutes.GET("/myposts", func(c *gin.Context) {
-			handleGetMyPosts(c, dbConfig)
+			handleGetMyPosts(c, posts, files)
 		})
 
 		postRoutes.POST("/", func(c *gin.Context) {
-			handleCreatePost(c, dbConfig)
+			handleCreatePost(c, posts)
 		})
 
 		postRoutes.PUT("/:postId", func(c *gin.Context) {
-			handleUpdatePost(c, dbConfig)
+			handleUpdatePost(c, posts)
 		})
 
 		postRoutes.DELETE("/:postId", func(c *gin.Context) {
-			handleDeletePost(c, dbConfig)
+			handleDeletePost(c, posts)
 		})
 	}
 }
 
-// handleGetAllPosts retrieves all posts
-func handleGetAllPosts(c *gin.Context, dbConfig *connect.DBConfig) {
-	var posts []models.Post
-	if err := dbConfig.DB.
-		Preload("User").
-		Preload("User.Posts").
-		Preload("User.Comments").
-		Preload("Comments.User").
-		Find(&posts).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to retrieve posts", "error": err.Error()})
-		return
+// postIDParam parses the post ID from the URL
+func postIDParam(c *gin.Context) (uint, bool) {
+	return idParam(c, "postId", apierror.NotFound("post_not_found", "Post not found"))
+}
+
+// postAuthor is the public view of the author shown with a post in listings
+type postAuthor struct {
+	ID          uint
+	Username    string
+	DisplayName string
+	Avatars     map[string]string
+}
+
+// postSummary is a post as shown on listing cards, with its excerpt and
+// reading time instead of its body
+type postSummary struct {
+	ID                 uint
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	Title              string
+	Excerpt            string
+	WordCount          int
+	ReadingTimeMinutes int
+	CoverMediaID       *uint
+	// Cover links to the cover image at the allowed widths, if there is one
+	Cover  *markdown.Image
+	UserID uint
+	User   postAuthor
+}
+
+// summarizePosts converts posts, loaded with their authors, for a listing.
+// Covers are linked through the file route under prefix.
+func summarizePosts(ctx context.Context, posts []models.Post, files *services.MediaService, prefix string) ([]postSummary, error) {
+	var coverIDs []uint
+	for _, post := range posts {
+		if post.CoverMediaID != nil {
+			coverIDs = append(coverIDs, *post.CoverMediaID)
+		}
 	}
-	c.JSON(http.StatusOK, posts)
+	covers, err := files.Covers(ctx, coverIDs, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]postSummary, 0, len(posts))
+	for _, post := range posts {
+		summary := postSummary{
+			ID:                 post.ID,
+			CreatedAt:          post.CreatedAt,
+			UpdatedAt:          post.UpdatedAt,
+			Title:              post.Title,
+			Excerpt:            post.Excerpt,
+			WordCount:          post.WordCount,
+			ReadingTimeMinutes: post.ReadingTimeMinutes,
+			CoverMediaID:       post.CoverMediaID,
+			UserID:             post.UserID,
+			User: postAuthor{
+				ID:          post.User.ID,
+				Username:    post.User.Username,
+				DisplayName: post.User.DisplayName,
+				Avatars:     utils.AvatarURLs(post.User.Avatar),
+			},
+		}
+		if post.CoverMediaID != nil {
+			if cover, ok := covers[*post.CoverMediaID]; ok {
+				summary.Cover = &cover
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
 }
 
-// handleGetPostByID retrieves a post by its ID
-func handleGetPostByID(c *gin.Context, dbConfig *connect.DBConfig) {
-	postID := c.Param("postId")
-	var post models.Post
-	if err := dbConfig.DB.
-		Preload("User").
-		Preload("User.Posts").
-		Preload("User.Comments").
-		Preload("Comments.User").
-		First(&post, postID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"message": "Post not found", "error": err.Error()})
+// handleGetAllPosts lists all posts, newest first
+func handleGetAllPosts(c *gin.Context, posts *services.PostService, files *services.MediaService) {
+	list, err := posts.List(c.Request.Context())
+	if err != nil {
+		c.Error(serviceError(err, "Failed to retrieve posts"))
 		return
 	}
-	c.JSON(http.StatusOK, post)
+
+	summaries, err := summarizePosts(c.Request.Context(), list, files, apiPrefix(c, "/posts"))
+	if err != nil {
+		c.Error(serviceError(err, "Failed to retrieve posts"))
+		return
+	}
+	c.JSON(http.StatusOK, summaries)
+}
+
+// postResponse is a post with its body rendered to HTML
+type postResponse struct {
+	models.Post
+	HTML string
+	// TOC is the table of contents of the body, nested by heading level
+	TOC []markdown.Heading
+	// Series is the series the post is part of, if any
+	Series *seriesNavigation
 }
 
-// handleGetMyPosts retrieves posts by the logged-in user
-func handleGetMyPosts(c *gin.Context, dbConfig *connect.DBConfig) {
-	session := sessions.Default(c)
-	userID := session.Get("user_id")
-	if userID == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized. Please log in."})
+// handleGetPostByID retrieves a post by its ID, with its body rendered.
+// Embedded uploads link to variants sized for the reader's screen, and a post
+// in a series links to the other parts. Headings get anchors, which the table
+// of contents links to.
+func handleGetPostByID(c *gin.Context, posts *services.PostService, files *services.MediaService, series *services.SeriesService) {
+	postID, ok := postIDParam(c)
+	if !ok {
 		return
 	}
 
-	var posts []models.Post
-	if err := dbConfig.DB.Where("user_id = ?", userID).Find(&posts).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to retrieve user posts", "error": err.Error()})
+	post, err := posts.Get(c.Request.Context(), postID)
+	if err != nil {
+		c.Error(serviceError(err, "Failed to retrieve post"))
 		return
 	}
 
-	if len(posts) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"message": "No posts found for this user."})
+	doc, err := markdown.Render(post.Body, files.Images(c.Request.Context(), apiPrefix(c, "/posts")))
+	if err != nil {
+		c.Error(apierror.Internal("Failed to render post", err))
 		return
 	}
+	response := postResponse{Post: post, HTML: doc.HTML, TOC: doc.TOC}
 
-	c.JSON(http.StatusOK, posts)
+	parts, err := series.GetByPost(c.Request.Context(), post.ID)
+	switch {
+	case err == nil:
+		nav := newSeriesNavigation(parts, post.ID)
+		response.Series = &nav
+	case !errors.Is(err, services.ErrSeriesNotFound):
+		c.Error(serviceError(err, "Failed to retrieve post series"))
+		return
+	}
+	c.JSON(http.StatusOK, response)
 }
 
-// handleCreatePost creates a new post
-func handleCreatePost(c *gin.Context, dbConfig *connect.DBConfig) {
-	var reqBody struct {
-		Title string `json:"title" binding:"required"`
-		Body  string `json:"body" binding:"required"`
+// handleGetMyPosts lists the posts of the logged-in user, newest first
+func handleGetMyPosts(c *gin.Context, posts *services.PostService, files *services.MediaService) {
+	userID, ok := utils.SessionUserID(c)
+	if !ok {
+		c.Error(apierror.Unauthorized())
+		return
 	}
 
-	if err := c.ShouldBindJSON(&reqBody); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request data", "error": err.Error()})
+	list, err := posts.ListByUser(c.Request.Context(), userID)
+	if err != nil {
+		c.Error(serviceError(err, "Failed to retrieve user posts"))
 		return
 	}
 
-	session := sessions.Default(c)
-	userID := session.Get("user_id")
-	if userID == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized. Please log in."})
+	if len(list) == 0 {
+		c.Error(apierror.NotFound("posts_not_found", "No posts found for this user."))
 		return
 	}
 
-	post := models.Post{
-		Title:  reqBody.Title,
-		Body:   reqBody.Body,
-		UserID: userID.(uint),
+	summaries, err := summarizePosts(c.Request.Context(), list, files, apiPrefix(c, "/posts/myposts"))
+	if err != nil {
+		c.Error(serviceError(err, "Failed to retrieve user posts"))
+		return
 	}
+	c.JSON(http.StatusOK, summaries)
+}
 
-	if err := dbConfig.DB.Create(&post).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create post", "error": err.Error()})
+// postRequest is the request body for creating and updating a post
+type postRequest struct {
+	Title string `json:"title" binding:"required"`
+	Body  string `json:"body" binding:"required"`
+	// Excerpt and CoverMediaID are left as they are when omitted
+	Excerpt      *string `json:"excerpt"`
+	CoverMediaID *uint   `json:"cover_media_id"`
+}
+
+// input returns the service input of the request
+func (r postRequest) input() services.PostInput {
+	return services.PostInput{Title: r.Title, Body: r.Body, Excerpt: r.Excerpt, CoverMediaID: r.CoverMediaID}
+}
+
+// handleCreatePost creates a new post
+func handleCreatePost(c *gin.Context, posts *services.PostService) {
+	var reqBody postRequest
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.Error(apierror.Invalid(err))
+		return
+	}
+
+	userID, ok := utils.SessionUserID(c)
+	if !ok {
+		c.Error(apierror.Unauthorized())
+		return
+	}
+
+	post, err := posts.Create(c.Request.Context(), userID, reqBody.input())
+	if err != nil {
+		c.Error(serviceError(err, "Failed to create post"))
 		return
 	}
 
@@ -131,37 +257,27 @@ func handleCreatePost(c *gin.Context, dbConfig *connect.DBConfig) {
 }
 
 // handleUpdatePost updates a specific post
-func handleUpdatePost(c *gin.Context, dbConfig *connect.DBConfig) {
-	postID := c.Param("postId")
-
-	session := sessions.Default(c)
-	userID := session.Get("user_id")
-	if userID == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized. Please log in."})
+func handleUpdatePost(c *gin.Context, posts *services.PostService) {
+	userID, ok := utils.SessionUserID(c)
+	if !ok {
+		c.Error(apierror.Unauthorized())
 		return
 	}
 
-	var post models.Post
-	if err := dbConfig.DB.Where("id = ? AND user_id = ?", postID, userID).First(&post).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"message": "No post found with this ID for the logged-in user."})
+	postID, ok := postIDParam(c)
+	if !ok {
 		return
 	}
 
-	var reqBody struct {
-		Title string `json:"title" binding:"required"`
-		Body  string `json:"body" binding:"required"`
-	}
-
+	var reqBody postRequest
 	if err := c.ShouldBindJSON(&reqBody); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request data", "error": err.Error()})
+		c.Error(apierror.Invalid(err))
 		return
 	}
 
-	post.Title = reqBody.Title
-	post.Body = reqBody.Body
-
-	if err := dbConfig.DB.Save(&post).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update post", "error": err.Error()})
+	post, err := posts.Update(c.Request.Context(), postID, userID, reqBody.input())
+	if err != nil {
+		c.Error(serviceError(err, "Failed to update post"))
 		return
 	}
 
@@ -172,19 +288,21 @@ func handleUpdatePost(c *gin.Context, dbConfig *connect.DBConfig) {
 }
 
 // handleDeletePost deletes a specific post
-func handleDeletePost(c *gin.Context, dbConfig *connect.DBConfig) {
-	postID := c.Param("postId")
+func handleDeletePost(c *gin.Context, posts *services.PostService) {
+	userID, ok := utils.SessionUserID(c)
+	if !ok {
+		c.Error(apierror.Unauthorized())
+		return
+	}
 
-	session := sessions.Default(c)
-	userID := session.Get("user_id")
-	if userID == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized. Please log in."})
+	postID, ok := postIDParam(c)
+	if !ok {
 		return
 	}
 
-	result := dbConfig.DB.Where("id = ? AND user_id = ?", postID, userID).Delete(&models.Post{})
-	if result.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"message": "No post found with this ID for the logged-in user."})
+	// The post and its comments go to the trash so they can be restored
+	if err := posts.Delete(c.Request.Context(), postID, userID); err != nil {
+		c.Error(serviceError(err, "Failed to delete post"))
 		return
 	}
 
@@ -0,0 +1,320 @@
+package api
+
+import (
+	"TechBlog/apierror"
+	"TechBlog/config"
+	"TechBlog/connect"
+	"TechBlog/mailer"
+	"TechBlog/models"
+	"TechBlog/utils"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// maxAvatarUploadSize caps the size of an uploaded avatar file
+const maxAvatarUploadSize = 5 << 20
+
+// emailVerificationTTL is how long an email verification link stays valid
+const emailVerificationTTL = 24 * time.Hour
+
+// RegisterProfileRoutes sets up routes for the logged-in user's own profile
+func RegisterProfileRoutes(router *gin.RouterGroup, dbConfig *connect.DBConfig, cfg *config.Config) {
+	profileRoutes := router.Group("/users/me")
+	{
+		profileRoutes.GET("", func(c *gin.Context) {
+			handleGetProfile(c, dbConfig)
+		})
+		profileRoutes.PUT("", func(c *gin.Context) {
+			handleUpdateProfile(c, dbConfig, cfg)
+		})
+		profileRoutes.PUT("/password", func(c *gin.Context) {
+			handleChangePassword(c, dbConfig)
+		})
+		profileRoutes.PUT("/avatar", func(c *gin.Context) {
+			handleUploadAvatar(c, dbConfig, cfg)
+		})
+		profileRoutes.DELETE("/avatar", func(c *gin.Context) {
+			handleDeleteAvatar(c, dbConfig, cfg)
+		})
+	}
+}
+
+// profileResponse wraps a user with the URLs of their avatar renditions
+func profileResponse(user models.User) gin.H {
+	return gin.H{
+		"user":    user,
+		"avatars": utils.AvatarURLs(user.Avatar),
+	}
+}
+
+// loadSessionUser loads the logged-in user, writing an error response if it fails
+func loadSessionUser(c *gin.Context, dbConfig *connect.DBConfig) (models.User, bool) {
+	var user models.User
+
+	userID, ok := utils.SessionUserID(c)
+	if !ok {
+		c.Error(apierror.Unauthorized())
+		return user, false
+	}
+
+	if err := dbConfig.DB.WithContext(c.Request.Context()).First(&user, userID).Error; err != nil {
+		c.Error(apierror.FromDB(err, apierror.NotFound("user_not_found", "User not found"), "Failed to retrieve user"))
+		return user, false
+	}
+	return user, true
+}
+
+// handleGetProfile returns the logged-in user's profile
+func handleGetProfile(c *gin.Context, dbConfig *connect.DBConfig) {
+	user, ok := loadSessionUser(c, dbConfig)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, profileResponse(user))
+}
+
+// handleUpdateProfile updates the logged-in user's profile fields. A changed
+// email address is only applied once the new address has been verified.
+func handleUpdateProfile(c *gin.Context, dbConfig *connect.DBConfig, cfg *config.Config) {
+	var reqBody struct {
+		DisplayName *string           `json:"display_name" binding:"omitempty,max=100"`
+		Bio         *string           `json:"bio" binding:"omitempty,max=5000"`
+		Website     *string           `json:"website" binding:"omitempty,url,max=255"`
+		SocialLinks map[string]string `json:"social_links" binding:"omitempty,max=10,dive,keys,required,max=32,endkeys,url,max=255"`
+		Email       *string           `json:"email" binding:"omitempty,email"`
+	}
+
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.Error(apierror.Invalid(err))
+		return
+	}
+
+	user, ok := loadSessionUser(c, dbConfig)
+	if !ok {
+		return
+	}
+
+	var columns []string
+	if reqBody.DisplayName != nil {
+		user.DisplayName = strings.TrimSpace(*reqBody.DisplayName)
+		columns = append(columns, "DisplayName")
+	}
+	if reqBody.Bio != nil {
+		user.Bio = *reqBody.Bio
+		columns = append(columns, "Bio")
+	}
+	if reqBody.Website != nil {
+		user.Website = *reqBody.Website
+		columns = append(columns, "Website")
+	}
+	if reqBody.SocialLinks != nil {
+		user.SocialLinks = reqBody.SocialLinks
+		columns = append(columns, "SocialLinks")
+	}
+
+	var verificationToken string
+	if reqBody.Email != nil && !strings.EqualFold(*reqBody.Email, user.Email) {
+		var count int64
+		dbConfig.DB.WithContext(c.Request.Context()).Model(&models.User{}).Where("email = ?", *reqBody.Email).Count(&count)
+		if count > 0 {
+			c.Error(apierror.Conflict("email_taken", "Email address is already in use"))
+			return
+		}
+
+		token, err := utils.NewToken()
+		if err != nil {
+			c.Error(apierror.Internal("Failed to generate verification token", err))
+			return
+		}
+		verificationToken = token
+
+		now := time.Now()
+		user.PendingEmail = *reqBody.Email
+		user.EmailVerificationHash = utils.HashToken(token)
+		user.EmailVerificationSent = &now
+		columns = append(columns, "PendingEmail", "EmailVerificationHash", "EmailVerificationSent")
+	}
+
+	if len(columns) > 0 {
+		if err := dbConfig.DB.WithContext(c.Request.Context()).Model(&user).Select(columns).Updates(&user).Error; err != nil {
+			c.Error(apierror.FromDB(err, nil, "Failed to update profile"))
+			return
+		}
+	}
+
+	if verificationToken != "" {
+		link := fmt.Sprintf("%s/verify-email?token=%s", cfg.AppURL, verificationToken)
+		body := fmt.Sprintf("Hi %s,\n\nConfirm your new email address by opening this link within 24 hours:\n\n%s\n", user.Username, link)
+		if err := mailer.Send(c.Request.Context(), user.PendingEmail, "Confirm your new email address", body); err != nil {
+			utils.Logger(c).Warn("failed to send verification email", "user_id", user.ID, "error", err)
+		}
+	}
+
+	response := profileResponse(user)
+	response["message"] = "Profile updated successfully"
+	if verificationToken != "" {
+		response["message"] = "Profile updated successfully. Check your new email address to confirm the change."
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// handleVerifyEmail confirms a pending email change using the emailed token
+func handleVerifyEmail(c *gin.Context, dbConfig *connect.DBConfig) {
+	var reqBody struct {
+		Token string `json:"token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.Error(apierror.Invalid(err))
+		return
+	}
+
+	var user models.User
+	if err := dbConfig.DB.WithContext(c.Request.Context()).Where("email_verification_hash = ?", utils.HashToken(reqBody.Token)).First(&user).Error; err != nil {
+		c.Error(apierror.NotFound("invalid_token", "Invalid or expired verification token"))
+		return
+	}
+
+	if user.EmailVerificationSent == nil || time.Since(*user.EmailVerificationSent) > emailVerificationTTL {
+		c.Error(apierror.NotFound("invalid_token", "Invalid or expired verification token"))
+		return
+	}
+
+	updates := map[string]interface{}{
+		"email_verified":          true,
+		"pending_email":           "",
+		"email_verification_hash": "",
+		"email_verification_sent": nil,
+	}
+	if user.PendingEmail != "" {
+		updates["email"] = user.PendingEmail
+	}
+
+	if err := dbConfig.DB.WithContext(c.Request.Context()).Model(&user).Updates(updates).Error; err != nil {
+		c.Error(apierror.FromDB(err, nil, "Failed to verify email"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// handleChangePassword changes the logged-in user's password after checking the current one
+func handleChangePassword(c *gin.Context, dbConfig *connect.DBConfig) {
+	var reqBody struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required,min=8,max=72"`
+	}
+
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.Error(apierror.Invalid(err))
+		return
+	}
+
+	user, ok := loadSessionUser(c, dbConfig)
+	if !ok {
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(reqBody.CurrentPassword)); err != nil {
+		c.Error(apierror.New(http.StatusUnauthorized, "invalid_credentials", "Current password is incorrect"))
+		return
+	}
+
+	user.SetPassword(reqBody.NewPassword)
+	if err := dbConfig.DB.WithContext(c.Request.Context()).Save(&user).Error; err != nil {
+		c.Error(apierror.FromDB(err, nil, "Failed to change password"))
+		return
+	}
+
+	// Other sessions are signed out, this one carries on under the new version
+	session := sessions.Default(c)
+	session.Set(utils.SessionVersionKey, user.SessionVersion)
+	if err := session.Save(); err != nil {
+		c.Error(apierror.Internal("Failed to save session", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
+
+// handleUploadAvatar stores a new avatar, cropped and resized to the fixed avatar sizes
+func handleUploadAvatar(c *gin.Context, dbConfig *connect.DBConfig, cfg *config.Config) {
+	user, ok := loadSessionUser(c, dbConfig)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxAvatarUploadSize)
+	fileHeader, err := c.FormFile("avatar")
+	if err != nil {
+		c.Error(&apierror.Error{Status: http.StatusBadRequest, Code: "avatar_required", Message: "An avatar image under 5 MB is required", Err: err})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.Error(&apierror.Error{Status: http.StatusBadRequest, Code: "invalid_image", Message: "Failed to read avatar", Err: err})
+		return
+	}
+	defer file.Close()
+
+	img, _, err := utils.DecodeImage(file)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, utils.ErrImageTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		c.Error(&apierror.Error{Status: status, Code: "invalid_image", Message: "Avatar must be a JPEG, PNG or GIF image", Err: err})
+		return
+	}
+
+	token, err := utils.NewToken()
+	if err != nil {
+		c.Error(apierror.Internal("Failed to save avatar", err))
+		return
+	}
+	key := strconv.FormatUint(uint64(user.ID), 10) + "-" + token[:12]
+
+	if err := utils.SaveAvatar(cfg.Storage.UploadDir, img, key); err != nil {
+		c.Error(apierror.Internal("Failed to save avatar", err))
+		return
+	}
+
+	previous := user.Avatar
+	if err := dbConfig.DB.WithContext(c.Request.Context()).Model(&user).Update("avatar", key).Error; err != nil {
+		utils.RemoveAvatar(cfg.Storage.UploadDir, key)
+		c.Error(apierror.FromDB(err, nil, "Failed to save avatar"))
+		return
+	}
+	utils.RemoveAvatar(cfg.Storage.UploadDir, previous)
+
+	response := profileResponse(user)
+	response["message"] = "Avatar updated successfully"
+	c.JSON(http.StatusOK, response)
+}
+
+// handleDeleteAvatar removes the logged-in user's avatar
+func handleDeleteAvatar(c *gin.Context, dbConfig *connect.DBConfig, cfg *config.Config) {
+	user, ok := loadSessionUser(c, dbConfig)
+	if !ok {
+		return
+	}
+
+	previous := user.Avatar
+	if err := dbConfig.DB.WithContext(c.Request.Context()).Model(&user).Update("avatar", "").Error; err != nil {
+		c.Error(apierror.FromDB(err, nil, "Failed to remove avatar"))
+		return
+	}
+	utils.RemoveAvatar(cfg.Storage.UploadDir, previous)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Avatar removed successfully"})
+}
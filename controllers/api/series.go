@@ -0,0 +1,258 @@
+package api
+
+import (
+	"TechBlog/apierror"
+	"TechBlog/connect"
+	"TechBlog/models"
+	"TechBlog/services"
+	"TechBlog/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterPublicSeriesRoutes sets up the route that shows a series to anyone
+func RegisterPublicSeriesRoutes(router *gin.RouterGroup, dbConfig *connect.DBConfig) {
+	series := newSeriesService(dbConfig)
+
+	router.GET("/series/:seriesId", func(c *gin.Context) {
+		handleGetSeries(c, series)
+	})
+}
+
+// RegisterSeriesRoutes sets up routes for authors to manage their series
+func RegisterSeriesRoutes(router *gin.RouterGroup, dbConfig *connect.DBConfig) {
+	series := newSeriesService(dbConfig)
+
+	seriesRoutes := router.Group("/series")
+	{
+		seriesRoutes.POST("", func(c *gin.Context) {
+			handleCreateSeries(c, series)
+		})
+		seriesRoutes.PUT("/:seriesId", func(c *gin.Context) {
+			handleUpdateSeries(c, series)
+		})
+		seriesRoutes.PUT("/:seriesId/posts", func(c *gin.Context) {
+			handleSetSeriesPosts(c, series)
+		})
+		seriesRoutes.DELETE("/:seriesId", func(c *gin.Context) {
+			handleDeleteSeries(c, series)
+		})
+	}
+}
+
+// seriesIDParam parses the series ID from the URL
+func seriesIDParam(c *gin.Context) (uint, bool) {
+	return idParam(c, "seriesId", apierror.NotFound("series_not_found", "Series not found"))
+}
+
+// seriesEntry is a post in the table of contents of a series
+type seriesEntry struct {
+	ID                 uint
+	Title              string
+	Position           int
+	ReadingTimeMinutes int
+}
+
+// seriesResponse is a series with its table of contents
+type seriesResponse struct {
+	ID          uint
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uint
+	Title       string
+	Description string
+	// Posts are the posts of the series in reading order, numbered from 1.
+	// Posts in the trash are left out.
+	Posts []seriesEntry
+}
+
+// newSeriesResponse builds the table of contents of a series
+func newSeriesResponse(series models.Series) seriesResponse {
+	entries := make([]seriesEntry, len(series.Posts))
+	for i, member := range series.Posts {
+		entries[i] = seriesEntry{
+			ID:                 member.Post.ID,
+			Title:              member.Post.Title,
+			Position:           i + 1,
+			ReadingTimeMinutes: member.Post.ReadingTimeMinutes,
+		}
+	}
+	return seriesResponse{
+		ID:          series.ID,
+		CreatedAt:   series.CreatedAt,
+		UpdatedAt:   series.UpdatedAt,
+		UserID:      series.UserID,
+		Title:       series.Title,
+		Description: series.Description,
+		Posts:       entries,
+	}
+}
+
+// seriesNavigation places a post in its series, for readers to move
+// between the parts
+type seriesNavigation struct {
+	seriesResponse
+	// Position is the number of the post in the series
+	Position int
+	Previous *seriesEntry
+	Next     *seriesEntry
+}
+
+// newSeriesNavigation returns the navigation around postID in series
+func newSeriesNavigation(series models.Series, postID uint) seriesNavigation {
+	nav := seriesNavigation{seriesResponse: newSeriesResponse(series)}
+	for i, entry := range nav.Posts {
+		if entry.ID != postID {
+			continue
+		}
+		nav.Position = entry.Position
+		if i > 0 {
+			nav.Previous = &nav.Posts[i-1]
+		}
+		if i < len(nav.Posts)-1 {
+			nav.Next = &nav.Posts[i+1]
+		}
+	}
+	return nav
+}
+
+// seriesRequest is the request body for creating and updating a series
+type seriesRequest struct {
+	Title       string `json:"title" binding:"required"`
+	Description string `json:"description"`
+	// PostIDs are the posts of a new series in reading order
+	PostIDs []uint `json:"post_ids"`
+}
+
+// handleGetSeries returns a series with its table of contents
+func handleGetSeries(c *gin.Context, series *services.SeriesService) {
+	seriesID, ok := seriesIDParam(c)
+	if !ok {
+		return
+	}
+
+	found, err := series.Get(c.Request.Context(), seriesID)
+	if err != nil {
+		c.Error(serviceError(err, "Failed to retrieve series"))
+		return
+	}
+	c.JSON(http.StatusOK, newSeriesResponse(found))
+}
+
+// handleCreateSeries starts a series of the logged-in user's posts
+func handleCreateSeries(c *gin.Context, series *services.SeriesService) {
+	var reqBody seriesRequest
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.Error(apierror.Invalid(err))
+		return
+	}
+
+	userID, ok := utils.SessionUserID(c)
+	if !ok {
+		c.Error(apierror.Unauthorized())
+		return
+	}
+
+	created, err := series.Create(c.Request.Context(), userID,
+		services.SeriesInput{Title: reqBody.Title, Description: reqBody.Description}, reqBody.PostIDs)
+	if err != nil {
+		c.Error(serviceError(err, "Failed to create series"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Series created successfully",
+		"series":  newSeriesResponse(created),
+	})
+}
+
+// handleUpdateSeries renames a series or changes its description
+func handleUpdateSeries(c *gin.Context, series *services.SeriesService) {
+	userID, ok := utils.SessionUserID(c)
+	if !ok {
+		c.Error(apierror.Unauthorized())
+		return
+	}
+
+	seriesID, ok := seriesIDParam(c)
+	if !ok {
+		return
+	}
+
+	var reqBody seriesRequest
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.Error(apierror.Invalid(err))
+		return
+	}
+
+	updated, err := series.Update(c.Request.Context(), seriesID, userID,
+		services.SeriesInput{Title: reqBody.Title, Description: reqBody.Description})
+	if err != nil {
+		c.Error(serviceError(err, "Failed to update series"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Series updated successfully",
+		"series":  newSeriesResponse(updated),
+	})
+}
+
+// handleSetSeriesPosts replaces the posts of a series, which adds, removes
+// and reorders them at once
+func handleSetSeriesPosts(c *gin.Context, series *services.SeriesService) {
+	userID, ok := utils.SessionUserID(c)
+	if !ok {
+		c.Error(apierror.Unauthorized())
+		return
+	}
+
+	seriesID, ok := seriesIDParam(c)
+	if !ok {
+		return
+	}
+
+	var reqBody struct {
+		PostIDs []uint `json:"post_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&reqBody); err != nil {
+		c.Error(apierror.Invalid(err))
+		return
+	}
+
+	updated, err := series.SetPosts(c.Request.Context(), seriesID, userID, reqBody.PostIDs)
+	if err != nil {
+		c.Error(serviceError(err, "Failed to update series posts"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Series posts updated successfully",
+		"series":  newSeriesResponse(updated),
+	})
+}
+
+// handleDeleteSeries deletes a series, keeping its posts
+func handleDeleteSeries(c *gin.Context, series *services.SeriesService) {
+	userID, ok := utils.SessionUserID(c)
+	if !ok {
+		c.Error(apierror.Unauthorized())
+		return
+	}
+
+	seriesID, ok := seriesIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := series.Delete(c.Request.Context(), seriesID, userID); err != nil {
+		c.Error(serviceError(err, "Failed to delete series"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Series deleted successfully",
+	})
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"TechBlog/apierror"
+	"TechBlog/connect"
+	"TechBlog/repository"
+	"TechBlog/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newPostService builds a PostService on the GORM repositories
+func newPostService(dbConfig *connect.DBConfig) *services.PostService {
+	return services.NewPostService(repository.NewPostRepository(dbConfig.DB), repository.NewMediaRepository(dbConfig.DB))
+}
+
+// newCommentService builds a CommentService on the GORM repositories
+func newCommentService(dbConfig *connect.DBConfig) *services.CommentService {
+	return services.NewCommentService(repository.NewCommentRepository(dbConfig.DB), repository.NewPostRepository(dbConfig.DB))
+}
+
+// newSeriesService builds a SeriesService on the GORM repositories
+func newSeriesService(dbConfig *connect.DBConfig) *services.SeriesService {
+	return services.NewSeriesService(repository.NewSeriesRepository(dbConfig.DB), repository.NewPostRepository(dbConfig.DB))
+}
+
+// newUserService builds a UserService on the GORM repositories
+func newUserService(dbConfig *connect.DBConfig) *services.UserService {
+	return services.NewUserService(repository.NewUserRepository(dbConfig.DB))
+}
+
+// idParam parses a numeric ID from the URL. A malformed ID can never match a
+// record, so it is reported as notFound.
+func idParam(c *gin.Context, name string, notFound *apierror.Error) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 0)
+	if err != nil || id == 0 {
+		c.Error(notFound)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// serviceError converts an error returned by a service. Errors the services
+// do not define are handled like database errors, failing with message.
+func serviceError(err error, message string) *apierror.Error {
+	var validation *services.ValidationError
+	var unsupported *services.UnsupportedMediaTypeError
+	switch {
+	case errors.As(err, &validation):
+		return apierror.InvalidField(validation.Field, validation.Code, validation.Message)
+	case errors.As(err, &unsupported):
+		return apierror.New(http.StatusUnsupportedMediaType, "unsupported_media_type", "This type of file is not accepted").
+			With("content_type", unsupported.ContentType).With("allowed_types", unsupported.Allowed)
+	case errors.Is(err, services.ErrPostNotFound):
+		return apierror.NotFound("post_not_found", "Post not found")
+	case errors.Is(err, services.ErrCommentNotFound):
+		return apierror.NotFound("comment_not_found", "Comment not found")
+	case errors.Is(err, services.ErrUserNotFound):
+		return apierror.NotFound("user_not_found", "User not found")
+	case errors.Is(err, services.ErrSeriesNotFound):
+		return apierror.NotFound("series_not_found", "Series not found")
+	case errors.Is(err, services.ErrMediaNotFound):
+		return apierror.NotFound("media_not_found", "Media not found")
+	case errors.Is(err, services.ErrMediaNotTransformable):
+		return apierror.BadRequest("media_not_transformable", "Only JPEG, PNG, GIF and WebP images can be resized or converted")
+	case errors.Is(err, services.ErrInvalidCredentials):
+		return apierror.New(http.StatusUnauthorized, "invalid_credentials", "Incorrect password, please try again")
+	}
+	return apierror.FromDB(err, nil, message)
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"TechBlog/apierror"
+	"TechBlog/config"
+	"TechBlog/connect"
+	"TechBlog/models"
+	"TechBlog/trash"
+	"TechBlog/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RegisterTrashRoutes sets up routes for the logged-in user's trash
+func RegisterTrashRoutes(router *gin.RouterGroup, dbConfig *connect.DBConfig, cfg *config.Config) {
+	trashRoutes := router.Group("/trash")
+	{
+		trashRoutes.GET("", func(c *gin.Context) {
+			handleGetTrash(c, dbConfig, cfg, false)
+		})
+		trashRoutes.POST("/posts/:postId/restore", func(c *gin.Context) {
+			handleRestorePost(c, dbConfig, false)
+		})
+		trashRoutes.POST("/comments/:commentId/restore", func(c *gin.Context) {
+			handleRestoreComment(c, dbConfig, false)
+		})
+	}
+}
+
+// RegisterAdminTrashRoutes sets up routes for administrators to manage everyone's trash
+func RegisterAdminTrashRoutes(router *gin.RouterGroup, dbConfig *connect.DBConfig, cfg *config.Config) {
+	trashRoutes := router.Group("/trash")
+	{
+		trashRoutes.GET("", func(c *gin.Context) {
+			handleGetTrash(c, dbConfig, cfg, true)
+		})
+		trashRoutes.POST("/posts/:postId/restore", func(c *gin.Context) {
+			handleRestorePost(c, dbConfig, true)
+		})
+		trashRoutes.POST("/comments/:commentId/restore", func(c *gin.Context) {
+			handleRestoreComment(c, dbConfig, true)
+		})
+	}
+}
+
+// trashScope limits a query to deleted rows, owned by the logged-in user unless admin is set
+func trashScope(c *gin.Context, admin bool) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Unscoped().Where("deleted_at IS NOT NULL")
+		if !admin {
+			userID, _ := utils.SessionUserID(c)
+			db = db.Where("user_id = ?", userID)
+		}
+		return db
+	}
+}
+
+// handleGetTrash lists trashed posts and comments
+func handleGetTrash(c *gin.Context, dbConfig *connect.DBConfig, cfg *config.Config, admin bool) {
+	var posts []models.Post
+	if err := dbConfig.DB.WithContext(c.Request.Context()).Scopes(trashScope(c, admin)).Order("deleted_at DESC").Find(&posts).Error; err != nil {
+		c.Error(apierror.FromDB(err, nil, "Failed to retrieve trash"))
+		return
+	}
+
+	var comments []models.Comment
+	if err := dbConfig.DB.WithContext(c.Request.Context()).Scopes(trashScope(c, admin)).Order("deleted_at DESC").Find(&comments).Error; err != nil {
+		c.Error(apierror.FromDB(err, nil, "Failed to retrieve trash"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"posts":          posts,
+		"comments":       comments,
+		"retention_days": cfg.Accounts.TrashRetentionDays,
+	})
+}
+
+// handleRestorePost restores a trashed post along with the comments deleted with it
+func handleRestorePost(c *gin.Context, dbConfig *connect.DBConfig, admin bool) {
+	var post models.Post
+	if err := dbConfig.DB.WithContext(c.Request.Context()).Scopes(trashScope(c, admin)).Where("id = ?", c.Param("postId")).First(&post).Error; err != nil {
+		c.Error(apierror.NotFound("post_not_found", "No post found in the trash with this ID."))
+		return
+	}
+
+	if err := trash.RestorePost(dbConfig.DB.WithContext(c.Request.Context()), &post); err != nil {
+		respondRestoreError(c, err, "Failed to restore post")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Post restored successfully"})
+}
+
+// handleRestoreComment restores a trashed comment
+func handleRestoreComment(c *gin.Context, dbConfig *connect.DBConfig, admin bool) {
+	var comment models.Comment
+	if err := dbConfig.DB.WithContext(c.Request.Context()).Scopes(trashScope(c, admin)).Where("id = ?", c.Param("commentId")).First(&comment).Error; err != nil {
+		c.Error(apierror.NotFound("comment_not_found", "No comment found in the trash with this ID."))
+		return
+	}
+
+	if err := trash.RestoreComment(dbConfig.DB.WithContext(c.Request.Context()), &comment); err != nil {
+		respondRestoreError(c, err, "Failed to restore comment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Comment restored successfully"})
+}
+
+func respondRestoreError(c *gin.Context, err error, message string) {
+	if errors.Is(err, trash.ErrParentDeleted) {
+		c.Error(apierror.Conflict("parent_deleted", "The post this item belongs to is in the trash, or its author has been deleted."))
+		return
+	}
+	c.Error(apierror.FromDB(err, nil, message))
+}
@@ -1,75 +1,80 @@
 package api
 
 import (
+	"TechBlog/apierror"
 	"TechBlog/connect"
-	"TechBlog/models"
+	"TechBlog/metrics"
+	"TechBlog/services"
+	"TechBlog/utils"
 	"net/http"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // RegisterPublicRoutes sets up public user-related routes
 func RegisterPublicRoutes(router *gin.RouterGroup, dbConfig *connect.DBConfig) {
+	users := newUserService(dbConfig)
+
 	router.POST("/login", func(c *gin.Context) {
-		handleLogin(c, dbConfig)
+		handleLogin(c, users)
 	})
 
 	router.POST("/signup", func(c *gin.Context) {
-		handleSignup(c, dbConfig)
+		handleSignup(c, users)
+	})
+
+	router.POST("/users/verify-email", func(c *gin.Context) {
+		handleVerifyEmail(c, dbConfig)
 	})
 }
 
 // RegisterProtectedRoutes sets up protected user-related routes
 func RegisterProtectedRoutes(router *gin.RouterGroup, dbConfig *connect.DBConfig) {
+	users := newUserService(dbConfig)
+
 	router.GET("/users", func(c *gin.Context) {
-		handleGetAllUsers(c, dbConfig)
+		handleGetAllUsers(c, users)
 	})
 
 	router.GET("/users/:id", func(c *gin.Context) {
-		handleGetUser(c, dbConfig)
+		handleGetUser(c, users)
 	})
 
 	router.POST("/users", func(c *gin.Context) {
-		handleCreateUser(c, dbConfig)
-	})
-
-	router.DELETE("/users/:id", func(c *gin.Context) {
-		handleDeleteUser(c, dbConfig)
+		handleCreateUser(c, users)
 	})
 }
 
 // handleLogin processes user login
-func handleLogin(c *gin.Context, dbConfig *connect.DBConfig) {
+func handleLogin(c *gin.Context, users *services.UserService) {
 	var reqBody struct {
 		Username string `json:"username" binding:"required"`
 		Password string `json:"password" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&reqBody); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request data", "error": err.Error()})
-		return
-	}
-
-	var user models.User
-	if err := dbConfig.DB.Where("username = ?", reqBody.Username).First(&user).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"message": "No user found with the provided username"})
+		c.Error(apierror.Invalid(err))
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(reqBody.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": "Incorrect password, please try again"})
+	user, err := users.Authenticate(c.Request.Context(), reqBody.Username, reqBody.Password)
+	if err != nil {
+		c.Error(serviceError(err, "Failed to log in"))
 		return
 	}
 
 	session := sessions.Default(c)
 	session.Set("user_id", user.ID)
 	session.Set("logged_in", true)
+	session.Set(utils.SessionVersionKey, user.SessionVersion)
+	// A token issued before login could have been planted by someone else
+	utils.ResetCSRFToken(session)
 	if err := session.Save(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to save session", "error": err.Error()})
+		c.Error(apierror.Internal("Failed to save session", err))
 		return
 	}
+	metrics.SessionsStarted.Inc()
 
 	c.JSON(http.StatusOK, gin.H{
 		"user":    user,
@@ -77,27 +82,24 @@ func handleLogin(c *gin.Context, dbConfig *connect.DBConfig) {
 	})
 }
 
-// handleSignup handles user registration
-func handleSignup(c *gin.Context, dbConfig *connect.DBConfig) {
-	var reqBody struct {
-		Username string `json:"username" binding:"required"`
-		Email    string `json:"email" binding:"required,email"`
-		Password string `json:"password" binding:"required"`
-	}
+// registerRequest is the request body for signing up and creating users
+type registerRequest struct {
+	Username string `json:"username" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
 
+// handleSignup handles user registration
+func handleSignup(c *gin.Context, users *services.UserService) {
+	var reqBody registerRequest
 	if err := c.ShouldBindJSON(&reqBody); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request data", "error": err.Error()})
+		c.Error(apierror.Invalid(err))
 		return
 	}
 
-	newUser := models.User{
-		Username: reqBody.Username,
-		Email:    reqBody.Email,
-		Password: reqBody.Password,
-	}
-
-	if err := dbConfig.DB.Create(&newUser).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create user", "error": err.Error()})
+	newUser, err := users.Register(c.Request.Context(), reqBody.Username, reqBody.Email, reqBody.Password)
+	if err != nil {
+		c.Error(serviceError(err, "Failed to create user"))
 		return
 	}
 
@@ -108,23 +110,26 @@ func handleSignup(c *gin.Context, dbConfig *connect.DBConfig) {
 }
 
 // handleGetAllUsers retrieves all users (protected)
-func handleGetAllUsers(c *gin.Context, dbConfig *connect.DBConfig) {
-	var users []models.User
-	if err := dbConfig.DB.Find(&users).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to retrieve users", "error": err.Error()})
+func handleGetAllUsers(c *gin.Context, users *services.UserService) {
+	list, err := users.List(c.Request.Context())
+	if err != nil {
+		c.Error(serviceError(err, "Failed to retrieve users"))
 		return
 	}
 
-	c.JSON(http.StatusOK, users)
+	c.JSON(http.StatusOK, list)
 }
 
 // handleGetUser retrieves a specific user by ID (protected)
-func handleGetUser(c *gin.Context, dbConfig *connect.DBConfig) {
-	userID := c.Param("id")
+func handleGetUser(c *gin.Context, users *services.UserService) {
+	userID, ok := idParam(c, "id", apierror.NotFound("user_not_found", "User not found"))
+	if !ok {
+		return
+	}
 
-	var user models.User
-	if err := dbConfig.DB.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"message": "User not found", "error": err.Error()})
+	user, err := users.Get(c.Request.Context(), userID)
+	if err != nil {
+		c.Error(serviceError(err, "Failed to retrieve user"))
 		return
 	}
 
@@ -132,26 +137,16 @@ func handleGetUser(c *gin.Context, dbConfig *connect.DBConfig) {
 }
 
 // handleCreateUser creates a new user (protected)
-func handleCreateUser(c *gin.Context, dbConfig *connect.DBConfig) {
-	var reqBody struct {
-		Username string `json:"username" binding:"required"`
-		Email    string `json:"email" binding:"required,email"`
-		Password string `json:"password" binding:"required"`
-	}
-
+func handleCreateUser(c *gin.Context, users *services.UserService) {
+	var reqBody registerRequest
 	if err := c.ShouldBindJSON(&reqBody); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request data", "error": err.Error()})
+		c.Error(apierror.Invalid(err))
 		return
 	}
 
-	newUser := models.User{
-		Username: reqBody.Username,
-		Email:    reqBody.Email,
-		Password: reqBody.Password,
-	}
-
-	if err := dbConfig.DB.Create(&newUser).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create user", "error": err.Error()})
+	newUser, err := users.Register(c.Request.Context(), reqBody.Username, reqBody.Email, reqBody.Password)
+	if err != nil {
+		c.Error(serviceError(err, "Failed to create user"))
 		return
 	}
 
@@ -160,17 +155,3 @@ func handleCreateUser(c *gin.Context, dbConfig *connect.DBConfig) {
 		"message": "User created successfully",
 	})
 }
-
-// handleDeleteUser deletes a specific user by ID (protected)
-func handleDeleteUser(c *gin.Context, dbConfig *connect.DBConfig) {
-	userID := c.Param("id")
-
-	if err := dbConfig.DB.Delete(&models.User{}, userID).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to delete user", "error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message": "User deleted successfully",
-	})
-}
@@ -0,0 +1,8 @@
+package api
+
+import "github.com/gin-gonic/gin"
+
+// Overrides replaces handlers in one version of the API. The keys are a
+// method and a route path relative to the version prefix, such as
+// "GET /posts/:postId"; every other route keeps the shared handler.
+type Overrides map[string]gin.HandlerFunc
@@ -1,25 +1,165 @@
 package routes
 
 import (
+	"TechBlog/config"
 	"TechBlog/connect"
 	"TechBlog/controllers/api"
+	"TechBlog/media"
+	"TechBlog/ratelimit"
 	"TechBlog/utils"
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/gin-gonic/gin"
 )
 
-// RegisterRoutes sets up all routes for the application
-func RegisterRoutes(router *gin.Engine, dbConfig *connect.DBConfig) {
+// apiVersion is one version of the API. Every version serves the same routes;
+// overrides, when set, returns the handlers that differ in this version.
+type apiVersion struct {
+	name      string
+	overrides func(dbConfig *connect.DBConfig, cfg *config.Config) api.Overrides
+}
+
+// versions are mounted under /api/<name>. A version that changes a payload
+// overrides the handlers of the affected routes, for example
+//
+//	{name: "v2", overrides: api.V2Overrides}
+var versions = []apiVersion{
+	{name: "v1"},
+}
+
+// legacyVersion is also served under the unversioned /api/ prefix, which
+// predates versioning and is deprecated since legacyDeprecatedAt
+const legacyVersion = "v1"
+
+var legacyDeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+
+// rateLimitedRoutes are the routes with a rate limit policy of their own,
+// keyed like api.Overrides. Every other API route counts against
+// config.PolicyAPI.
+var rateLimitedRoutes = map[string]string{
+	"POST /login":     config.PolicyLogin,
+	"POST /signup":    config.PolicySignup,
+	"POST /users":     config.PolicySignup,
+	"POST /posts/":    config.PolicyPosts,
+	"POST /comments/": config.PolicyComments,
+	"POST /media":     config.PolicyMedia,
+}
+
+// apiCSP is the Content-Security-Policy of the API, whose responses are
+// never meant to render as a page
+const apiCSP = "default-src 'none'; base-uri 'none'; form-action 'none'"
+
+// SecurityHeaders are the security headers of the frontend and every other
+// response, built from cfg. The API replaces the policy with apiCSP.
+// Violations are reported to the collector of the latest API version.
+func SecurityHeaders(cfg *config.Config) utils.SecurityHeaders {
+	return utils.SecurityHeaders{
+		HSTSMaxAge:            cfg.Security.HSTSMaxAgeSeconds,
+		HSTSIncludeSubdomains: cfg.Security.HSTSIncludeSubdomains,
+		CSP:                   cfg.Security.CSP,
+		ReportOnly:            cfg.Security.CSPReportOnly,
+		ReportURI:             "/api/" + versions[len(versions)-1].name + "/csp-report",
+		FrameAncestors:        cfg.Security.FrameAncestors,
+		ReferrerPolicy:        cfg.Security.ReferrerPolicy,
+		PermissionsPolicy:     cfg.Security.PermissionsPolicy,
+	}
+}
+
+// RegisterRoutes sets up all routes for the application. The API routes are
+// throttled by limiter unless it is nil, and uploaded files are kept in storage.
+func RegisterRoutes(router *gin.Engine, dbConfig *connect.DBConfig, cfg *config.Config, limiter *ratelimit.Limiter, storage media.Storage) {
+	// Health probes and metrics
+	api.RegisterHealthRoutes(router.Group("/"), dbConfig)
+	api.RegisterMetricsRoutes(router.Group("/"), cfg)
+
+	for _, version := range versions {
+		var overrides api.Overrides
+		if version.overrides != nil {
+			overrides = version.overrides(dbConfig, cfg)
+		}
+
+		prefix := "/api/" + version.name
+		registerAPI(router.Group(prefix), prefix, overrides, limiter, storage, dbConfig, cfg)
+		checkOverrides(router, prefix, overrides)
+
+		if version.name == legacyVersion {
+			legacy := router.Group("/api")
+			legacy.Use(utils.WithDeprecation("/api", prefix, legacyDeprecatedAt, cfg.API.LegacySunsetDate()))
+			registerAPI(legacy, "/api", overrides, limiter, storage, dbConfig, cfg)
+		}
+	}
+}
+
+// registerAPI mounts every API route on base, replacing the handlers listed
+// in overrides
+func registerAPI(base *gin.RouterGroup, prefix string, overrides api.Overrides, limiter *ratelimit.Limiter, storage media.Storage, dbConfig *connect.DBConfig, cfg *config.Config) {
+	security := SecurityHeaders(cfg).WithCSP(apiCSP)
+	base.Use(utils.WithSecurityHeaders(security))
+
+	// Requests are counted before authentication, so that failed attempts count too
+	if limiter != nil {
+		base.Use(utils.WithRateLimit(limiter, prefix, rateLimitedRoutes, config.PolicyAPI))
+	}
+
+	// The overrides run last so that they are guarded like the routes they replace
+	withOverrides := func(middleware ...gin.HandlerFunc) []gin.HandlerFunc {
+		if len(overrides) > 0 {
+			middleware = append(middleware, utils.WithOverrides(prefix, overrides))
+		}
+		return middleware
+	}
+
 	// Public routes
-	publicRoutes := router.Group("/api/")
+	publicRoutes := base.Group("/")
+	publicRoutes.Use(withOverrides()...)
+	api.RegisterDocsRoutes(publicRoutes, security)
+	api.RegisterCSRFRoutes(publicRoutes)
+	api.RegisterCSPReportRoutes(publicRoutes)
 	api.RegisterPublicRoutes(publicRoutes, dbConfig)
-	api.RegisterPublicPostRoutes(publicRoutes, dbConfig)
+	api.RegisterPublicPostRoutes(publicRoutes, dbConfig, cfg, storage)
+	api.RegisterAuthorRoutes(publicRoutes, dbConfig, cfg, storage)
+	api.RegisterPublicMediaRoutes(publicRoutes, dbConfig, cfg, storage)
+	api.RegisterPublicSeriesRoutes(publicRoutes, dbConfig)
 
-	// Protected routes
-	protectedRoutes := router.Group("/api/")
-	protectedRoutes.Use(utils.WithAuth())
+	// Protected routes, whose state-changing requests must carry the CSRF
+	// token of the session
+	protectedRoutes := base.Group("/")
+	protectedRoutes.Use(withOverrides(utils.WithAuth(dbConfig), utils.WithCSRF())...)
 	{
 		api.RegisterProtectedRoutes(protectedRoutes, dbConfig)
+		api.RegisterProfileRoutes(protectedRoutes, dbConfig, cfg)
+		api.RegisterAccountRoutes(protectedRoutes, dbConfig, cfg)
+		api.RegisterExportRoutes(protectedRoutes, dbConfig)
 		api.RegisterCommentRoutes(protectedRoutes, dbConfig)
-		api.RegisterPostRoutes(protectedRoutes, dbConfig)
+		api.RegisterPostRoutes(protectedRoutes, dbConfig, cfg, storage)
+		api.RegisterSeriesRoutes(protectedRoutes, dbConfig)
+		api.RegisterTrashRoutes(protectedRoutes, dbConfig, cfg)
+		api.RegisterMediaRoutes(protectedRoutes, dbConfig, cfg, storage)
+	}
+
+	// Admin routes
+	adminRoutes := base.Group("/admin")
+	adminRoutes.Use(withOverrides(utils.WithAuth(dbConfig), utils.WithCSRF(), utils.WithAdmin(dbConfig))...)
+	{
+		api.RegisterAdminTrashRoutes(adminRoutes, dbConfig, cfg)
+		api.RegisterAdminAccountRoutes(adminRoutes, dbConfig, cfg)
+		api.RegisterAdminExportRoutes(adminRoutes, dbConfig)
+	}
+}
+
+// checkOverrides panics when overrides names a route that is not registered
+// under prefix, which would otherwise silently keep the shared handler
+func checkOverrides(router *gin.Engine, prefix string, overrides api.Overrides) {
+	registered := map[string]bool{}
+	for _, route := range router.Routes() {
+		registered[route.Method+" "+route.Path] = true
+	}
+	for key := range overrides {
+		method, path, _ := strings.Cut(key, " ")
+		if !registered[method+" "+prefix+path] {
+			panic(fmt.Sprintf("%s overrides %q, which is not a registered route", prefix, key))
+		}
 	}
 }
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"TechBlog/config"
+	"TechBlog/connect"
+	"TechBlog/media"
+	"TechBlog/migrations"
+	"TechBlog/models"
+	"TechBlog/ratelimit"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Results of a doctor check
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "FAIL"
+)
+
+// runDoctor implements the doctor command, which checks that the server can
+// run with the current configuration and exits with status 1 when it cannot
+func runDoctor(args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	failed := false
+	report := func(result, name, detail string) {
+		fmt.Printf("%-4s  %-10s %s\n", result, name, detail)
+		if result == checkFail {
+			failed = true
+		}
+	}
+	defer func() {
+		if failed {
+			os.Exit(1)
+		}
+	}()
+
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		report(checkFail, "config", err.Error())
+		return
+	}
+	report(checkOK, "config", cfg.Env+" environment")
+
+	doctorStorage(cfg, report)
+	doctorFrontend(cfg, report)
+	doctorMail(cfg, report)
+	doctorRateLimit(cfg, report)
+	doctorMedia(cfg, report)
+
+	dbConfig, err := connect.DBConnect(cfg.Database)
+	if err != nil {
+		report(checkFail, "database", err.Error())
+		return
+	}
+	defer dbConfig.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sqlDB, err := dbConfig.DB.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		report(checkFail, "database", err.Error())
+		return
+	}
+	switch {
+	case cfg.Database.Driver == config.DriverSQLite:
+		report(checkOK, "database", "opened SQLite database "+cfg.Database.DSN)
+	case cfg.Database.DSN != "":
+		report(checkOK, "database", "connected to Postgres using DB_DSN")
+	default:
+		report(checkOK, "database", fmt.Sprintf("connected to %s on %s:%d", cfg.Database.Name, cfg.Database.Host, cfg.Database.Port))
+	}
+
+	migrator, err := newMigrator(dbConfig)
+	if err != nil {
+		report(checkFail, "migrations", err.Error())
+		return
+	}
+	pending, err := migrator.Pending(ctx)
+	switch {
+	case errors.Is(err, migrations.ErrNotInitialized) && cfg.Database.MigrateOnStart:
+		report(checkWarn, "migrations", "database not initialized, it is migrated when the server starts")
+		return
+	case errors.Is(err, migrations.ErrNotInitialized):
+		report(checkFail, "migrations", "database not initialized, run `techblog migrate up`")
+		return
+	case err != nil:
+		report(checkFail, "migrations", err.Error())
+		return
+	case pending > 0 && cfg.Database.MigrateOnStart:
+		report(checkWarn, "migrations", fmt.Sprintf("%d pending, they are applied when the server starts", pending))
+		return
+	case pending > 0:
+		report(checkFail, "migrations", fmt.Sprintf("%d pending, run `techblog migrate up`", pending))
+		return
+	}
+	report(checkOK, "migrations", "schema is up to date")
+
+	var admins int64
+	if err := dbConfig.DB.Model(&models.User{}).Where("is_admin = ?", true).Count(&admins).Error; err != nil {
+		report(checkFail, "admins", err.Error())
+	} else if admins == 0 {
+		report(checkWarn, "admins", "no administrator, create one with `techblog user create -admin`")
+	} else {
+		report(checkOK, "admins", fmt.Sprintf("%d administrators", admins))
+	}
+}
+
+// doctorStorage checks that the upload and export directories are writable
+// and do not lie in the source tree, where users' files could be committed
+func doctorStorage(cfg *config.Config, report func(result, name, detail string)) {
+	for _, dir := range []string{cfg.Storage.UploadDir, cfg.Storage.ExportDir} {
+		if sources, _ := filepath.Glob(filepath.Join(dir, "*.go")); len(sources) > 0 {
+			report(checkWarn, "storage", dir+" contains Go source files, keep users' files out of the source tree")
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			report(checkFail, "storage", err.Error())
+			continue
+		}
+		f, err := os.CreateTemp(dir, ".doctor-*")
+		if err != nil {
+			report(checkFail, "storage", dir+" is not writable: "+err.Error())
+			continue
+		}
+		f.Close()
+		os.Remove(f.Name())
+		report(checkOK, "storage", dir+" is writable")
+	}
+}
+
+// doctorFrontend checks that the frontend has been built
+func doctorFrontend(cfg *config.Config, report func(result, name, detail string)) {
+	index := filepath.Join(cfg.Server.FrontendDir, "index.html")
+	if _, err := os.Stat(index); err != nil {
+		report(checkWarn, "frontend", index+" not found, run `npm run build` in frontend")
+		return
+	}
+	report(checkOK, "frontend", index)
+}
+
+// doctorMail checks that the SMTP server accepts connections
+func doctorMail(cfg *config.Config, report func(result, name, detail string)) {
+	if cfg.Mail.SMTPHost == "" {
+		report(checkWarn, "mail", "no SMTP server configured, emails are written to the log")
+		return
+	}
+
+	address := net.JoinHostPort(cfg.Mail.SMTPHost, cfg.Mail.SMTPPort)
+	conn, err := net.DialTimeout("tcp", address, 5*time.Second)
+	if err != nil {
+		report(checkFail, "mail", err.Error())
+		return
+	}
+	conn.Close()
+	report(checkOK, "mail", "SMTP server "+address+" is reachable")
+}
+
+// doctorRateLimit checks that the rate limit store is reachable
+func doctorRateLimit(cfg *config.Config, report func(result, name, detail string)) {
+	switch {
+	case !cfg.RateLimit.Enabled:
+		report(checkWarn, "ratelimit", "rate limiting is disabled")
+		return
+	case cfg.RateLimit.Store != config.RateLimitStoreRedis:
+		report(checkOK, "ratelimit", cfg.RateLimit.Store+" store")
+		return
+	}
+
+	store, err := ratelimit.NewRedisStore(cfg.RateLimit.RedisURL)
+	if err != nil {
+		report(checkFail, "ratelimit", err.Error())
+		return
+	}
+	defer store.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := store.Ping(ctx); err != nil {
+		report(checkFail, "ratelimit", "Redis is not reachable: "+err.Error())
+		return
+	}
+	report(checkOK, "ratelimit", "Redis store is reachable")
+}
+
+// doctorMedia checks that the S3 bucket for uploaded files is reachable. A
+// local media store lives in the upload directory, which doctorStorage checks.
+func doctorMedia(cfg *config.Config, report func(result, name, detail string)) {
+	if cfg.Media.Store != config.MediaStoreS3 {
+		report(checkOK, "media", "local store in "+cfg.Storage.UploadDir)
+		return
+	}
+
+	store, err := media.NewS3Storage(cfg.Media.S3)
+	if err != nil {
+		report(checkFail, "media", err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := store.Ping(ctx); err != nil {
+		report(checkFail, "media", "S3 bucket "+cfg.Media.S3.Bucket+" is not reachable: "+err.Error())
+		return
+	}
+	report(checkOK, "media", "S3 bucket "+cfg.Media.S3.Bucket+" is reachable")
+}
@@ -0,0 +1,191 @@
+package exports
+
+import (
+	"TechBlog/models"
+	"TechBlog/utils"
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// readme is written to every archive to explain its contents
+const readme = `This archive contains the personal data TechBlog stores about your account.
+
+profile.json           Your account profile
+posts.json             All of your posts, including ones in the trash
+posts/*.md             Each post as a Markdown file
+comments.json          All of your comments, including ones in the trash
+series.json            Your series with the IDs of their posts in order
+media.json             The files you uploaded, which can be downloaded from the
+                       API while your account exists
+account_deletions.json Account deletion requests for your account
+data_exports.json      Data export requests for your account
+
+Login sessions are kept only in your browser's cookie and are not stored on
+the server. TechBlog does not record reactions or an audit log for accounts,
+so there is no data of those kinds to export.
+`
+
+var slugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// profile is the exported view of a user, including fields hidden from API responses
+type profile struct {
+	models.User
+	PendingEmail string `json:",omitempty"`
+}
+
+// Build writes the archive for an export into dir and returns its path and size
+func Build(db *gorm.DB, dir string, export *models.DataExport) (string, int64, error) {
+	var user models.User
+	if err := db.Unscoped().First(&user, export.UserID).Error; err != nil {
+		return "", 0, fmt.Errorf("failed to load user: %w", err)
+	}
+
+	var posts []models.Post
+	if err := db.Unscoped().Where("user_id = ?", user.ID).Order("id").Find(&posts).Error; err != nil {
+		return "", 0, fmt.Errorf("failed to load posts: %w", err)
+	}
+
+	var comments []models.Comment
+	if err := db.Unscoped().Where("user_id = ?", user.ID).Order("id").Find(&comments).Error; err != nil {
+		return "", 0, fmt.Errorf("failed to load comments: %w", err)
+	}
+
+	var series []models.Series
+	if err := db.Preload("Posts", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Where("user_id = ?", user.ID).Order("id").Find(&series).Error; err != nil {
+		return "", 0, fmt.Errorf("failed to load series: %w", err)
+	}
+
+	var files []models.Media
+	if err := db.Where("user_id = ?", user.ID).Order("id").Find(&files).Error; err != nil {
+		return "", 0, fmt.Errorf("failed to load media: %w", err)
+	}
+
+	var deletions []models.AccountDeletion
+	if err := db.Where("user_id = ?", user.ID).Order("id").Find(&deletions).Error; err != nil {
+		return "", 0, fmt.Errorf("failed to load account deletions: %w", err)
+	}
+
+	var dataExports []models.DataExport
+	if err := db.Where("user_id = ?", user.ID).Order("id").Find(&dataExports).Error; err != nil {
+		return "", 0, fmt.Errorf("failed to load data exports: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", 0, err
+	}
+
+	token, err := utils.NewToken()
+	if err != nil {
+		return "", 0, err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("export-%d-%s.zip", export.ID, token[:16]))
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", 0, err
+	}
+
+	archive := zip.NewWriter(f)
+	err = writeArchive(archive, user, posts, comments, series, files, deletions, dataExports)
+	if closeErr := archive.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", 0, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", 0, err
+	}
+	return path, info.Size(), nil
+}
+
+func writeArchive(archive *zip.Writer, user models.User, posts []models.Post, comments []models.Comment, series []models.Series,
+	files []models.Media, deletions []models.AccountDeletion, dataExports []models.DataExport) error {
+	if err := writeFile(archive, "README.txt", strings.NewReader(readme)); err != nil {
+		return err
+	}
+	if err := writeJSON(archive, "profile.json", profile{User: user, PendingEmail: user.PendingEmail}); err != nil {
+		return err
+	}
+	if err := writeJSON(archive, "posts.json", posts); err != nil {
+		return err
+	}
+	for _, post := range posts {
+		if err := writeFile(archive, postFilename(post), strings.NewReader(postMarkdown(post))); err != nil {
+			return err
+		}
+	}
+	if err := writeJSON(archive, "comments.json", comments); err != nil {
+		return err
+	}
+	if err := writeJSON(archive, "series.json", series); err != nil {
+		return err
+	}
+	if err := writeJSON(archive, "media.json", files); err != nil {
+		return err
+	}
+	if err := writeJSON(archive, "account_deletions.json", deletions); err != nil {
+		return err
+	}
+	return writeJSON(archive, "data_exports.json", dataExports)
+}
+
+func writeJSON(archive *zip.Writer, name string, v interface{}) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func writeFile(archive *zip.Writer, name string, r io.Reader) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+func postFilename(post models.Post) string {
+	slug := strings.Trim(slugPattern.ReplaceAllString(strings.ToLower(post.Title), "-"), "-")
+	if slug == "" {
+		slug = "untitled"
+	}
+	return fmt.Sprintf("posts/%d-%s.md", post.ID, slug)
+}
+
+// postMarkdown renders a post as Markdown with YAML front matter
+func postMarkdown(post models.Post) string {
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "id: %d\n", post.ID)
+	fmt.Fprintf(&b, "title: %q\n", post.Title)
+	fmt.Fprintf(&b, "created_at: %s\n", post.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "updated_at: %s\n", post.UpdatedAt.Format(time.RFC3339))
+	if post.DeletedAt.Valid {
+		fmt.Fprintf(&b, "deleted_at: %s\n", post.DeletedAt.Time.Format(time.RFC3339))
+	}
+	b.WriteString("---\n\n")
+	b.WriteString(post.Body)
+	b.WriteString("\n")
+	return b.String()
+}
@@ -0,0 +1,177 @@
+package exports
+
+import (
+	"TechBlog/config"
+	"TechBlog/mailer"
+	"TechBlog/models"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// workerInterval is how often the worker looks for pending and expired exports
+const workerInterval = time.Minute
+
+// RunPending builds every pending export. Each export is claimed before it
+// is built so that several server instances never build the same one.
+// Exports that have been processing for longer than the export timeout were
+// left behind by a worker that died and are built again.
+func RunPending(db *gorm.DB, cfg *config.Config) (int, error) {
+	if err := requeueStale(db, cfg.Accounts.ExportTimeout()); err != nil {
+		return 0, err
+	}
+
+	var pending []models.DataExport
+	if err := db.Where("status = ?", models.ExportPending).Order("id").Find(&pending).Error; err != nil {
+		return 0, err
+	}
+
+	built := 0
+	for i := range pending {
+		export := &pending[i]
+
+		// The claim is told apart from later ones by its start time, which
+		// is truncated to the precision the database stores
+		startedAt := time.Now().Truncate(time.Microsecond)
+		claim := db.Model(&models.DataExport{}).
+			Where("id = ? AND status = ?", export.ID, models.ExportPending).
+			Updates(map[string]interface{}{
+				"status":     models.ExportProcessing,
+				"started_at": startedAt,
+			})
+		if claim.Error != nil || claim.RowsAffected == 0 {
+			continue
+		}
+
+		path, size, err := Build(db, cfg.Storage.ExportDir, export)
+		if err != nil {
+			claimed(db, export.ID, startedAt).Updates(map[string]interface{}{
+				"status": models.ExportFailed,
+				"error":  err.Error(),
+			})
+			continue
+		}
+
+		expiresAt := time.Now().Add(cfg.Accounts.ExportTTL())
+		finished, err := finish(db, export.ID, startedAt, path, size, expiresAt)
+		if err != nil || !finished {
+			if err == nil {
+				slog.Warn("data export was built again by another worker, discarding this archive", "export_id", export.ID)
+			}
+			os.Remove(path)
+			continue
+		}
+		built++
+
+		notify(db, cfg, export, expiresAt)
+	}
+	return built, nil
+}
+
+// claimed selects an export as long as it is processing under the claim
+// made at startedAt. A worker that stalled for so long that its export was
+// requeued and claimed again must leave the export to the new claim.
+func claimed(db *gorm.DB, id uint, startedAt time.Time) *gorm.DB {
+	return db.Model(&models.DataExport{}).
+		Where("id = ? AND status = ? AND started_at = ?", id, models.ExportProcessing, startedAt)
+}
+
+// finish marks an export built under the claim made at startedAt as ready to
+// download from path. It reports false when the claim has been lost, in
+// which case the export is left unchanged.
+func finish(db *gorm.DB, id uint, startedAt time.Time, path string, size int64, expiresAt time.Time) (bool, error) {
+	result := claimed(db, id, startedAt).Updates(map[string]interface{}{
+		"status":       models.ExportReady,
+		"file_path":    path,
+		"size":         size,
+		"completed_at": time.Now(),
+		"expires_at":   expiresAt,
+	})
+	return result.RowsAffected > 0, result.Error
+}
+
+// requeueStale returns exports that have been processing for longer than
+// timeout to the queue. Exports claimed before started_at was recorded count
+// as stale too.
+func requeueStale(db *gorm.DB, timeout time.Duration) error {
+	result := db.Model(&models.DataExport{}).
+		Where("status = ? AND (started_at IS NULL OR started_at < ?)", models.ExportProcessing, time.Now().Add(-timeout)).
+		Updates(map[string]interface{}{
+			"status":     models.ExportPending,
+			"started_at": nil,
+		})
+	if result.RowsAffected > 0 {
+		slog.Warn("requeued stalled data exports", "count", result.RowsAffected)
+	}
+	return result.Error
+}
+
+// ExpireOld deletes the archives of exports whose download window has closed
+func ExpireOld(db *gorm.DB) error {
+	var expired []models.DataExport
+	if err := db.Where("status = ? AND expires_at < ?", models.ExportReady, time.Now()).Find(&expired).Error; err != nil {
+		return err
+	}
+
+	for i := range expired {
+		if err := os.Remove(expired[i].FilePath); err != nil && !os.IsNotExist(err) {
+			slog.Warn("failed to remove expired export", "export_id", expired[i].ID, "error", err)
+			continue
+		}
+		db.Model(&expired[i]).Updates(map[string]interface{}{
+			"status":    models.ExportExpired,
+			"file_path": "",
+		})
+	}
+	return nil
+}
+
+// StartWorker builds pending exports and removes expired ones until ctx is
+// cancelled. The returned channel is closed once the current run has finished.
+func StartWorker(ctx context.Context, db *gorm.DB, cfg *config.Config) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(workerInterval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := RunPending(db, cfg); err != nil {
+				slog.Error("data export run failed", "error", err)
+			}
+			if err := ExpireOld(db); err != nil {
+				slog.Error("data export expiry failed", "error", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
+
+// notify tells the user that their export can be downloaded. Exports
+// requested by an administrator are not announced to the user.
+func notify(db *gorm.DB, cfg *config.Config, export *models.DataExport, expiresAt time.Time) {
+	if export.RequestedByID != export.UserID {
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, export.UserID).Error; err != nil {
+		return
+	}
+
+	body := fmt.Sprintf("Hi %s,\n\nYour data export is ready. Log in to download it from %s/account/exports before %s.\n",
+		user.Username, cfg.AppURL, expiresAt.Format(time.RFC1123))
+	if err := mailer.Send(context.Background(), user.Email, "Your data export is ready", body); err != nil {
+		slog.Warn("failed to send data export email", "user_id", user.ID, "error", err)
+	}
+}
@@ -0,0 +1,75 @@
+package exports
+
+import (
+	"TechBlog/config"
+	"TechBlog/connect"
+	"TechBlog/migrations"
+	"TechBlog/models"
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLateWorkerLosesClaim(t *testing.T) {
+	cfg := config.Default()
+	cfg.Database.Driver = config.DriverSQLite
+	cfg.Database.DSN = ":memory:"
+	cfg.Storage.ExportDir = t.TempDir()
+
+	dbConfig, err := connect.DBConnect(cfg.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbConfig.Close() })
+	sqlDB, err := dbConfig.DB.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := migrations.New(sqlDB, dbConfig.Driver())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	db := dbConfig.DB
+
+	user := models.User{Username: "alice", Email: "alice@example.com", Password: "correct horse battery"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	// A worker claimed the export and stalled for longer than the timeout
+	stalledAt := time.Now().Add(-cfg.Accounts.ExportTimeout() - time.Minute).Truncate(time.Microsecond)
+	export := models.DataExport{UserID: user.ID, RequestedByID: user.ID, Status: models.ExportProcessing, StartedAt: &stalledAt}
+	if err := db.Create(&export).Error; err != nil {
+		t.Fatal(err)
+	}
+	if built, err := RunPending(db, cfg); err != nil || built != 1 {
+		t.Fatalf("expected the stalled export to be built again, built %d: %v", built, err)
+	}
+	var rebuilt models.DataExport
+	if err := db.First(&rebuilt, export.ID).Error; err != nil || rebuilt.Status != models.ExportReady {
+		t.Fatalf("expected the export to be ready: %+v %v", rebuilt, err)
+	}
+
+	// The stalled worker finishes its own archive afterwards
+	late, size, err := Build(db, cfg.Storage.ExportDir, &export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	finished, err := finish(db, export.ID, stalledAt, late, size, time.Now().Add(time.Hour))
+	if err != nil || finished {
+		t.Fatalf("expected the late worker to have lost its claim, finished %v: %v", finished, err)
+	}
+	var current models.DataExport
+	if err := db.First(&current, export.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if current.FilePath != rebuilt.FilePath || current.FilePath == late {
+		t.Fatalf("expected the export to keep the rebuilt archive %q, got %q", rebuilt.FilePath, current.FilePath)
+	}
+	if _, err := os.Stat(rebuilt.FilePath); err != nil {
+		t.Fatalf("expected the rebuilt archive to be kept: %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+const API_URL = "http://localhost:8383/api/v1";
+
+let csrfToken: string | null = null;
+
+// Fetches the CSRF token of the session, which requests that change data must send
+const fetchCsrfToken = async (): Promise<string> => {
+    const response = await fetch(`${API_URL}/csrf`, { credentials: "include" });
+    if (!response.ok) throw new Error("Failed to fetch the CSRF token.");
+    const data = await response.json();
+    return data.csrf_token;
+};
+
+// Forgets the cached CSRF token, for example after logging in, which issues a new one
+export const resetCsrfToken = () => {
+    csrfToken = null;
+};
+
+// apiFetch sends a request with the session cookie and, unless it only reads
+// data, the CSRF token. A rejected token is refreshed and the request retried once.
+export const apiFetch = async (url: string, init: RequestInit = {}): Promise<Response> => {
+    const method = (init.method ?? "GET").toUpperCase();
+    if (method === "GET" || method === "HEAD") {
+        return fetch(url, { ...init, credentials: "include" });
+    }
+
+    const send = async () => {
+        if (!csrfToken) csrfToken = await fetchCsrfToken();
+        const headers = new Headers(init.headers);
+        headers.set("X-CSRF-Token", csrfToken);
+        return fetch(url, { ...init, headers, credentials: "include" });
+    };
+
+    let response = await send();
+    if (response.status === 403) {
+        const problem = await response.clone().json().catch(() => null);
+        if (problem?.code === "invalid_csrf_token") {
+            resetCsrfToken();
+            response = await send();
+        }
+    }
+    return response;
+};
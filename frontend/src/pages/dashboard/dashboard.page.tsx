@@ -1,16 +1,19 @@
 import React, { useEffect, useState } from "react";
 import { useNavigate } from "react-router-dom";
 import swal from "sweetalert2";
+import { apiFetch } from "../../api";
 
 interface User {
     ID: number;
     Username: string;
 }
 
+// A post as listed, with an excerpt instead of its body
 interface Post {
     ID: number;
     Title: string;
-    Body: string;
+    Excerpt: string;
+    ReadingTimeMinutes: number;
     CreatedAt: string;
     User: User;
 }
@@ -40,7 +43,7 @@ const DashboardPage: React.FC = () => {
     // Fetch user's posts
     const fetchMyPosts = async () => {
         try {
-            const response = await fetch("http://localhost:8383/api/posts/myposts", {
+            const response = await fetch("http://localhost:8383/api/v1/posts/myposts", {
                 credentials: "include",
             });
             if (!response.ok) throw new Error("Failed to fetch posts.");
@@ -59,7 +62,7 @@ const DashboardPage: React.FC = () => {
     // Add new post
     const handleAddPost = async () => {
         try {
-            const response = await fetch("http://localhost:8383/api/posts/", {
+            const response = await apiFetch("http://localhost:8383/api/v1/posts/", {
                 method: "POST",
                 headers: {
                     "Content-Type": "application/json",
@@ -90,7 +93,7 @@ const DashboardPage: React.FC = () => {
     // Update post
     const handleUpdatePost = async (postId: number) => {
         try {
-            const response = await fetch(`http://localhost:8383/api/posts/${postId}`, {
+            const response = await apiFetch(`http://localhost:8383/api/v1/posts/${postId}`, {
                 method: "PUT",
                 headers: {
                     "Content-Type": "application/json",
@@ -101,10 +104,12 @@ const DashboardPage: React.FC = () => {
 
             if (!response.ok) throw new Error("Failed to update post.");
 
-            const updatedPost = await response.json();
+            const updated: Post = (await response.json()).post;
             setPosts((prevPosts) =>
                 prevPosts.map((post) =>
-                    post.ID === postId ? { ...post, Title: updatedPost.title, Body: updatedPost.body } : post
+                    post.ID === postId
+                        ? { ...post, Title: updated.Title, Excerpt: updated.Excerpt, ReadingTimeMinutes: updated.ReadingTimeMinutes }
+                        : post
                 )
             );
 
@@ -123,10 +128,25 @@ const DashboardPage: React.FC = () => {
         }
     };
 
+    // Start editing a post. Listings leave out the body, so the post is fetched in full.
+    const handleEditPost = async (postId: number) => {
+        try {
+            const response = await fetch(`http://localhost:8383/api/v1/posts/${postId}`);
+            if (!response.ok) throw new Error("Failed to fetch the post.");
+            const post = await response.json();
+            setEditingPostId(postId);
+            setTitle(post.Title);
+            setBody(post.Body);
+        } catch (err) {
+            console.error(err);
+            setError("Unable to load the post.");
+        }
+    };
+
     // Delete post
     const handleDeletePost = async (postId: number) => {
         try {
-            const response = await fetch(`http://localhost:8383/api/posts/${postId}`, {
+            const response = await apiFetch(`http://localhost:8383/api/v1/posts/${postId}`, {
                 method: "DELETE",
                 credentials: "include",
             });
@@ -197,14 +217,11 @@ const DashboardPage: React.FC = () => {
                             <span className="card-text-color author">
                                 Posted by {post.User.Username} on{" "}
                                 {new Date(post.CreatedAt).toLocaleDateString()}
+                                {" · "}{post.ReadingTimeMinutes} min read
                                 &nbsp;&nbsp;
                                 <button
                                     className="btn w-40 card-body-color ms-2 me-2"
-                                    onClick={() => {
-                                        setEditingPostId(post.ID);
-                                        setTitle(post.Title);
-                                        setBody(post.Body);
-                                    }}
+                                    onClick={() => handleEditPost(post.ID)}
                                 >
                                     <svg xmlns="http://www.w3.org/2000/svg" width="16" height="16"
                                          fill="currentColor" className="bi bi-pencil me-2"
@@ -224,7 +241,7 @@ const DashboardPage: React.FC = () => {
                             </span>
                         </div>
                         <div className="card-body card-body-color">
-                            <p className="card-text text-center card-body-text">{post.Body}</p>
+                            <p className="card-text text-center card-body-text">{post.Excerpt}</p>
                         </div>
                     </article>
                 )
@@ -6,17 +6,26 @@ interface User {
     Username: string;
 }
 
-// Define the interface for a Post
+interface Image {
+    Src: string;
+    Width: number;
+    Height: number;
+    Srcset: { URL: string; Width: number }[] | null;
+}
+
+// A post as listed, with an excerpt instead of its body
 interface Post {
     ID: number;
     Title: string;
-    Body: string;
+    Excerpt: string;
+    ReadingTimeMinutes: number;
+    Cover: Image | null;
     CreatedAt: string;
     User:User
 }
 
 const fetchData = async (): Promise<Post[]> => {
-    const response = await fetch("http://localhost:8383/api/posts");
+    const response = await fetch("http://localhost:8383/api/v1/posts");
     if (!response.ok) {
         throw new Error("Failed to fetch posts");
     }
@@ -53,6 +62,18 @@ const Home: React.FC = () => {
             {posts.length > 0 ? (
                 posts.map((post) => (
                     <article className="card w-75 card-border mb-3" key={post.ID}>
+                        {post.Cover && (
+                            <img
+                                className="card-img-top post-cover"
+                                src={post.Cover.Src}
+                                srcSet={post.Cover.Srcset?.map((source) => `${source.URL} ${source.Width}w`).join(", ")}
+                                sizes="(max-width: 960px) 100vw, 960px"
+                                width={post.Cover.Width}
+                                height={post.Cover.Height}
+                                loading="lazy"
+                                alt=""
+                            />
+                        )}
                         <div className="card-header card-color d-flex flex-column align-items-start">
                             <h2 className="card-title card-text-color mb-1">
                                 <Link to={`/post/${post.ID}`} className="card-title card-text-color mb-1" >{post.Title}</Link>
@@ -61,10 +82,11 @@ const Home: React.FC = () => {
                                 Posted by {post.User.Username} on{" "}
                                 {new Date(post.CreatedAt).toLocaleDateString()} at{" "}
                                 {new Date(post.CreatedAt).toLocaleTimeString()}
+                                {" · "}{post.ReadingTimeMinutes} min read
                             </small>
                         </div>
                         <div className="card-body card-body-color">
-                            <p className="card-text">{post.Body}</p>
+                            <p className="card-text">{post.Excerpt}</p>
                         </div>
                     </article>
                 ))
@@ -1,10 +1,11 @@
 import React, {useEffect, useState} from "react";
 import swal from "sweetalert2";
 import { useNavigate, Link } from "react-router-dom";
+import { resetCsrfToken } from "../../api";
 
 const login = async (username: string, password: string): Promise<string | null> => {
     try {
-        const response = await fetch("http://localhost:8383/api/login", {
+        const response = await fetch("http://localhost:8383/api/v1/login", {
             method: "POST",
             headers: {
                 "Content-Type": "application/json",
@@ -17,6 +18,7 @@ const login = async (username: string, password: string): Promise<string | null>
         });
 
         if (response.ok) {
+            resetCsrfToken();
             const data = await response.json();
             await swal.fire({
                 icon: "success",
@@ -1,6 +1,7 @@
 import React, { useState, useEffect } from "react";
-import { useParams } from "react-router-dom";
+import { Link, useParams } from "react-router-dom";
 import swal from "sweetalert2";
+import { apiFetch } from "../../api";
 
 interface User {
     ID: number;
@@ -14,13 +15,42 @@ interface Comment {
     User: User;
 }
 
+interface SeriesEntry {
+    ID: number;
+    Title: string;
+    Position: number;
+}
+
+// The series a post is part of, with the parts around it
+interface Series {
+    ID: number;
+    Title: string;
+    Posts: SeriesEntry[];
+    Position: number;
+    Previous: SeriesEntry | null;
+    Next: SeriesEntry | null;
+}
+
+// A heading of the body, with the headings of its section
+interface Heading {
+    ID: string;
+    Title: string;
+    Level: number;
+    Children: Heading[];
+}
+
 interface Post {
     ID: number;
     Title: string;
     Body: string;
+    // The body rendered by the server, which leaves out raw HTML
+    HTML: string;
+    // Headings link to their anchors in HTML, such as #user-content-getting-started
+    TOC: Heading[];
     CreatedAt: string;
     User: User;
     Comments: Comment[];
+    Series: Series | null;
 }
 
 const formatDate = (date: string): string => {
@@ -39,7 +69,7 @@ const SinglePostPage: React.FC = () => {
 
     const fetchPost = async () => {
         try {
-            const response = await fetch(`http://localhost:8383/api/posts/${postId}`);
+            const response = await fetch(`http://localhost:8383/api/v1/posts/${postId}`);
             if (!response.ok) throw new Error("Failed to fetch post");
             const data: Post = await response.json();
             setPost(data);
@@ -62,7 +92,7 @@ const SinglePostPage: React.FC = () => {
         }
 
         try {
-            const response = await fetch(`http://localhost:8383/api/comments/`, {
+            const response = await apiFetch(`http://localhost:8383/api/v1/comments/`, {
                 method: "POST",
                 headers: {
                     "Content-Type": "application/json",
@@ -89,7 +119,7 @@ const SinglePostPage: React.FC = () => {
 
     const handleDeleteComment = async (commentId: number) => {
         try {
-            const response = await fetch(`http://localhost:8383/api/comments/${commentId}`, {
+            const response = await apiFetch(`http://localhost:8383/api/v1/comments/${commentId}`, {
                 method: "DELETE",
                 credentials: "include",
             });
@@ -113,7 +143,7 @@ const SinglePostPage: React.FC = () => {
         if (!editingCommentId) return;
 
         try {
-            const response = await fetch(`http://localhost:8383/api/comments/${editingCommentId}`, {
+            const response = await apiFetch(`http://localhost:8383/api/v1/comments/${editingCommentId}`, {
                 method: "PUT",
                 headers: {
                     "Content-Type": "application/json",
@@ -154,9 +184,41 @@ const SinglePostPage: React.FC = () => {
                 <div className="card-header card-color">
                     <h2 className="card-title card-text-color">{post.Title}</h2>
                 </div>
-                <div className="card-body card-body-color">{post.Body}</div>
+                <div className="card-body card-body-color post-body" dangerouslySetInnerHTML={{ __html: post.HTML }} />
             </article>
 
+            {/* Series Navigation */}
+            {post.Series && (
+                <nav className="card w-75 card-border mb-4" aria-label="Series">
+                    <div className="card-header card-color">
+                        <h3 className="card-title card-text-color mb-0">
+                            Part {post.Series.Position} of {post.Series.Posts.length}: {post.Series.Title}
+                        </h3>
+                    </div>
+                    <div className="card-body card-body-color">
+                        <ol className="mb-3">
+                            {post.Series.Posts.map((entry) => (
+                                <li key={entry.ID}>
+                                    {entry.ID === post.ID ? (
+                                        <strong>{entry.Title}</strong>
+                                    ) : (
+                                        <Link to={`/post/${entry.ID}`}>{entry.Title}</Link>
+                                    )}
+                                </li>
+                            ))}
+                        </ol>
+                        <div className="d-flex justify-content-between">
+                            {post.Series.Previous ? (
+                                <Link to={`/post/${post.Series.Previous.ID}`}>&larr; {post.Series.Previous.Title}</Link>
+                            ) : <span />}
+                            {post.Series.Next && (
+                                <Link to={`/post/${post.Series.Next.ID}`}>{post.Series.Next.Title} &rarr;</Link>
+                            )}
+                        </div>
+                    </div>
+                </nav>
+            )}
+
             {/* Add Comment Box */}
             {isLoggedIn && (
                 <div className="d-flex flex-column align-items-center mb-4 w-75">
@@ -0,0 +1,83 @@
+package mailer
+
+import (
+	"TechBlog/logging"
+	"TechBlog/tracing"
+	"context"
+	"fmt"
+	"net/smtp"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Mailer sends transactional email
+type Mailer interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// Default is the mailer used by Send
+var Default Mailer = LogMailer{}
+
+// Send delivers a message using the default mailer, traced as a child of the span in ctx
+func Send(ctx context.Context, to, subject, body string) error {
+	ctx, span := tracing.Tracer().Start(ctx, "mail.send", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	err := Default.Send(ctx, to, subject, body)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// LogMailer writes messages to the log instead of sending them, which is
+// useful for local development
+type LogMailer struct{}
+
+// Send logs the message
+func (LogMailer) Send(ctx context.Context, to, subject, body string) error {
+	logging.FromContext(ctx).InfoContext(ctx, "mail not sent, no SMTP server configured", "to", to, "subject", subject, "body", body)
+	return nil
+}
+
+// SMTPMailer sends messages through an SMTP server
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send delivers the message over SMTP. The trace context is added as
+// message headers so that the mail can be tied to the request that sent it.
+func (m SMTPMailer) Send(ctx context.Context, to, subject, body string) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	headers := []string{
+		"From: " + m.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+	}
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	for _, key := range carrier.Keys() {
+		headers = append(headers, key+": "+carrier.Get(key))
+	}
+	msg := strings.Join(append(headers, "", body), "\r\n")
+
+	if err := smtp.SendMail(m.Host+":"+m.Port, auth, m.From, []string{to}, []byte(msg)); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	return nil
+}
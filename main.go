@@ -1,73 +1,92 @@
 package main
 
 import (
+	"TechBlog/config"
 	"TechBlog/connect"
-	"TechBlog/controllers/routes"
-	"github.com/gin-contrib/cors"
-	"github.com/gin-contrib/sessions"
-	"github.com/gin-contrib/sessions/cookie"
-	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
+	"TechBlog/migrations"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 	"log"
-	"net/http"
 	"os"
-	"time"
+	"strings"
+
+	"github.com/joho/godotenv"
 )
 
+const usage = `Usage: techblog [command] [flags] [arguments]
+
+Commands:
+  serve                 start the HTTP server (the default)
+  migrate               apply, roll back or create database migrations
+  seed                  fill the database with fake users, posts and comments
+  user create           create a user, optionally an administrator
+  user reset-password   set a new password for a user
+  post import           import Markdown files as posts
+  doctor                check the configuration, database and migrations
+
+Every command accepts -config, -env and -port. Run "techblog <command> -h"
+for the flags of a command.
+`
+
 func main() {
-	// Load environment variables
-	err := godotenv.Load()
-	if err != nil {
+	// Load environment variables from .env when the file exists
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
 		log.Fatalf("Error loading .env file: %v", err)
 	}
 
-	// Connect to the database
-	dbConfig, err := connect.DBConnect(
-		os.Getenv("USER"),
-		os.Getenv("PASS"),
-		os.Getenv("DBNAME"),
-	)
-	if err != nil {
-		log.Fatalf("Database connection failed: %v", err)
+	// Without a command the server is started, as before commands existed
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
 	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8005"
+	switch command {
+	case "serve":
+		runServe(args)
+	case "migrate":
+		runMigrate(args)
+	case "seed":
+		runSeed(args)
+	case "user":
+		runUser(args)
+	case "post":
+		runPost(args)
+	case "doctor":
+		runDoctor(args)
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
 	}
+}
 
-	router := gin.Default()
-
-	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:5173"},                   // Frontend URL
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}, // Include OPTIONS for preflight requests
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Accept"},
-		ExposeHeaders:    []string{"Content-Length", "Authorization"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
-
-	router.OPTIONS("/*path", func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "http://localhost:5173")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, Accept")
-		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Status(http.StatusNoContent)
-	})
-
-	gin.SetMode(gin.DebugMode)
-
-	store := cookie.NewStore([]byte("secret"))
-	router.Use(sessions.Sessions("mysession", store))
-
-	router.Static("/static", "./frontend/build/")
-
-	router.NoRoute(func(c *gin.Context) {
-		c.File("./frontend/build/index.html")
-	})
+// mustConnect opens the database connection or exits
+func mustConnect(cfg *config.Config) *connect.DBConfig {
+	dbConfig, err := connect.DBConnect(cfg.Database)
+	if err != nil {
+		log.Fatalf("Database connection failed: %v", err)
+	}
+	return dbConfig
+}
 
-	// Register routes
-	routes.RegisterRoutes(router, dbConfig)
+// requireMigrated exits when the database has pending migrations
+func requireMigrated(dbConfig *connect.DBConfig) {
+	migrator, err := newMigrator(dbConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	router.Run(":" + port)
+	pending, err := migrator.Pending(context.Background())
+	if errors.Is(err, migrations.ErrNotInitialized) {
+		log.Fatal("Database is not initialized. Run `techblog migrate up` or set DB_MIGRATE_ON_START=true.")
+	}
+	if err != nil {
+		log.Fatalf("Failed to check database migrations: %v", err)
+	}
+	if pending > 0 {
+		log.Fatalf("Database has %d pending migrations. Run `techblog migrate up` or set DB_MIGRATE_ON_START=true.", pending)
+	}
 }
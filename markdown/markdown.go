@@ -0,0 +1,112 @@
+// Package markdown renders post bodies, which are written in GitHub Flavored
+// Markdown, to HTML. Raw HTML in a body is left out and links with dangerous
+// schemes such as javascript: are dropped, so the output is safe to insert
+// into pages.
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Image is an uploaded image that a post embeds
+type Image struct {
+	// Src is the URL of the image at the largest size it is displayed at
+	Src string
+	// Width and Height are the dimensions of Src, or zero when unknown
+	Width  int
+	Height int
+	// Srcset lists the sizes the image is available in, smallest first
+	Srcset []Source
+}
+
+// Source is a candidate of a srcset
+type Source struct {
+	URL   string
+	Width int
+}
+
+// ImageResolver looks up the uploaded image that the URL of an embedded
+// image refers to. ok is false for other URLs, which are left as they are.
+type ImageResolver func(url string) (image Image, ok bool)
+
+// Document is a body rendered to HTML
+type Document struct {
+	HTML string
+	// TOC is the table of contents, which nests headings by their level
+	TOC []Heading
+}
+
+// Render converts a Markdown body to HTML. Images that images resolves get
+// their dimensions and a srcset, so that browsers download only the size
+// they display; images may be nil. Headings get anchors derived from their
+// text, and a paragraph of just TOCPlaceholder becomes the table of contents.
+func Render(body string, images ImageResolver) (Document, error) {
+	var doc Document
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithParserOptions(parser.WithASTTransformers(
+			util.Prioritized(imageTransformer{images: images}, 100),
+			util.Prioritized(headingTransformer{toc: &doc.TOC}, 200),
+		)),
+		goldmark.WithRendererOptions(renderer.WithNodeRenderers(
+			util.Prioritized(tocRenderer{}, 100),
+		)),
+	)
+
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(body), &buf); err != nil {
+		return Document{}, err
+	}
+	doc.HTML = buf.String()
+	return doc, nil
+}
+
+// imageTransformer points embedded uploads at their variants
+type imageTransformer struct {
+	images ImageResolver
+}
+
+func (t imageTransformer) Transform(doc *ast.Document, _ text.Reader, _ parser.Context) {
+	if t.images == nil {
+		return
+	}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		img, ok := n.(*ast.Image)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		resolved, ok := t.images(string(img.Destination))
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+
+		img.Destination = []byte(resolved.Src)
+		if resolved.Width > 0 && resolved.Height > 0 {
+			// Reserving the space keeps the text from jumping as images load
+			img.SetAttributeString("width", strconv.Itoa(resolved.Width))
+			img.SetAttributeString("height", strconv.Itoa(resolved.Height))
+		}
+		if len(resolved.Srcset) > 1 {
+			candidates := make([]string, len(resolved.Srcset))
+			for i, source := range resolved.Srcset {
+				candidates[i] = fmt.Sprintf("%s %dw", source.URL, source.Width)
+			}
+			img.SetAttributeString("srcset", strings.Join(candidates, ", "))
+			img.SetAttributeString("sizes", fmt.Sprintf("(max-width: %dpx) 100vw, %dpx", resolved.Width, resolved.Width))
+		}
+		img.SetAttributeString("loading", "lazy")
+		img.SetAttributeString("decoding", "async")
+		return ast.WalkSkipChildren, nil
+	})
+}
@@ -0,0 +1,105 @@
+package markdown
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+)
+
+// wordsPerMinute is the reading speed that reading times are estimated at
+const wordsPerMinute = 200
+
+// Summary describes a body for listings, which do not show the body itself
+type Summary struct {
+	// Excerpt is the text of the first paragraph without markup
+	Excerpt            string
+	WordCount          int
+	ReadingTimeMinutes int
+}
+
+// Summarize returns the summary of a Markdown body. The excerpt is cut at a
+// word boundary to at most maxExcerpt characters, ellipsis included. Images,
+// raw HTML, headings and the placeholder of the table of contents are not
+// part of the excerpt; code counts as words.
+func Summarize(body string, maxExcerpt int) Summary {
+	source := []byte(body)
+	doc := goldmark.New(goldmark.WithExtensions(extension.GFM)).Parser().Parse(text.NewReader(source))
+
+	var summary Summary
+	for block := doc.FirstChild(); block != nil; block = block.NextSibling() {
+		if _, ok := block.(*ast.Paragraph); !ok {
+			continue
+		}
+		if excerpt := strings.Join(strings.Fields(plainText(block, source)), " "); excerpt != "" {
+			summary.Excerpt = Truncate(excerpt, maxExcerpt)
+			break
+		}
+	}
+
+	words := strings.Fields(plainText(doc, source))
+	summary.WordCount = len(words)
+	if summary.Excerpt == "" {
+		// A body without paragraphs, such as a single list, is its own excerpt
+		summary.Excerpt = Truncate(strings.Join(words, " "), maxExcerpt)
+	}
+	if summary.WordCount > 0 {
+		summary.ReadingTimeMinutes = (summary.WordCount + wordsPerMinute - 1) / wordsPerMinute
+	}
+	return summary
+}
+
+// Truncate shortens s to at most max characters, cutting at the last word
+// boundary and ending with an ellipsis
+func Truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	cut := string([]rune(s)[:max-1])
+	if i := strings.LastIndexByte(cut, ' '); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,;:.-–—") + "…"
+}
+
+// plainText returns the text below n that readers see, without markup,
+// images or raw HTML. Blocks are separated by line breaks.
+func plainText(n ast.Node, source []byte) string {
+	var b strings.Builder
+	ast.Walk(n, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			if n.Type() == ast.TypeBlock {
+				b.WriteByte('\n')
+			}
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *ast.Image, *ast.RawHTML, *ast.HTMLBlock:
+			return ast.WalkSkipChildren, nil
+		case *ast.Paragraph:
+			if isTOCPlaceholder(node, source) {
+				return ast.WalkSkipChildren, nil
+			}
+		case *ast.Text:
+			b.Write(node.Segment.Value(source))
+			if node.SoftLineBreak() || node.HardLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(node.Value)
+		case *ast.AutoLink:
+			b.Write(node.Label(source))
+		case *ast.CodeBlock, *ast.FencedCodeBlock:
+			lines := node.Lines()
+			for i := 0; i < lines.Len(); i++ {
+				segment := lines.At(i)
+				b.Write(segment.Value(source))
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return b.String()
+}
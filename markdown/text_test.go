@@ -0,0 +1,25 @@
+package markdown
+
+import "testing"
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want Summary
+	}{
+		{"empty", "", Summary{}},
+		{"first paragraph", "# Title\n\n![A chart](chart.png)\n\nSome `code` and\na [link](https://go.dev).\n\nMore text.", Summary{"Some code and a link.", 8, 1}},
+		{"raw HTML", "<div>hidden</div>\n\nVisible <b>text</b>.", Summary{"Visible text.", 2, 1}},
+		{"without paragraphs", "- one\n- two\n\n```\nfmt.Println()\n```", Summary{"one two fmt.Println()", 3, 1}},
+		{"table of contents", "[[toc]]\n\n## Part one\n\nThe text.", Summary{"The text.", 4, 1}},
+		{"cut at a word", "A sentence that goes on, and on, and on for a while.", Summary{"A sentence that goes on, and on, and…", 12, 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Summarize(test.body, 40); got != test.want {
+				t.Errorf("Summarize = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
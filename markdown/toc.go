@@ -0,0 +1,192 @@
+package markdown
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// TOCPlaceholder is the paragraph that is replaced by the table of contents
+// where an author wants it to appear in the body
+const TOCPlaceholder = "[[toc]]"
+
+// Heading is an entry of a table of contents
+type Heading struct {
+	// ID is the anchor of the heading, so that #ID links to it
+	ID    string
+	Title string
+	Level int
+	// Children are the headings of the section that this heading opens
+	Children []Heading
+}
+
+// headingIDPrefix starts the anchors of headings, so that they cannot take
+// the IDs of the page around the post, like GitHub does
+const headingIDPrefix = "user-content-"
+
+// headingTransformer gives headings anchors and collects them into a table
+// of contents, which replaces the placeholder paragraphs of the body
+type headingTransformer struct {
+	toc *[]Heading
+}
+
+func (t headingTransformer) Transform(doc *ast.Document, reader text.Reader, _ parser.Context) {
+	source := reader.Source()
+	var flat []Heading
+	var placeholders []ast.Node
+	used := make(map[string]bool)
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *ast.Heading:
+			title := strings.Join(strings.Fields(plainText(node, source)), " ")
+			id := uniqueID(headingIDPrefix+slug(title), used)
+			node.SetAttributeString("id", id)
+			flat = append(flat, Heading{ID: id, Title: title, Level: node.Level})
+			return ast.WalkSkipChildren, nil
+		case *ast.Paragraph:
+			// Only a placeholder of its own, not one in a list or quote, counts
+			if node.Parent() == doc && isTOCPlaceholder(node, source) {
+				placeholders = append(placeholders, node)
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+
+	*t.toc = nest(flat)
+	for _, placeholder := range placeholders {
+		doc.ReplaceChild(doc, placeholder, &tocNode{headings: *t.toc})
+	}
+}
+
+// slug turns a title into an anchor: lowercase letters and digits, with
+// dashes between words and other characters left out
+func slug(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(r)
+		case unicode.IsSpace(r) || r == '-':
+			dash = true
+		}
+	}
+	if b.Len() == 0 {
+		return "section"
+	}
+	return b.String()
+}
+
+// uniqueID returns id, or id with the first free numeric suffix if an
+// earlier heading already took it, and marks the result as used
+func uniqueID(id string, used map[string]bool) string {
+	unique := id
+	for i := 1; used[unique]; i++ {
+		unique = id + "-" + strconv.Itoa(i)
+	}
+	used[unique] = true
+	return unique
+}
+
+// nest arranges headings in document order into a tree, in which each
+// heading is a child of the closest heading above it of a higher rank
+func nest(flat []Heading) []Heading {
+	toc := []Heading{}
+	for len(flat) > 0 {
+		heading := flat[0]
+		end := 1
+		for end < len(flat) && flat[end].Level > heading.Level {
+			end++
+		}
+		heading.Children = nest(flat[1:end])
+		toc = append(toc, heading)
+		flat = flat[end:]
+	}
+	return toc
+}
+
+// isTOCPlaceholder reports whether a paragraph holds nothing but the
+// placeholder of the table of contents
+func isTOCPlaceholder(n ast.Node, source []byte) bool {
+	if _, ok := n.(*ast.Paragraph); !ok {
+		return false
+	}
+	var b strings.Builder
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		t, ok := child.(*ast.Text)
+		if !ok {
+			return false
+		}
+		b.Write(t.Segment.Value(source))
+	}
+	return strings.EqualFold(strings.TrimSpace(b.String()), TOCPlaceholder)
+}
+
+// kindTOC is the kind of tocNode
+var kindTOC = ast.NewNodeKind("TOC")
+
+// tocNode is the table of contents in place of a placeholder
+type tocNode struct {
+	ast.BaseBlock
+	headings []Heading
+}
+
+func (n *tocNode) Kind() ast.NodeKind {
+	return kindTOC
+}
+
+func (n *tocNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// tocRenderer renders tocNode as a navigation of nested lists
+type tocRenderer struct{}
+
+func (r tocRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindTOC, r.render)
+}
+
+func (r tocRenderer) render(w util.BufWriter, _ []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	headings := n.(*tocNode).headings
+	if len(headings) == 0 {
+		return ast.WalkContinue, nil
+	}
+	_, _ = w.WriteString(`<nav class="toc">` + "\n")
+	writeTOC(w, headings)
+	_, _ = w.WriteString("</nav>\n")
+	return ast.WalkContinue, nil
+}
+
+func writeTOC(w util.BufWriter, headings []Heading) {
+	_, _ = w.WriteString("<ol>\n")
+	for _, heading := range headings {
+		_, _ = w.WriteString(`<li><a href="#`)
+		_, _ = w.Write(util.EscapeHTML([]byte(heading.ID)))
+		_, _ = w.WriteString(`">`)
+		_, _ = w.Write(util.EscapeHTML([]byte(heading.Title)))
+		_, _ = w.WriteString("</a>")
+		if len(heading.Children) > 0 {
+			_ = w.WriteByte('\n')
+			writeTOC(w, heading.Children)
+		}
+		_, _ = w.WriteString("</li>\n")
+	}
+	_, _ = w.WriteString("</ol>\n")
+}
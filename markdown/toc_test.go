@@ -0,0 +1,80 @@
+package markdown
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenderTOC(t *testing.T) {
+	body := "# Intro\n\n[[toc]]\n\n## Setup\n\n### Go 1.23 & *more*\n\n## Setup\n\n# Wrap-up!\n\n## ?\n\n```\n[[toc]]\n```\n"
+	doc, err := Render(body, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Heading{
+		{ID: "user-content-intro", Title: "Intro", Level: 1, Children: []Heading{
+			{ID: "user-content-setup", Title: "Setup", Level: 2, Children: []Heading{
+				{ID: "user-content-go-123-more", Title: "Go 1.23 & more", Level: 3, Children: []Heading{}},
+			}},
+			{ID: "user-content-setup-1", Title: "Setup", Level: 2, Children: []Heading{}},
+		}},
+		{ID: "user-content-wrap-up", Title: "Wrap-up!", Level: 1, Children: []Heading{
+			{ID: "user-content-section", Title: "?", Level: 2, Children: []Heading{}},
+		}},
+	}
+	if !reflect.DeepEqual(doc.TOC, want) {
+		t.Errorf("TOC = %+v, want %+v", doc.TOC, want)
+	}
+
+	for _, fragment := range []string{
+		`<h1 id="user-content-intro">Intro</h1>`,
+		`<h2 id="user-content-setup-1">Setup</h2>`,
+		`<nav class="toc">`,
+		`<li><a href="#user-content-go-123-more">Go 1.23 &amp; more</a></li>`,
+		"<pre><code>[[toc]]\n</code></pre>",
+	} {
+		if !strings.Contains(doc.HTML, fragment) {
+			t.Errorf("HTML lacks %q:\n%s", fragment, doc.HTML)
+		}
+	}
+	if strings.Contains(doc.HTML, "<p>[[toc]]</p>") {
+		t.Errorf("placeholder was not replaced:\n%s", doc.HTML)
+	}
+}
+
+func TestRenderWithoutPlaceholder(t *testing.T) {
+	doc, err := Render("Text only, [[toc]] inline.\n\n## Part", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(doc.HTML, "<nav") || !strings.Contains(doc.HTML, "[[toc]] inline") {
+		t.Errorf("unexpected table of contents:\n%s", doc.HTML)
+	}
+	if len(doc.TOC) != 1 || doc.TOC[0].ID != "user-content-part" {
+		t.Errorf("TOC = %+v", doc.TOC)
+	}
+}
+
+func TestHeadingIDsArePrefixed(t *testing.T) {
+	// Unprefixed, these headings would take the IDs of the page's own
+	// elements, and a heading titled like a prefixed one must not clash
+	doc, err := Render("# Root\n\n## Comments\n\n## User-content Root\n\n[[toc]]", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fragment := range []string{
+		`<h1 id="user-content-root">Root</h1>`,
+		`<h2 id="user-content-comments">Comments</h2>`,
+		`<h2 id="user-content-user-content-root">User-content Root</h2>`,
+		`<a href="#user-content-comments">Comments</a>`,
+	} {
+		if !strings.Contains(doc.HTML, fragment) {
+			t.Errorf("HTML lacks %q:\n%s", fragment, doc.HTML)
+		}
+	}
+	if strings.Contains(doc.HTML, `id="root"`) || strings.Contains(doc.HTML, `id="comments"`) {
+		t.Errorf("unprefixed ID in:\n%s", doc.HTML)
+	}
+}
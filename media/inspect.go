@@ -0,0 +1,81 @@
+package media
+
+import (
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"strings"
+
+	_ "golang.org/x/image/webp"
+)
+
+// Info describes the content of an uploaded file
+type Info struct {
+	// ContentType is detected from the content, whatever the client claimed
+	ContentType string
+	// Width and Height are the dimensions of images as displayed, after
+	// applying their EXIF orientation, and zero for other files
+	Width  int
+	Height int
+}
+
+// IsImage reports whether the file is an image
+func (i Info) IsImage() bool {
+	return strings.HasPrefix(i.ContentType, "image/")
+}
+
+// Inspect sniffs the content type of file from its first bytes, like
+// browsers do, and reads the dimensions of images from their header. The
+// file is rewound afterwards. An image whose header cannot be decoded is
+// reported with zero dimensions.
+func Inspect(file io.ReadSeeker) (Info, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return Info{}, err
+	}
+	contentType, _, _ := strings.Cut(http.DetectContentType(head[:n]), ";")
+	info := Info{ContentType: contentType}
+
+	if info.IsImage() {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return Info{}, err
+		}
+		if cfg, _, err := image.DecodeConfig(file); err == nil {
+			info.Width, info.Height = cfg.Width, cfg.Height
+		}
+	}
+	if info.ContentType == "image/jpeg" {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return Info{}, err
+		}
+		if swapsAxes(orientation(file)) {
+			info.Width, info.Height = info.Height, info.Width
+		}
+	}
+
+	_, err = file.Seek(0, io.SeekStart)
+	return info, err
+}
+
+// Extension returns the file extension for contentType, including the dot
+func Extension(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	case "application/pdf":
+		return ".pdf"
+	case "text/plain":
+		return ".txt"
+	}
+	return ".bin"
+}
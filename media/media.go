@@ -0,0 +1,44 @@
+// Package media stores the files users upload to embed in their posts. A
+// Storage keeps the bytes, either on the local filesystem or in an
+// S3-compatible bucket; the database keeps a models.Media row per file.
+package media
+
+import (
+	"TechBlog/config"
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotFound is returned when a key names no stored file
+var ErrNotFound = errors.New("media file not found")
+
+// Storage keeps the bytes of uploaded files under keys such as
+// media/1/abc.png. Keys only contain letters, digits, dots, dashes and
+// slashes, so implementations can use them as paths as they are.
+type Storage interface {
+	// Put stores size bytes read from body under key
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Open returns the content of the file stored under key
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the file stored under key. Deleting a missing file is not an error.
+	Delete(ctx context.Context, key string) error
+	// PublicURL returns the permanent URL of the file stored under key, or
+	// an empty string when the files are not publicly readable
+	PublicURL(key string) string
+	// SignedURL returns a URL that serves the file stored under key until
+	// ttl has passed
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// FromConfig returns the store selected by cfg. The local store writes below
+// uploadDir, which the server publishes under /uploads.
+func FromConfig(cfg config.MediaConfig, uploadDir string) (Storage, error) {
+	switch cfg.Store {
+	case config.MediaStoreS3:
+		return NewS3Storage(cfg.S3)
+	default:
+		return NewLocalStorage(uploadDir, "/uploads"), nil
+	}
+}
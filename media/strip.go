@@ -0,0 +1,280 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// ErrMalformedImage is returned by StripMetadata for images whose structure
+// cannot be followed, which could hide metadata where it is not looked for
+var ErrMalformedImage = errors.New("malformed image")
+
+// StripMetadata returns an image without the metadata that cameras and
+// editors store alongside the pixels, such as the location a photo was taken
+// at, the camera's serial number or comments. The pixels are copied as they
+// are, so no quality is lost. A JPEG keeps its EXIF orientation, which is
+// needed to display it upright, and color profiles are kept. Content types
+// that carry no such metadata are returned unchanged.
+func StripMetadata(data []byte, contentType string) ([]byte, error) {
+	switch contentType {
+	case "image/jpeg":
+		return stripJPEG(data)
+	case "image/png":
+		return stripPNG(data)
+	case "image/webp":
+		return stripWebP(data)
+	case "image/gif":
+		return stripGIF(data)
+	}
+	return data, nil
+}
+
+// stripJPEG keeps the JFIF header, ICC profiles and Adobe color information
+// of a JPEG and drops every other application segment and comment. Anything
+// after the end of the image, such as the further images of a multi-picture
+// file with metadata of their own, is dropped too.
+func stripJPEG(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, ErrMalformedImage
+	}
+
+	var jfif, kept bytes.Buffer
+	var out *bytes.Buffer
+	pos := 2
+	for {
+		// Markers may be preceded by any number of fill bytes
+		for pos+1 < len(data) && data[pos] == 0xFF && data[pos+1] == 0xFF {
+			pos++
+		}
+		if pos+2 > len(data) || data[pos] != 0xFF {
+			return nil, ErrMalformedImage
+		}
+		kind := data[pos+1]
+		if kind == 0xD9 && out != nil {
+			out.Write(data[pos : pos+2])
+			return out.Bytes(), nil
+		}
+		if pos+4 > len(data) {
+			return nil, ErrMalformedImage
+		}
+		end := pos + 2 + int(binary.BigEndian.Uint16(data[pos+2:]))
+		if end > len(data) || end < pos+4 {
+			return nil, ErrMalformedImage
+		}
+		segment := data[pos:end]
+
+		if kind == 0xDA && out == nil {
+			// The segments before the first scan are complete
+			out = bytes.NewBuffer(append([]byte(nil), data[:2]...))
+			out.Write(jfif.Bytes())
+			if o := orientation(bytes.NewReader(data)); o > 1 {
+				out.Write(orientationSegment(o))
+			}
+			out.Write(kept.Bytes())
+		}
+		dst := &kept
+		if out != nil {
+			dst = out
+		}
+		switch {
+		case kind == 0xE0 && out == nil:
+			jfif.Write(segment)
+		case kind == 0xE2 && bytes.HasPrefix(segment[4:], []byte("ICC_PROFILE\x00")),
+			kind == 0xEE && bytes.HasPrefix(segment[4:], []byte("Adobe")):
+			dst.Write(segment)
+		case kind >= 0xE0 && kind <= 0xEF, kind == 0xFE:
+			// Other application segments and comments are dropped
+		default:
+			dst.Write(segment)
+		}
+		pos = end
+
+		if kind == 0xDA {
+			// The entropy-coded data of a scan runs to the next marker other
+			// than a restart marker or a stuffed zero byte
+			start := pos
+			for {
+				if pos+1 >= len(data) {
+					return nil, ErrMalformedImage
+				}
+				if next := data[pos+1]; data[pos] == 0xFF && next != 0x00 && next != 0xFF && (next < 0xD0 || next > 0xD7) {
+					break
+				}
+				pos++
+			}
+			out.Write(data[start:pos])
+		}
+	}
+}
+
+// orientationSegment returns an APP1 segment with EXIF data that holds
+// nothing but orientation o
+func orientationSegment(o int) []byte {
+	tiff := []byte{
+		'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, // big endian, first IFD at 8
+		0x00, 0x01, // one entry
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, // orientation, one SHORT
+		0x00, byte(o), 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, // no next IFD
+	}
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1, 0x00, 0x00}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// pngSignature starts every PNG file
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// stripPNG drops the text, time and EXIF chunks of a PNG and anything after
+// its end
+func stripPNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, ErrMalformedImage
+	}
+	out := bytes.NewBuffer(append([]byte(nil), pngSignature...))
+	pos := len(pngSignature)
+	for {
+		if pos+12 > len(data) {
+			return nil, ErrMalformedImage
+		}
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		end := pos + 12 + length
+		if length < 0 || end > len(data) || end < pos {
+			return nil, ErrMalformedImage
+		}
+		switch kind := string(data[pos+4 : pos+8]); kind {
+		case "tEXt", "zTXt", "iTXt", "eXIf", "tIME":
+		default:
+			out.Write(data[pos:end])
+			if kind == "IEND" {
+				return out.Bytes(), nil
+			}
+		}
+		pos = end
+	}
+}
+
+// VP8X flags that announce metadata chunks
+const (
+	webpFlagEXIF = 0x08
+	webpFlagXMP  = 0x04
+)
+
+// stripWebP drops the EXIF and XMP chunks of a WebP image
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, ErrMalformedImage
+	}
+	size := int(binary.LittleEndian.Uint32(data[4:]))
+	if size < 4 || 8+size > len(data) {
+		return nil, ErrMalformedImage
+	}
+	data = data[:8+size]
+
+	var chunks bytes.Buffer
+	pos := 12
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return nil, ErrMalformedImage
+		}
+		length := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		end := pos + 8 + length + length%2
+		if end > len(data) || end < pos {
+			return nil, ErrMalformedImage
+		}
+		switch kind := string(data[pos : pos+4]); kind {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte(nil), data[pos:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= webpFlagEXIF | webpFlagXMP
+			}
+			chunks.Write(chunk)
+		default:
+			chunks.Write(data[pos:end])
+		}
+		pos = end
+	}
+
+	out := make([]byte, 12, 12+chunks.Len())
+	copy(out, data[:12])
+	binary.LittleEndian.PutUint32(out[4:], uint32(4+chunks.Len()))
+	return append(out, chunks.Bytes()...), nil
+}
+
+// stripGIF drops the comments of a GIF and its application extensions,
+// such as XMP, except those that make animations loop
+func stripGIF(data []byte) ([]byte, error) {
+	if len(data) < 13 || (string(data[:6]) != "GIF87a" && string(data[:6]) != "GIF89a") {
+		return nil, ErrMalformedImage
+	}
+	pos := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		pos += 3 << (flags&0x07 + 1)
+	}
+	if pos > len(data) {
+		return nil, ErrMalformedImage
+	}
+	out := bytes.NewBuffer(append([]byte(nil), data[:pos]...))
+
+	// subBlocks returns the end of the data sub-blocks starting at pos
+	subBlocks := func(pos int) (int, error) {
+		for {
+			if pos >= len(data) {
+				return 0, ErrMalformedImage
+			}
+			size := int(data[pos])
+			pos += 1 + size
+			if size == 0 {
+				return pos, nil
+			}
+		}
+	}
+
+	for {
+		if pos >= len(data) {
+			return nil, ErrMalformedImage
+		}
+		switch data[pos] {
+		case 0x3B:
+			out.WriteByte(0x3B)
+			return out.Bytes(), nil
+		case 0x21:
+			if pos+2 > len(data) {
+				return nil, ErrMalformedImage
+			}
+			end, err := subBlocks(pos + 2)
+			if err != nil {
+				return nil, err
+			}
+			label := data[pos+1]
+			loop := label == 0xFF && pos+14 <= len(data) &&
+				(string(data[pos+3:pos+14]) == "NETSCAPE2.0" || string(data[pos+3:pos+14]) == "ANIMEXTS1.0")
+			if label != 0xFE && (label != 0xFF || loop) {
+				out.Write(data[pos:end])
+			}
+			pos = end
+		case 0x2C:
+			start := pos
+			if pos+10 > len(data) {
+				return nil, ErrMalformedImage
+			}
+			flags := data[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << (flags&0x07 + 1)
+			}
+			// The LZW minimum code size precedes the image data
+			end, err := subBlocks(pos + 1)
+			if err != nil {
+				return nil, err
+			}
+			out.Write(data[start:end])
+			pos = end
+		default:
+			return nil, ErrMalformedImage
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+)
+
+// secret stands for the metadata that must not survive
+const secret = "GPS 52.5200 N 13.4050 E"
+
+func TestStripJPEG(t *testing.T) {
+	original := exifJPEG(t)
+	xmp := append([]byte("http://ns.adobe.com/xap/1.0/\x00"), secret...)
+	comment := []byte(secret)
+	segments := append(segment(0xE1, xmp), segment(0xFE, comment)...)
+	// The metadata goes after the EXIF segment, and another picture with
+	// metadata of its own follows the image, like in multi-picture files
+	exifEnd := 4 + int(binary.BigEndian.Uint16(original[4:]))
+	withMetadata := append(append(append([]byte{}, original[:exifEnd]...), segments...), original[exifEnd:]...)
+	withMetadata = append(withMetadata, original...)
+	withMetadata = append(withMetadata, secret...)
+
+	stripped, err := StripMetadata(withMetadata, "image/jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stripped, []byte(secret)) || bytes.Count(stripped, []byte{0xFF, 0xD8}) != 1 {
+		t.Fatal("expected the metadata and the appended picture to be removed")
+	}
+	if o := orientation(bytes.NewReader(stripped)); o != 6 {
+		t.Errorf("expected orientation 6 to be kept, got %d", o)
+	}
+	info, err := Inspect(bytes.NewReader(stripped))
+	if err != nil || info.Width != 20 || info.Height != 40 {
+		t.Fatalf("expected an upright 20x40 JPEG, got %+v, %v", info, err)
+	}
+	if _, _, err := image.Decode(bytes.NewReader(stripped)); err != nil {
+		t.Fatalf("stripped JPEG does not decode: %v", err)
+	}
+}
+
+func TestStripPNG(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, image.NewGray(image.Rect(0, 0, 4, 3))); err != nil {
+		t.Fatal(err)
+	}
+	original := encoded.Bytes()
+	// The text chunk goes after the signature and the IHDR chunk
+	ihdrEnd := len(pngSignature) + 12 + 13
+	text := chunk("tEXt", append([]byte("Comment\x00"), secret...))
+	withMetadata := append(append(append([]byte{}, original[:ihdrEnd]...), text...), original[ihdrEnd:]...)
+	withMetadata = append(withMetadata, secret...)
+
+	stripped, err := StripMetadata(withMetadata, "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stripped, original) {
+		t.Fatalf("expected the original PNG back, got %q", stripped)
+	}
+}
+
+func TestStripGIF(t *testing.T) {
+	palette := color.Palette{color.Black, color.White}
+	frames := []*image.Paletted{image.NewPaletted(image.Rect(0, 0, 4, 3), palette), image.NewPaletted(image.Rect(0, 0, 4, 3), palette)}
+	var encoded bytes.Buffer
+	if err := gif.EncodeAll(&encoded, &gif.GIF{Image: frames, Delay: []int{10, 10}}); err != nil {
+		t.Fatal(err)
+	}
+	original := encoded.Bytes()
+	// The extensions go after the header and the global color table
+	headerEnd := 13
+	if flags := original[10]; flags&0x80 != 0 {
+		headerEnd += 3 << (flags&0x07 + 1)
+	}
+	comment := append([]byte{0x21, 0xFE, byte(len(secret))}, append([]byte(secret), 0)...)
+	xmp := append([]byte{0x21, 0xFF, 11}, "XMP DataXMP"...)
+	xmp = append(append(xmp, byte(len(secret))), append([]byte(secret), 0)...)
+	withMetadata := append(append(append([]byte{}, original[:headerEnd]...), append(comment, xmp...)...), original[headerEnd:]...)
+
+	stripped, err := StripMetadata(withMetadata, "image/gif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stripped, original) {
+		t.Fatalf("expected the original GIF back, got %q", stripped)
+	}
+	decoded, err := gif.DecodeAll(bytes.NewReader(stripped))
+	if err != nil || len(decoded.Image) != 2 || decoded.LoopCount != 0 {
+		t.Fatalf("expected an endlessly looping animation, got %+v, %v", decoded, err)
+	}
+}
+
+func TestStripWebP(t *testing.T) {
+	riff := func(chunks ...[]byte) []byte {
+		body := bytes.Join(chunks, nil)
+		out := []byte("RIFF\x00\x00\x00\x00WEBP")
+		binary.LittleEndian.PutUint32(out[4:], uint32(4+len(body)))
+		return append(out, body...)
+	}
+	vp8x := func(flags byte) []byte {
+		return webpChunk("VP8X", []byte{flags, 0, 0, 0, 3, 0, 0, 2, 0, 0})
+	}
+	pixels := webpChunk("VP8L", []byte{0x2F, 1, 2, 3, 4})
+
+	withMetadata := riff(vp8x(webpFlagEXIF|webpFlagXMP|0x10), webpChunk("EXIF", []byte(secret)), pixels, webpChunk("XMP ", []byte(secret)))
+	stripped, err := StripMetadata(withMetadata, "image/webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := riff(vp8x(0x10), pixels); !bytes.Equal(stripped, want) {
+		t.Fatalf("StripMetadata = %q, want %q", stripped, want)
+	}
+}
+
+func TestStripMalformed(t *testing.T) {
+	original := exifJPEG(t)
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, image.NewGray(image.Rect(0, 0, 4, 3))); err != nil {
+		t.Fatal(err)
+	}
+
+	for contentType, data := range map[string][]byte{
+		"image/jpeg": original[:len(original)/2],
+		"image/png":  encoded.Bytes()[:encoded.Len()-4],
+		"image/gif":  []byte("GIF89a"),
+		"image/webp": []byte("RIFF\xff\x00\x00\x00WEBP"),
+	} {
+		if _, err := StripMetadata(data, contentType); !errors.Is(err, ErrMalformedImage) {
+			t.Errorf("%s: expected ErrMalformedImage, got %v", contentType, err)
+		}
+	}
+	if pdf, err := StripMetadata([]byte("%PDF-1.4"), "application/pdf"); err != nil || string(pdf) != "%PDF-1.4" {
+		t.Errorf("expected other files to be left alone, got %q, %v", pdf, err)
+	}
+}
+
+// segment builds a JPEG marker segment
+func segment(kind byte, payload []byte) []byte {
+	out := []byte{0xFF, kind, 0, 0}
+	binary.BigEndian.PutUint16(out[2:], uint16(len(payload)+2))
+	return append(out, payload...)
+}
+
+// chunk builds a PNG chunk
+func chunk(kind string, data []byte) []byte {
+	out := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	out = append(append(out, kind...), data...)
+	return binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(out[4:]))
+}
+
+// webpChunk builds a RIFF chunk, padded to an even length
+func webpChunk(kind string, data []byte) []byte {
+	out := binary.LittleEndian.AppendUint32([]byte(kind), uint32(len(data)))
+	out = append(out, data...)
+	if len(data)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
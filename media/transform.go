@@ -0,0 +1,174 @@
+package media
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+)
+
+// Formats that images can be converted to
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	// FormatWebP is encoded losslessly, which suits screenshots and
+	// diagrams better than photos
+	FormatWebP = "webp"
+)
+
+// Formats lists every format that images can be converted to
+var Formats = []string{FormatJPEG, FormatPNG, FormatWebP}
+
+// jpegQuality is the quality of JPEG variants
+const jpegQuality = 85
+
+// Transform describes a variant of an image. Zero fields keep the size or
+// the format of the original.
+type Transform struct {
+	// Width and Height are the size of the variant. With only one of them
+	// set, the other follows from the aspect ratio; with both set, the image
+	// is cropped around its center to their aspect ratio.
+	Width  int
+	Height int
+	// Format is one of Formats
+	Format string
+}
+
+// CanTransform reports whether images of contentType can be transformed. GIF
+// animations lose all but their first frame.
+func CanTransform(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+// Resolve returns the transform with every field set that produces t from a
+// width x height image of contentType. Images are never enlarged: a variant
+// larger than the original is scaled down to fit it, keeping the requested
+// aspect ratio.
+func (t Transform) Resolve(width, height int, contentType string) Transform {
+	resolved := Transform{Width: width, Height: height, Format: t.Format}
+	if resolved.Format == "" {
+		resolved.Format = defaultFormat(contentType)
+	}
+
+	switch {
+	case t.Width > 0 && t.Height > 0:
+		// The largest area of the requested aspect ratio that fits the image
+		cropWidth, cropHeight := width, width*t.Height/t.Width
+		if cropHeight > height {
+			cropWidth, cropHeight = height*t.Width/t.Height, height
+		}
+		if cropWidth >= t.Width {
+			resolved.Width, resolved.Height = t.Width, t.Height
+		} else {
+			resolved.Width, resolved.Height = cropWidth, cropHeight
+		}
+	case t.Width > 0 && t.Width < width:
+		resolved.Width, resolved.Height = t.Width, scaled(height, t.Width, width)
+	case t.Height > 0 && t.Height < height:
+		resolved.Width, resolved.Height = scaled(width, t.Height, height), t.Height
+	}
+	resolved.Width, resolved.Height = max(resolved.Width, 1), max(resolved.Height, 1)
+	return resolved
+}
+
+// scaled returns length scaled by to/from, rounded to the nearest pixel
+func scaled(length, to, from int) int {
+	return (length*to*2 + from) / (from * 2)
+}
+
+// defaultFormat is the format that variants of contentType keep when no
+// other is requested
+func defaultFormat(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return FormatJPEG
+	case "image/webp":
+		return FormatWebP
+	}
+	return FormatPNG
+}
+
+// ContentType returns the MIME type of the format of t
+func (t Transform) ContentType() string {
+	return "image/" + t.Format
+}
+
+// Key returns the key that the variant of the file stored under key is kept
+// under, next to the original. t must be resolved.
+func (t Transform) Key(key string) string {
+	base := strings.TrimSuffix(key, path.Ext(key))
+	return fmt.Sprintf("%s_%dx%d%s", base, t.Width, t.Height, Extension(t.ContentType()))
+}
+
+// Apply decodes an image, turns it upright according to its EXIF
+// orientation and produces the variant described by t, which must be
+// resolved. Encoding drops all metadata, such as the location a photo was
+// taken at.
+func Apply(r io.Reader, t Transform) ([]byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+
+	// Scaling before turning the image saves turning all of its pixels
+	o := orientation(bytes.NewReader(data))
+	width, height := t.Width, t.Height
+	if swapsAxes(o) {
+		width, height = height, width
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	op := draw.Src
+	if t.Format == FormatJPEG {
+		// JPEG has no transparency, so transparent pixels become white
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		op = draw.Over
+	}
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, centerCrop(img.Bounds(), width, height), op, nil)
+	out := orient(dst, o)
+
+	var buf bytes.Buffer
+	switch t.Format {
+	case FormatJPEG:
+		err = jpeg.Encode(&buf, out, &jpeg.Options{Quality: jpegQuality})
+	case FormatPNG:
+		err = png.Encode(&buf, out)
+	case FormatWebP:
+		err = nativewebp.Encode(&buf, out, nil)
+	default:
+		err = fmt.Errorf("unsupported image format %q", t.Format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// centerCrop returns the largest rectangle of bounds with the aspect ratio
+// of width x height, centered
+func centerCrop(bounds image.Rectangle, width, height int) image.Rectangle {
+	w, h := bounds.Dx(), bounds.Dy()
+	cropWidth, cropHeight := w, scaled(w, height, width)
+	if cropHeight > h {
+		cropWidth, cropHeight = scaled(h, width, height), h
+	}
+	x0 := bounds.Min.X + (w-cropWidth)/2
+	y0 := bounds.Min.Y + (h-cropHeight)/2
+	return image.Rect(x0, y0, x0+cropWidth, y0+cropHeight)
+}
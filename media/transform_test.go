@@ -0,0 +1,101 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name        string
+		transform   Transform
+		width       int
+		height      int
+		contentType string
+		want        Transform
+	}{
+		{"keeps the original", Transform{}, 800, 600, "image/jpeg", Transform{800, 600, FormatJPEG}},
+		{"scales to a width", Transform{Width: 320}, 800, 600, "image/png", Transform{320, 240, FormatPNG}},
+		{"scales to a height", Transform{Height: 300}, 800, 600, "image/webp", Transform{400, 300, FormatWebP}},
+		{"never enlarges", Transform{Width: 1920}, 800, 600, "image/png", Transform{800, 600, FormatPNG}},
+		{"crops", Transform{Width: 320, Height: 320}, 800, 600, "image/png", Transform{320, 320, FormatPNG}},
+		{"shrinks a crop to fit", Transform{Width: 1280, Height: 640, Format: FormatWebP}, 800, 600, "image/png", Transform{800, 400, FormatWebP}},
+		{"converts GIFs to PNG", Transform{Width: 320}, 640, 480, "image/gif", Transform{320, 240, FormatPNG}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.transform.Resolve(test.width, test.height, test.contentType); got != test.want {
+				t.Errorf("Resolve = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+
+	if key := (Transform{320, 240, FormatWebP}).Key("media/1/abc.png"); key != "media/1/abc_320x240.webp" {
+		t.Errorf("Key = %q", key)
+	}
+}
+
+// exifJPEG encodes a 40x20 image, red on the left and blue on the right,
+// with an EXIF orientation that rotates it by 90 degrees clockwise
+func exifJPEG(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			if x < 20 {
+				img.Set(x, y, color.RGBA{R: 255, A: 255})
+			} else {
+				img.Set(x, y, color.RGBA{B: 255, A: 255})
+			}
+		}
+	}
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	exif := []byte("Exif\x00\x00" +
+		"MM\x00\x2a\x00\x00\x00\x08" + // big-endian TIFF header, first IFD at 8
+		"\x00\x01" + // one entry
+		"\x01\x12\x00\x03\x00\x00\x00\x01\x00\x06\x00\x00" + // orientation 6
+		"\x00\x00\x00\x00")
+	app1 := append([]byte{0xFF, 0xE1, 0, byte(len(exif) + 2)}, exif...)
+	return append(append([]byte{0xFF, 0xD8}, app1...), encoded.Bytes()[2:]...)
+}
+
+func TestApplyOrientsAndStripsMetadata(t *testing.T) {
+	original := exifJPEG(t)
+
+	info, err := Inspect(bytes.NewReader(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ContentType != "image/jpeg" || info.Width != 20 || info.Height != 40 {
+		t.Fatalf("expected an upright 20x40 JPEG, got %+v", info)
+	}
+
+	variant, err := Apply(bytes.NewReader(original), Transform{}.Resolve(info.Width, info.Height, info.ContentType))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(variant, []byte("Exif")) {
+		t.Error("expected the variant to carry no EXIF data")
+	}
+	img, err := jpeg.Decode(bytes.NewReader(variant))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size != image.Pt(20, 40) {
+		t.Fatalf("expected a 20x40 variant, got %v", size)
+	}
+	// Turned clockwise, the left half of the original is at the top
+	if r, _, b, _ := img.At(10, 5).RGBA(); r < b {
+		t.Error("expected red at the top")
+	}
+	if r, _, b, _ := img.At(10, 35).RGBA(); b < r {
+		t.Error("expected blue at the bottom")
+	}
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"TechBlog/models"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"gorm.io/gorm"
+)
+
+// startTimeKey is where the plugin keeps the start time of a statement
+const startTimeKey = "metrics:start_time"
+
+// GormPlugin records the duration of every query in DBQueryDuration
+type GormPlugin struct{}
+
+// Name identifies the plugin to GORM
+func (GormPlugin) Name() string {
+	return "metrics"
+}
+
+// Initialize registers callbacks around every kind of GORM operation
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	return errors.Join(
+		callback.Create().Before("gorm:create").Register("metrics:before_create", startTimer),
+		callback.Create().After("gorm:create").Register("metrics:after_create", observe("create")),
+		callback.Query().Before("gorm:query").Register("metrics:before_query", startTimer),
+		callback.Query().After("gorm:query").Register("metrics:after_query", observe("query")),
+		callback.Update().Before("gorm:update").Register("metrics:before_update", startTimer),
+		callback.Update().After("gorm:update").Register("metrics:after_update", observe("update")),
+		callback.Delete().Before("gorm:delete").Register("metrics:before_delete", startTimer),
+		callback.Delete().After("gorm:delete").Register("metrics:after_delete", observe("delete")),
+		callback.Row().Before("gorm:row").Register("metrics:before_row", startTimer),
+		callback.Row().After("gorm:row").Register("metrics:after_row", observe("row")),
+		callback.Raw().Before("gorm:raw").Register("metrics:before_raw", startTimer),
+		callback.Raw().After("gorm:raw").Register("metrics:after_raw", observe("raw")),
+	)
+}
+
+func startTimer(db *gorm.DB) {
+	db.InstanceSet(startTimeKey, time.Now())
+}
+
+func observe(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(startTimeKey)
+		if !ok {
+			return
+		}
+		outcome := "success"
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			outcome = "error"
+		}
+		DBQueryDuration.WithLabelValues(operation, outcome).Observe(time.Since(value.(time.Time)).Seconds())
+	}
+}
+
+// RegisterDB exposes the connection pool statistics of db and the depth of
+// the background job queues stored in it
+func RegisterDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	if err := prometheus.Register(collectors.NewDBStatsCollector(sqlDB, "techblog")); err != nil {
+		return err
+	}
+	return prometheus.Register(queueCollector{db: db})
+}
+
+var queueDepth = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "jobs", "queue_depth"),
+	"Jobs waiting for a background worker, by job.",
+	[]string{"job"}, nil,
+)
+
+// queueCollector counts waiting jobs when metrics are scraped
+type queueCollector struct {
+	db *gorm.DB
+}
+
+// Describe sends the description of the queue depth metric
+func (q queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueDepth
+}
+
+// Collect counts the pending data exports and scheduled account deletions
+func (q queueCollector) Collect(ch chan<- prometheus.Metric) {
+	queues := []struct {
+		job   string
+		model interface{}
+		query string
+		args  []interface{}
+	}{
+		{"data_export", &models.DataExport{}, "status = ?", []interface{}{models.ExportPending}},
+		{"account_deletion", &models.AccountDeletion{}, "status = ?", []interface{}{models.DeletionScheduled}},
+	}
+
+	for _, queue := range queues {
+		var count int64
+		if err := q.db.Model(queue.model).Where(queue.query, queue.args...).Count(&count).Error; err != nil {
+			ch <- prometheus.NewInvalidMetric(queueDepth, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(queueDepth, prometheus.GaugeValue, float64(count), queue.job)
+	}
+}
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// namespace prefixes every metric of the application
+const namespace = "techblog"
+
+// HTTP metrics, labelled by method, route template and status code
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to handle HTTP requests, by method, route template and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+// DBQueryDuration measures database queries by operation (create, query,
+// update, delete, row or raw) and whether they failed
+var DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "db_query_duration_seconds",
+	Help:      "Time taken by database queries, by GORM operation and outcome.",
+	Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+}, []string{"operation", "outcome"})
+
+// Business counters
+var (
+	PostsPublished = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "posts_published_total",
+		Help:      "Posts created through the API.",
+	})
+
+	CommentsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "comments_created_total",
+		Help:      "Comments created through the API.",
+	})
+
+	LoginsFailed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_failed_total",
+		Help:      "Login attempts rejected because of an unknown username or a wrong password.",
+	})
+
+	// SessionsStarted counts successful logins. Sessions live in a signed
+	// cookie, so the number of active sessions cannot be known on the server.
+	SessionsStarted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sessions_started_total",
+		Help:      "Sessions started by a successful login.",
+	})
+
+	MediaUploaded = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "media_uploaded_total",
+		Help:      "Files uploaded to the media storage.",
+	})
+)
+
+// MediaVariantDuration measures the generation of image variants by output
+// format. Its count is the number of variants generated.
+var MediaVariantDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "media_variant_duration_seconds",
+	Help:      "Time taken to generate resized or converted image variants, by format.",
+	Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+}, []string{"format"})
+
+// RateLimited counts requests rejected by a rate limit, by policy
+var RateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "rate_limited_requests_total",
+	Help:      "Requests rejected because a rate limit was exceeded, by policy.",
+}, []string{"policy"})
+
+// CSPViolations counts the Content-Security-Policy violations reported by
+// browsers, by directive
+var CSPViolations = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "csp_violations_total",
+	Help:      "Content-Security-Policy violations reported by browsers, by directive.",
+}, []string{"directive"})
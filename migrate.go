@@ -0,0 +1,113 @@
+package main
+
+import (
+	"TechBlog/config"
+	"TechBlog/connect"
+	"TechBlog/migrations"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+)
+
+const migrateUsage = `Usage: techblog migrate [flags] <command>
+
+Commands:
+  up             apply all pending migrations
+  down [n]       roll back the last n migrations (default 1)
+  status         list migrations and when they were applied
+  create <name>  create empty up and down files in ` + migrations.SourceDir + `
+
+Flags:
+`
+
+// runMigrate implements the migrate subcommand
+func runMigrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), migrateUsage)
+		fs.PrintDefaults()
+	}
+
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	command := fs.Arg(0)
+	switch command {
+	case "up", "down", "status":
+	case "create":
+		if fs.NArg() < 2 {
+			log.Fatal("migrate create needs a migration name")
+		}
+		up, down, err := migrations.Create(migrations.SourceDir, fs.Arg(1))
+		if err != nil {
+			log.Fatalf("Failed to create migration: %v", err)
+		}
+		fmt.Printf("created %s\ncreated %s\n", up, down)
+		return
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	dbConfig := mustConnect(cfg)
+	defer dbConfig.Close()
+
+	migrator, err := newMigrator(dbConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx := context.Background()
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%d migrations applied\n", applied)
+	case "down":
+		steps := 1
+		if fs.NArg() > 1 {
+			if steps, err = strconv.Atoi(fs.Arg(1)); err != nil || steps < 1 {
+				log.Fatalf("migrate down needs a positive number of steps, got %q", fs.Arg(1))
+			}
+		}
+		rolledBack, err := migrator.Down(ctx, steps)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%d migrations rolled back\n", rolledBack)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-40s %s\n", status.Version, status.Name, applied)
+		}
+	}
+}
+
+// newMigrator returns a migrator for the database that logs what it applies
+func newMigrator(dbConfig *connect.DBConfig) (*migrations.Migrator, error) {
+	sqlDB, err := dbConfig.DB.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	migrator, err := migrations.New(sqlDB, dbConfig.Driver())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	migrator.Log = log.Printf
+	return migrator, nil
+}
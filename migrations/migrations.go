@@ -0,0 +1,374 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// SourceDir is where new migration files are created, relative to the repository root
+const SourceDir = "migrations/sql"
+
+// Dialects with their own migration files. They match the names of the GORM dialectors.
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+)
+
+// lockKey identifies the Postgres advisory lock held while migrating, so
+// that replicas starting at the same time apply migrations one at a time
+const lockKey = 7_354_201_846
+
+var (
+	filenamePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)(?:\.(postgres|sqlite))?\.(up|down)\.sql$`)
+	namePattern     = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// ErrNotInitialized is returned by Pending when the database has never been
+// migrated, so that it lacks even the table that records migrations
+var ErrNotInitialized = errors.New("database is not initialized, no migration has been applied")
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes whether a migration has been applied
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Load reads the embedded migrations for dialect in version order
+func Load(dialect string) ([]Migration, error) {
+	return load(files, "sql", dialect)
+}
+
+// load reads the migration files in dir of fsys. A file written for dialect,
+// such as 0001_name.sqlite.up.sql, takes the place of the file without a
+// dialect; files for other dialects are skipped.
+func load(fsys fs.FS, dir, dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	specific := map[string]bool{}
+	for _, entry := range entries {
+		match := filenamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration file %s does not match NNNN_name[.postgres|.sqlite].up.sql or .down.sql", entry.Name())
+		}
+
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+
+		fileDialect, direction := match[3], match[4]
+		key := match[1] + "." + direction
+		if fileDialect != "" && fileDialect != dialect || fileDialect == "" && specific[key] {
+			continue
+		}
+		specific[key] = fileDialect != ""
+
+		body, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" && dialect != "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up file for %s", m.Version, m.Name, dialect)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies migrations to a Postgres or SQLite database
+type Migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []Migration
+	// Log receives a line for every migration that is applied or rolled back
+	Log func(format string, args ...interface{})
+}
+
+// New returns a Migrator for the embedded migrations of dialect
+func New(db *sql.DB, dialect string) (*Migrator, error) {
+	if dialect != Postgres && dialect != SQLite {
+		return nil, fmt.Errorf("migrations are not available for the %s dialect", dialect)
+	}
+	migrations, err := Load(dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: migrations, Log: func(string, ...interface{}) {}}, nil
+}
+
+// Up applies every pending migration and returns how many were applied
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration, migration.Up,
+				"INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)",
+				migration.Version, migration.Name, time.Now()); err != nil {
+				return err
+			}
+			m.Log("applied %04d_%s", migration.Version, migration.Name)
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the most recently applied steps migrations
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	rolledBack := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && rolledBack < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %04d_%s cannot be rolled back: it has no down file", migration.Version, migration.Name)
+			}
+			if err := m.apply(ctx, conn, migration, migration.Down,
+				"DELETE FROM schema_migrations WHERE version = $1", migration.Version); err != nil {
+				return err
+			}
+			m.Log("rolled back %04d_%s", migration.Version, migration.Name)
+			rolledBack++
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// Status lists every known migration with the time it was applied, if it
+// was. It only reads from the database, so that health checks can call it
+// with a role that may not change the schema; in a database that has never
+// been migrated every migration is pending.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	statuses, _, err := m.status(ctx)
+	return statuses, err
+}
+
+// status is Status that also reports whether the database has been initialized
+func (m *Migrator) status(ctx context.Context) ([]Status, bool, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer conn.Close()
+
+	initialized, err := tableExists(ctx, conn, m.dialect)
+	if err != nil {
+		return nil, false, err
+	}
+	done := map[int64]time.Time{}
+	if initialized {
+		if done, err = appliedVersions(ctx, conn); err != nil {
+			return nil, false, err
+		}
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Migration: migration}
+		if appliedAt, ok := done[migration.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, initialized, nil
+}
+
+// Pending returns how many migrations have not been applied, or
+// ErrNotInitialized for a database that has never been migrated. Like
+// Status, it only reads from the database.
+func (m *Migrator) Pending(ctx context.Context) (int, error) {
+	statuses, initialized, err := m.status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !initialized {
+		return 0, ErrNotInitialized
+	}
+
+	pending := 0
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// apply runs a migration script and the bookkeeping statement in one transaction
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration, script string, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %04d_%s failed: %w", migration.Version, migration.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return fmt.Errorf("failed to record migration %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+	return tx.Commit()
+}
+
+// withLock runs fn on a single connection while holding the migration
+// advisory lock. SQLite has no advisory locks; it lets one connection write
+// at a time, which keeps each migration's transaction to itself.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.dialect == Postgres {
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey)
+	}
+
+	if err := ensureTable(ctx, conn, m.dialect); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// ensureTable creates the table that records applied migrations. SQLite
+// drivers only read DATETIME columns back as timestamps.
+func ensureTable(ctx context.Context, conn *sql.Conn, dialect string) error {
+	timestamp := "TIMESTAMPTZ"
+	if dialect == SQLite {
+		timestamp = "DATETIME"
+	}
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    BIGINT PRIMARY KEY,
+    name       TEXT NOT NULL,
+    applied_at `+timestamp+` NOT NULL
+)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// tableExists reports whether the table that records migrations exists
+func tableExists(ctx context.Context, conn *sql.Conn, dialect string) (bool, error) {
+	query := "SELECT to_regclass('schema_migrations') IS NOT NULL"
+	if dialect == SQLite {
+		query = "SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'"
+	}
+	var exists bool
+	if err := conn.QueryRowContext(ctx, query).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to look up the schema_migrations table: %w", err)
+	}
+	return exists, nil
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		done[version] = appliedAt
+	}
+	return done, rows.Err()
+}
+
+// Create writes empty up and down files for a new migration into dir and
+// returns their paths. The version is one higher than the newest file in dir.
+// The files apply to every dialect; copies named NNNN_name.postgres.up.sql
+// or NNNN_name.sqlite.up.sql can be added for SQL that only one dialect runs.
+func Create(dir, name string) (string, string, error) {
+	name = strings.Trim(namePattern.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", "", fmt.Errorf("migration name is required")
+	}
+
+	existing, err := load(os.DirFS(dir), ".", "")
+	if err != nil {
+		return "", "", err
+	}
+	var version int64 = 1
+	if len(existing) > 0 {
+		version = existing[len(existing)-1].Version + 1
+	}
+
+	base := filepath.Join(dir, fmt.Sprintf("%04d_%s", version, name))
+	up, down := base+".up.sql", base+".down.sql"
+	header := fmt.Sprintf("-- %04d_%s\n", version, name)
+	if err := os.WriteFile(up, []byte(header), 0o644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(down, []byte(header), 0o644); err != nil {
+		return "", "", err
+	}
+	return up, down, nil
+}
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_users_email_verification_hash;
+ALTER TABLE users DROP COLUMN IF EXISTS email_verification_sent;
+ALTER TABLE users DROP COLUMN IF EXISTS email_verification_hash;
+ALTER TABLE users DROP COLUMN IF EXISTS pending_email;
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified;
+ALTER TABLE users DROP COLUMN IF EXISTS avatar;
+ALTER TABLE users DROP COLUMN IF EXISTS social_links;
+ALTER TABLE users DROP COLUMN IF EXISTS website;
+ALTER TABLE users DROP COLUMN IF EXISTS bio;
+ALTER TABLE users DROP COLUMN IF EXISTS display_name;
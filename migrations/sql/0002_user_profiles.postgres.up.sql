@@ -0,0 +1,10 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name VARCHAR(100);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bio TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS website VARCHAR(255);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS social_links TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar VARCHAR(100);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS pending_email TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verification_hash TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verification_sent TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS idx_users_email_verification_hash ON users (email_verification_hash);
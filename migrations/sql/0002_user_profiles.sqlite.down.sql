@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_users_email_verification_hash;
+ALTER TABLE users DROP COLUMN email_verification_sent;
+ALTER TABLE users DROP COLUMN email_verification_hash;
+ALTER TABLE users DROP COLUMN pending_email;
+ALTER TABLE users DROP COLUMN email_verified;
+ALTER TABLE users DROP COLUMN avatar;
+ALTER TABLE users DROP COLUMN social_links;
+ALTER TABLE users DROP COLUMN website;
+ALTER TABLE users DROP COLUMN bio;
+ALTER TABLE users DROP COLUMN display_name;
@@ -0,0 +1,10 @@
+ALTER TABLE users ADD COLUMN display_name VARCHAR(100);
+ALTER TABLE users ADD COLUMN bio TEXT;
+ALTER TABLE users ADD COLUMN website VARCHAR(255);
+ALTER TABLE users ADD COLUMN social_links TEXT;
+ALTER TABLE users ADD COLUMN avatar VARCHAR(100);
+ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE users ADD COLUMN pending_email TEXT;
+ALTER TABLE users ADD COLUMN email_verification_hash TEXT;
+ALTER TABLE users ADD COLUMN email_verification_sent DATETIME;
+CREATE INDEX IF NOT EXISTS idx_users_email_verification_hash ON users (email_verification_hash);
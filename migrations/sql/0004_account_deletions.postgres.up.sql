@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS account_deletions (
+    id                BIGSERIAL PRIMARY KEY,
+    created_at        TIMESTAMPTZ,
+    updated_at        TIMESTAMPTZ,
+    deleted_at        TIMESTAMPTZ,
+    user_id           BIGINT NOT NULL,
+    username          TEXT NOT NULL,
+    requested_by_id   BIGINT NOT NULL,
+    mode              TEXT NOT NULL,
+    transfer_to_id    BIGINT,
+    status            TEXT NOT NULL,
+    confirmation_hash TEXT,
+    confirmed_at      TIMESTAMPTZ,
+    execute_after     TIMESTAMPTZ,
+    completed_at      TIMESTAMPTZ,
+    error             TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_account_deletions_deleted_at ON account_deletions (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_account_deletions_user_id ON account_deletions (user_id);
+CREATE INDEX IF NOT EXISTS idx_account_deletions_status ON account_deletions (status);
+CREATE INDEX IF NOT EXISTS idx_account_deletions_confirmation_hash ON account_deletions (confirmation_hash);
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS account_deletions (
+    id                INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at        DATETIME,
+    updated_at        DATETIME,
+    deleted_at        DATETIME,
+    user_id           INTEGER NOT NULL,
+    username          TEXT NOT NULL,
+    requested_by_id   INTEGER NOT NULL,
+    mode              TEXT NOT NULL,
+    transfer_to_id    INTEGER,
+    status            TEXT NOT NULL,
+    confirmation_hash TEXT,
+    confirmed_at      DATETIME,
+    execute_after     DATETIME,
+    completed_at      DATETIME,
+    error             TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_account_deletions_deleted_at ON account_deletions (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_account_deletions_user_id ON account_deletions (user_id);
+CREATE INDEX IF NOT EXISTS idx_account_deletions_status ON account_deletions (status);
+CREATE INDEX IF NOT EXISTS idx_account_deletions_confirmation_hash ON account_deletions (confirmation_hash);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS data_exports (
+    id              BIGSERIAL PRIMARY KEY,
+    created_at      TIMESTAMPTZ,
+    updated_at      TIMESTAMPTZ,
+    deleted_at      TIMESTAMPTZ,
+    user_id         BIGINT NOT NULL,
+    requested_by_id BIGINT NOT NULL,
+    status          TEXT NOT NULL,
+    file_path       TEXT,
+    size            BIGINT,
+    completed_at    TIMESTAMPTZ,
+    expires_at      TIMESTAMPTZ,
+    error           TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_data_exports_deleted_at ON data_exports (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_data_exports_user_id ON data_exports (user_id);
+CREATE INDEX IF NOT EXISTS idx_data_exports_status ON data_exports (status);
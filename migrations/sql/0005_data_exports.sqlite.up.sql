@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS data_exports (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at      DATETIME,
+    updated_at      DATETIME,
+    deleted_at      DATETIME,
+    user_id         INTEGER NOT NULL,
+    requested_by_id INTEGER NOT NULL,
+    status          TEXT NOT NULL,
+    file_path       TEXT,
+    size            INTEGER,
+    completed_at    DATETIME,
+    expires_at      DATETIME,
+    error           TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_data_exports_deleted_at ON data_exports (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_data_exports_user_id ON data_exports (user_id);
+CREATE INDEX IF NOT EXISTS idx_data_exports_status ON data_exports (status);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS media (
+    id           BIGSERIAL PRIMARY KEY,
+    created_at   TIMESTAMPTZ,
+    updated_at   TIMESTAMPTZ,
+    deleted_at   TIMESTAMPTZ,
+    user_id      BIGINT NOT NULL,
+    key          TEXT NOT NULL,
+    filename     TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size         BIGINT NOT NULL,
+    width        BIGINT,
+    height       BIGINT
+);
+CREATE INDEX IF NOT EXISTS idx_media_deleted_at ON media (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_media_user_id ON media (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_media_key ON media (key);
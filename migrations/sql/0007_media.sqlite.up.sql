@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS media (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at   DATETIME,
+    updated_at   DATETIME,
+    deleted_at   DATETIME,
+    user_id      INTEGER NOT NULL,
+    key          TEXT NOT NULL,
+    filename     TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size         INTEGER NOT NULL,
+    width        INTEGER,
+    height       INTEGER
+);
+CREATE INDEX IF NOT EXISTS idx_media_deleted_at ON media (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_media_user_id ON media (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_media_key ON media (key);
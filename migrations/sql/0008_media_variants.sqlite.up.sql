@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS media_variants (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at   DATETIME,
+    media_id     INTEGER NOT NULL,
+    key          TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size         INTEGER NOT NULL,
+    width        INTEGER NOT NULL,
+    height       INTEGER NOT NULL,
+    CONSTRAINT fk_media_variants FOREIGN KEY (media_id) REFERENCES media (id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_media_variants_media_id ON media_variants (media_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_media_variants_key ON media_variants (key);
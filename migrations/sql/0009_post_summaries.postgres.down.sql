@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_posts_cover_media_id;
+ALTER TABLE posts DROP COLUMN IF EXISTS reading_time_minutes;
+ALTER TABLE posts DROP COLUMN IF EXISTS word_count;
+ALTER TABLE posts DROP COLUMN IF EXISTS cover_media_id;
+ALTER TABLE posts DROP COLUMN IF EXISTS custom_excerpt;
+ALTER TABLE posts DROP COLUMN IF EXISTS excerpt;
@@ -0,0 +1,6 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS excerpt TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS custom_excerpt BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS cover_media_id BIGINT;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS word_count BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS reading_time_minutes BIGINT NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_posts_cover_media_id ON posts (cover_media_id);
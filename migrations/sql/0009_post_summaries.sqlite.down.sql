@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_posts_cover_media_id;
+ALTER TABLE posts DROP COLUMN reading_time_minutes;
+ALTER TABLE posts DROP COLUMN word_count;
+ALTER TABLE posts DROP COLUMN cover_media_id;
+ALTER TABLE posts DROP COLUMN custom_excerpt;
+ALTER TABLE posts DROP COLUMN excerpt;
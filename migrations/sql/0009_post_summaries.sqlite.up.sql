@@ -0,0 +1,6 @@
+ALTER TABLE posts ADD COLUMN excerpt TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN custom_excerpt BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE posts ADD COLUMN cover_media_id INTEGER;
+ALTER TABLE posts ADD COLUMN word_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN reading_time_minutes INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_posts_cover_media_id ON posts (cover_media_id);
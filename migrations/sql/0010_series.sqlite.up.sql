@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS series (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at  DATETIME,
+    updated_at  DATETIME,
+    user_id     INTEGER NOT NULL,
+    title       TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    CONSTRAINT fk_users_series FOREIGN KEY (user_id) REFERENCES users (id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_series_user_id ON series (user_id);
+
+CREATE TABLE IF NOT EXISTS series_posts (
+    series_id INTEGER NOT NULL,
+    position  INTEGER NOT NULL,
+    post_id   INTEGER NOT NULL,
+    PRIMARY KEY (series_id, position),
+    CONSTRAINT fk_series_posts FOREIGN KEY (series_id) REFERENCES series (id) ON UPDATE CASCADE ON DELETE CASCADE,
+    CONSTRAINT fk_series_posts_post FOREIGN KEY (post_id) REFERENCES posts (id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_series_posts_post_id ON series_posts (post_id);
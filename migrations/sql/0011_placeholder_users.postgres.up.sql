@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS placeholder BOOLEAN NOT NULL DEFAULT false;
+-- The placeholder used to be found by its username alone; adopt it only if
+-- it also has the address it was created with
+UPDATE users SET placeholder = true WHERE username = 'deleted-user' AND email = 'deleted-user@users.invalid';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_placeholder ON users (placeholder) WHERE placeholder;
@@ -0,0 +1 @@
+ALTER TABLE data_exports ADD COLUMN IF NOT EXISTS started_at TIMESTAMPTZ;
@@ -0,0 +1 @@
+ALTER TABLE data_exports DROP COLUMN started_at;
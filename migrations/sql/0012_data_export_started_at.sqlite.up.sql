@@ -0,0 +1 @@
+ALTER TABLE data_exports ADD COLUMN started_at DATETIME;
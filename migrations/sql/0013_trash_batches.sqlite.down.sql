@@ -0,0 +1,2 @@
+ALTER TABLE comments DROP COLUMN trash_batch;
+ALTER TABLE posts DROP COLUMN trash_batch;
@@ -0,0 +1,6 @@
+ALTER TABLE posts ADD COLUMN trash_batch TEXT NOT NULL DEFAULT '';
+ALTER TABLE comments ADD COLUMN trash_batch TEXT NOT NULL DEFAULT '';
+-- Rows used to be restored together when their deletion timestamps were
+-- equal; the timestamp stands in as the batch of rows already in the trash
+UPDATE posts SET trash_batch = CAST(deleted_at AS TEXT) WHERE deleted_at IS NOT NULL;
+UPDATE comments SET trash_batch = CAST(deleted_at AS TEXT) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Account deletion modes
+const (
+	DeletionModeTransfer  = "transfer"
+	DeletionModeAnonymize = "anonymize"
+	DeletionModePurge     = "purge"
+)
+
+// Account deletion statuses
+const (
+	DeletionPendingConfirmation = "pending_confirmation"
+	DeletionScheduled           = "scheduled"
+	DeletionCompleted           = "completed"
+	DeletionCancelled           = "cancelled"
+	DeletionFailed              = "failed"
+	// DeletionProcessing is only seen inside the transaction that carries
+	// out a deletion, where it keeps other workers from running it as well
+	DeletionProcessing = "processing"
+)
+
+// AccountDeletion records a request to delete a user account. The request
+// outlives the account, so it keeps a copy of the username instead of a
+// foreign key.
+type AccountDeletion struct {
+	gorm.Model
+	UserID           uint   `gorm:"not null;index"`
+	Username         string `gorm:"not null"`
+	RequestedByID    uint   `gorm:"not null"`
+	Mode             string `gorm:"not null"`
+	TransferToID     *uint
+	Status           string `gorm:"not null;index"`
+	ConfirmationHash string `gorm:"index" json:"-"`
+	ConfirmedAt      *time.Time
+	ExecuteAfter     *time.Time
+	CompletedAt      *time.Time
+	Error            string
+}
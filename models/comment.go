@@ -9,4 +9,7 @@ type Comment struct {
 	Post   Post   `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 	UserID uint   `gorm:"not null"`
 	User   User   `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	// TrashBatch is the batch of the post the comment was trashed with, or
+	// empty when it was deleted on its own
+	TrashBatch string `gorm:"not null;default:''" json:"-"`
 }
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Data export statuses
+const (
+	ExportPending    = "pending"
+	ExportProcessing = "processing"
+	ExportReady      = "ready"
+	ExportFailed     = "failed"
+	ExportExpired    = "expired"
+)
+
+// DataExport is a request for an archive of everything stored about a user
+type DataExport struct {
+	gorm.Model
+	UserID        uint   `gorm:"not null;index"`
+	RequestedByID uint   `gorm:"not null"`
+	Status        string `gorm:"not null;index"`
+	FilePath      string `json:"-"`
+	// StartedAt is when a worker claimed the export for processing
+	StartedAt   *time.Time
+	Size        int64
+	CompletedAt *time.Time
+	ExpiresAt   *time.Time
+	Error       string
+}
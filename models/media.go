@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Media is a file uploaded by a user, such as an image to embed in a post.
+// The bytes live in the media storage under Key.
+type Media struct {
+	gorm.Model
+	UserID      uint   `gorm:"not null;index"`
+	Key         string `gorm:"not null;uniqueIndex" json:"-"`
+	Filename    string `gorm:"not null"`
+	ContentType string `gorm:"not null"`
+	Size        int64  `gorm:"not null"`
+	// Width and Height are set for images
+	Width  int
+	Height int
+}
+
+// MediaVariant is a resized or converted copy of an uploaded image. Variants
+// are generated on first request and kept in the media storage under Key.
+type MediaVariant struct {
+	ID          uint `gorm:"primarykey"`
+	CreatedAt   time.Time
+	MediaID     uint   `gorm:"not null;index"`
+	Key         string `gorm:"not null;uniqueIndex"`
+	ContentType string `gorm:"not null"`
+	Size        int64  `gorm:"not null"`
+	Width       int    `gorm:"not null"`
+	Height      int    `gorm:"not null"`
+}
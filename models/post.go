@@ -1,12 +1,49 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"TechBlog/markdown"
+
+	"gorm.io/gorm"
+)
+
+// ExcerptLength caps the length of post excerpts in characters
+const ExcerptLength = 280
 
 type Post struct {
 	gorm.Model
-	Title    string    `gorm:"not null"`
-	Body     string    `gorm:"type:text;not null"`
-	UserID   uint      `gorm:"not null"`
-	User     User      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
-	Comments []Comment `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Title string `gorm:"not null"`
+	Body  string `gorm:"type:text;not null"`
+	// Excerpt is shown instead of Body in listings. Unless CustomExcerpt is
+	// set, it is taken from the first paragraph of Body whenever the post is saved.
+	Excerpt       string `gorm:"type:text;not null;default:''"`
+	CustomExcerpt bool   `gorm:"not null;default:false"`
+	// CoverMediaID is the uploaded image shown on listing cards, if any
+	CoverMediaID *uint `gorm:"index"`
+	// WordCount and ReadingTimeMinutes are computed from Body on save
+	WordCount          int       `gorm:"not null;default:0"`
+	ReadingTimeMinutes int       `gorm:"not null;default:0"`
+	UserID             uint      `gorm:"not null"`
+	User               User      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Comments           []Comment `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	// TrashBatch links a trashed post to the comments deleted with it
+	TrashBatch string `gorm:"not null;default:''" json:"-"`
+}
+
+// BeforeSave keeps the fields derived from the body up to date. Updates of
+// single columns leave Body empty and are skipped.
+func (p *Post) BeforeSave(tx *gorm.DB) (err error) {
+	if p.Body != "" {
+		p.Summarize()
+	}
+	return nil
+}
+
+// Summarize derives the excerpt, word count and reading time from the body
+func (p *Post) Summarize() {
+	summary := markdown.Summarize(p.Body, ExcerptLength)
+	if !p.CustomExcerpt {
+		p.Excerpt = summary.Excerpt
+	}
+	p.WordCount = summary.WordCount
+	p.ReadingTimeMinutes = summary.ReadingTimeMinutes
 }
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Series is an ordered collection of posts by one author, such as a tutorial
+// in several parts. A post belongs to at most one series.
+type Series struct {
+	ID          uint `gorm:"primarykey"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uint   `gorm:"not null;index"`
+	Title       string `gorm:"not null"`
+	Description string `gorm:"type:text;not null;default:''"`
+	// Posts are the members of the series in reading order
+	Posts []SeriesPost `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// SeriesPost places a post at a position of a series, counting from 1
+type SeriesPost struct {
+	SeriesID uint `gorm:"primaryKey"`
+	Position int  `gorm:"primaryKey"`
+	PostID   uint `gorm:"not null;uniqueIndex"`
+	Post     Post `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
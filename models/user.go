@@ -1,26 +1,64 @@
 package models
 
 import (
+	"time"
+
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
+// DeletedUsername is the username of the placeholder account that
+// anonymized content is attributed to. Nobody can sign up with it.
+const DeletedUsername = "deleted-user"
+
 type User struct {
 	gorm.Model
-	Username string    `gorm:"unique;not null"`
-	Email    string    `gorm:"unique;not null"`
-	Password string    `gorm:"not null"`
+	Username    string            `gorm:"unique;not null"`
+	Email       string            `gorm:"unique;not null"`
+	Password    string            `gorm:"not null" json:"-"`
+	DisplayName string            `gorm:"size:100"`
+	Bio         string            `gorm:"type:text"`
+	Website     string            `gorm:"size:255"`
+	SocialLinks map[string]string `gorm:"type:text;serializer:json"`
+	Avatar      string            `gorm:"size:100"`
+	IsAdmin     bool              `gorm:"not null;default:false"`
+	// Placeholder marks the account that anonymized content belongs to
+	Placeholder bool `gorm:"not null;default:false" json:"-"`
+
+	EmailVerified         bool       `gorm:"not null;default:false"`
+	PendingEmail          string     `json:"-"`
+	EmailVerificationHash string     `gorm:"index" json:"-"`
+	EmailVerificationSent *time.Time `json:"-"`
+	// SessionVersion changes with the password. Sessions started under
+	// another version are no longer accepted.
+	SessionVersion uint `gorm:"not null;default:0" json:"-"`
+
+	// passwordChanged marks Password as a new plain text password that
+	// BeforeSave must hash
+	passwordChanged bool
+
 	Posts    []Post    `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 	Comments []Comment `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 }
 
+// SetPassword replaces the user's password, which is hashed when the user is
+// saved, and signs out the user's other sessions
+func (u *User) SetPassword(password string) {
+	u.Password = password
+	u.passwordChanged = true
+	u.SessionVersion++
+}
+
 func (u *User) BeforeSave(tx *gorm.DB) (err error) {
-	if len(u.Password) > 0 {
+	// Only passwords set through SetPassword are hashed, Password otherwise
+	// holds the stored hash
+	if u.passwordChanged {
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
 		if err != nil {
 			return err
 		}
 		u.Password = string(hashedPassword)
+		u.passwordChanged = false
 	}
 	return nil
 }
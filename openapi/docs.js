@@ -0,0 +1,276 @@
+// Renders the OpenAPI document next to the page as a browsable page with a form to try each
+// operation. Requests are sent from the browser, so they carry the session
+// cookie of the logged-in user.
+(function () {
+  'use strict';
+
+  var methods = ['get', 'post', 'put', 'patch', 'delete'];
+  var spec;
+
+  function el(tag, attrs, children) {
+    var node = document.createElement(tag);
+    Object.keys(attrs || {}).forEach(function (key) {
+      if (key === 'text') {
+        node.textContent = attrs[key];
+      } else {
+        node.setAttribute(key, attrs[key]);
+      }
+    });
+    (children || []).forEach(function (child) {
+      if (child) {
+        node.appendChild(typeof child === 'string' ? document.createTextNode(child) : child);
+      }
+    });
+    return node;
+  }
+
+  // resolve follows a local $ref such as #/components/schemas/Post
+  function resolve(value) {
+    if (!value || !value.$ref) {
+      return value;
+    }
+    return value.$ref.slice(2).split('/').reduce(function (node, key) {
+      return node[key];
+    }, spec);
+  }
+
+  function refName(value) {
+    return value && value.$ref ? value.$ref.split('/').pop() : '';
+  }
+
+  // example builds a sample value for a schema, stopping at nested models
+  function example(schema, depth) {
+    var name = refName(schema);
+    schema = resolve(schema) || {};
+    if (depth > 2 && name) {
+      return '<' + name + '>';
+    }
+    if (schema.examples) {
+      return schema.examples[0];
+    }
+    if (schema.allOf) {
+      return schema.allOf.reduce(function (merged, part) {
+        return Object.assign(merged, example(part, depth));
+      }, {});
+    }
+    var type = Array.isArray(schema.type) ? schema.type[0] : schema.type;
+    if (schema.enum) {
+      return schema.enum[0];
+    }
+    if (schema.const !== undefined) {
+      return schema.const;
+    }
+    switch (type) {
+      case 'object':
+        var result = {};
+        Object.keys(schema.properties || {}).forEach(function (key) {
+          result[key] = example(schema.properties[key], depth + 1);
+        });
+        if (!schema.properties && schema.additionalProperties) {
+          result.key = example(schema.additionalProperties, depth + 1);
+        }
+        return result;
+      case 'array':
+        return [example(schema.items, depth + 1)];
+      case 'integer':
+      case 'number':
+        return schema.default !== undefined ? schema.default : 0;
+      case 'boolean':
+        return false;
+      case 'string':
+        if (schema.format === 'date-time') {
+          return '2024-01-01T00:00:00Z';
+        }
+        return schema.format || 'string';
+    }
+    return null;
+  }
+
+  // serverURL is the URL the paths of pathItem are relative to
+  function serverURL(pathItem) {
+    var servers = pathItem.servers || spec.servers || [{ url: '' }];
+    return servers[0].url.replace(/\/$/, '');
+  }
+
+  function parameters(pathItem, operation) {
+    return (pathItem.parameters || []).concat(operation.parameters || []).map(resolve);
+  }
+
+  function renderParameters(params) {
+    if (!params.length) {
+      return null;
+    }
+    var rows = params.map(function (param) {
+      return el('tr', {}, [
+        el('td', { text: param.name + (param.required ? ' *' : '') }),
+        el('td', { text: param.in }),
+        el('td', { text: JSON.stringify(resolve(param.schema)) })
+      ]);
+    });
+    return el('div', {}, [el('h4', { text: 'Parameters' }), el('table', {}, rows)]);
+  }
+
+  function jsonBody(content) {
+    var media = content && (content['application/json'] || content['application/problem+json']);
+    return media ? example(media.schema, 0) : undefined;
+  }
+
+  function renderResponses(responses) {
+    var rows = Object.keys(responses || {}).map(function (status) {
+      var response = resolve(responses[status]);
+      var sample = jsonBody(response.content);
+      return el('tr', {}, [
+        el('td', { text: status }),
+        el('td', {}, [
+          response.description,
+          sample !== undefined ? el('pre', { text: JSON.stringify(sample, null, 2) }) : null
+        ])
+      ]);
+    });
+    return el('div', {}, [el('h4', { text: 'Responses' }), el('table', {}, rows)]);
+  }
+
+  // renderTryIt builds a form that sends the operation from the browser
+  function renderTryIt(method, url, params, operation) {
+    var inputs = {};
+    var fields = params.filter(function (param) {
+      return param.in === 'path' || param.in === 'query';
+    }).map(function (param) {
+      inputs[param.name] = el('input', { placeholder: param.name + ' (' + param.in + ')' });
+      return inputs[param.name];
+    });
+
+    var body = operation.requestBody && resolve(operation.requestBody);
+    var textarea = null;
+    if (body && body.content && body.content['application/json']) {
+      textarea = el('textarea', { rows: 6 });
+      textarea.value = JSON.stringify(jsonBody(body.content), null, 2);
+    }
+
+    var output = el('pre', { text: '' });
+    var button = el('button', { type: 'button', text: 'Send' });
+    button.addEventListener('click', function () {
+      var target = url;
+      var query = new URLSearchParams();
+      params.forEach(function (param) {
+        var value = inputs[param.name] ? inputs[param.name].value : '';
+        if (param.in === 'path') {
+          target = target.replace('{' + param.name + '}', encodeURIComponent(value));
+        } else if (param.in === 'query' && value !== '') {
+          query.append(param.name, value);
+        }
+      });
+      if (query.toString()) {
+        target += '?' + query.toString();
+      }
+      var init = { method: method.toUpperCase(), credentials: 'same-origin', headers: {} };
+      if (textarea) {
+        init.headers['Content-Type'] = 'application/json';
+        init.body = textarea.value;
+      }
+      output.textContent = 'Sending…';
+      withCSRFToken(init).then(function (init) {
+        return fetch(target, init);
+      }).then(function (response) {
+        return response.text().then(function (text) {
+          try {
+            text = JSON.stringify(JSON.parse(text), null, 2);
+          } catch (e) {
+            // not JSON, show it as is
+          }
+          output.textContent = response.status + ' ' + response.statusText + '\n\n' + text;
+        });
+      }).catch(function (err) {
+        output.textContent = String(err);
+      });
+    });
+
+    return el('div', {}, [el('h4', { text: 'Try it' })].concat(fields, [textarea, button, output]));
+  }
+
+  // withCSRFToken adds the CSRF token of the session to requests that change
+  // data. It is fetched every time because logging in replaces it.
+  function withCSRFToken(init) {
+    if (init.method === 'GET' || init.method === 'HEAD') {
+      return Promise.resolve(init);
+    }
+    return fetch('csrf', { credentials: 'same-origin' }).then(function (response) {
+      return response.json();
+    }).then(function (body) {
+      init.headers['X-CSRF-Token'] = body.csrf_token;
+      return init;
+    });
+  }
+
+  function renderOperation(method, path, pathItem, operation) {
+    var requirements = operation.security || spec.security || [];
+    var secured = requirements.length > 0 && requirements.every(function (requirement) {
+      return 'session' in requirement;
+    });
+    var url = serverURL(pathItem) + path;
+    var params = parameters(pathItem, operation);
+    var body = operation.requestBody && resolve(operation.requestBody);
+    var bodyContent = body && body.content && Object.keys(body.content)[0];
+
+    return el('details', {}, [
+      el('summary', {}, [
+        el('span', { class: 'method ' + method, text: method.toUpperCase() }),
+        el('span', { class: 'path', text: url }),
+        ' ',
+        operation.summary || '',
+        secured ? el('span', { class: 'lock', text: ' (login required)' }) : null
+      ]),
+      el('div', { class: 'operation' }, [
+        operation.description ? el('p', { text: operation.description }) : null,
+        renderParameters(params),
+        bodyContent ? el('h4', { text: 'Request body (' + bodyContent + ')' }) : null,
+        bodyContent ? el('pre', { text: JSON.stringify(example(body.content[bodyContent].schema, 0), null, 2) }) : null,
+        renderResponses(operation.responses),
+        renderTryIt(method, url, params, operation)
+      ])
+    ]);
+  }
+
+  function render() {
+    document.title = spec.info.title;
+    document.getElementById('title').textContent = spec.info.title + ' ' + spec.info.version;
+
+    var groups = {};
+    var order = (spec.tags || []).map(function (tag) { return tag.name; });
+    Object.keys(spec.paths).forEach(function (path) {
+      var pathItem = spec.paths[path];
+      methods.forEach(function (method) {
+        var operation = pathItem[method];
+        if (!operation) {
+          return;
+        }
+        var tag = (operation.tags || ['Other'])[0];
+        if (order.indexOf(tag) < 0) {
+          order.push(tag);
+        }
+        (groups[tag] = groups[tag] || []).push(renderOperation(method, path, pathItem, operation));
+      });
+    });
+
+    var root = document.getElementById('docs');
+    root.textContent = '';
+    root.appendChild(el('p', { text: spec.info.description }));
+    order.forEach(function (tag) {
+      if (groups[tag]) {
+        root.appendChild(el('h2', { text: tag }));
+        groups[tag].forEach(function (node) { root.appendChild(node); });
+      }
+    });
+  }
+
+  fetch('openapi.json').then(function (response) {
+    return response.json();
+  }).then(function (doc) {
+    spec = doc;
+    render();
+  }).catch(function (err) {
+    var root = document.getElementById('docs');
+    root.textContent = '';
+    root.appendChild(el('p', { class: 'error', text: 'Failed to load the API description: ' + err }));
+  });
+})();
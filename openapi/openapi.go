@@ -0,0 +1,68 @@
+// Package openapi holds the OpenAPI document of the API and the page that
+// renders it. The document is written by hand in openapi.yaml; a test in the
+// main package fails when a registered route is missing from it.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	//go:embed openapi.yaml
+	specYAML []byte
+
+	//go:embed docs.html
+	docsHTML string
+
+	//go:embed docs.js
+	DocsJS []byte
+)
+
+// docsPage is docs.html, whose inline style and script tags carry the CSP
+// nonce of the request
+var docsPage = template.Must(template.New("docs.html").Parse(docsHTML))
+
+var (
+	loadOnce sync.Once
+	spec     map[string]interface{}
+	specJSON []byte
+	loadErr  error
+)
+
+// load parses the embedded document once
+func load() {
+	loadOnce.Do(func() {
+		if loadErr = yaml.Unmarshal(specYAML, &spec); loadErr != nil {
+			loadErr = fmt.Errorf("parse openapi.yaml: %w", loadErr)
+			return
+		}
+		if specJSON, loadErr = json.Marshal(spec); loadErr != nil {
+			loadErr = fmt.Errorf("encode openapi.yaml: %w", loadErr)
+		}
+	})
+}
+
+// JSON returns the document encoded as JSON
+func JSON() ([]byte, error) {
+	load()
+	return specJSON, loadErr
+}
+
+// Spec returns the decoded document. Callers must not modify it.
+func Spec() (map[string]interface{}, error) {
+	load()
+	return spec, loadErr
+}
+
+// RenderDocs writes the page that renders the document, with nonce on its
+// inline style and script tags
+func RenderDocs(w io.Writer, nonce string) error {
+	return docsPage.Execute(w, struct{ Nonce string }{nonce})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"TechBlog/openapi"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// pathParam matches a gin path parameter such as :postId
+var pathParam = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+// specMethods are the operations of an OpenAPI path item
+var specMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// TestOpenAPICoversRoutes fails when a registered route is missing from the
+// OpenAPI document, or when the document describes a route that does not exist
+func TestOpenAPICoversRoutes(t *testing.T) {
+	app := newTestApp(t)
+	spec, err := openapi.Spec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths, ok := spec["paths"].(map[string]interface{})
+	if !ok {
+		t.Fatal("the document has no paths")
+	}
+
+	// The paths are relative to the server URL of the document, except for
+	// the health and metrics routes at the root
+	servers, _ := spec["servers"].([]interface{})
+	if len(servers) == 0 {
+		t.Fatal("the document has no servers")
+	}
+	prefix := servers[0].(map[string]interface{})["url"].(string)
+
+	registered := map[string]bool{}
+	var missing []string
+	for _, route := range app.router.Routes() {
+		// Static files and the HEAD routes gin adds for them are not part of the API
+		if route.Method == http.MethodHead || strings.Contains(route.Path, "*") {
+			continue
+		}
+		path := route.Path
+		switch {
+		case strings.HasPrefix(path, prefix+"/"):
+			path = strings.TrimPrefix(path, prefix)
+		case strings.HasPrefix(path, "/api/"):
+			// The deprecated alias and other versions, see TestLegacyAPIAlias
+			continue
+		}
+		path = pathParam.ReplaceAllString(path, "{$1}")
+		method := strings.ToLower(route.Method)
+		registered[method+" "+path] = true
+
+		item, _ := paths[path].(map[string]interface{})
+		if _, ok := item[method]; !ok {
+			missing = append(missing, route.Method+" "+path)
+		}
+	}
+
+	var stale []string
+	for path, item := range paths {
+		for _, method := range specMethods {
+			if _, ok := item.(map[string]interface{})[method]; ok && !registered[method+" "+path] {
+				stale = append(stale, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+
+	sort.Strings(missing)
+	sort.Strings(stale)
+	for _, route := range missing {
+		t.Errorf("%s is registered but missing from openapi/openapi.yaml", route)
+	}
+	for _, route := range stale {
+		t.Errorf("%s is described in openapi/openapi.yaml but not registered", route)
+	}
+}
+
+// TestOpenAPIDocs checks that the document and the docs page are served
+func TestOpenAPIDocs(t *testing.T) {
+	app := newTestApp(t)
+	client := app.client()
+
+	res := client.do(http.MethodGet, "/api/v1/openapi.json", nil)
+	res.expect(t, http.StatusOK)
+	if res.Body["openapi"] != "3.1.0" {
+		t.Errorf("openapi = %v, want 3.1.0", res.Body["openapi"])
+	}
+
+	for path, contentType := range map[string]string{
+		"/api/v1/docs":         "text/html",
+		"/api/v1/docs/docs.js": "text/javascript",
+	} {
+		res := client.do(http.MethodGet, path, nil)
+		res.expect(t, http.StatusOK)
+		if got := res.Header.Get("Content-Type"); !strings.HasPrefix(got, contentType) {
+			t.Errorf("%s: Content-Type = %q, want %s", path, got, contentType)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"TechBlog/config"
+	"TechBlog/models"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+const postUsage = `Usage: techblog post import [flags] -author <username> <file or directory>...
+
+Imports Markdown files as posts of the author. Directories are searched for
+*.md files. An optional YAML front matter block sets title, created_at and
+updated_at, which is the format of the posts in a personal data export.
+Without a title the first "# " heading or the file name is used.
+
+Flags:
+`
+
+// frontMatter holds the post fields read from a Markdown file's front matter
+type frontMatter struct {
+	Title     string    `yaml:"title"`
+	CreatedAt time.Time `yaml:"created_at"`
+	UpdatedAt time.Time `yaml:"updated_at"`
+}
+
+// runPost implements the post commands
+func runPost(args []string) {
+	if len(args) == 0 || args[0] != "import" {
+		fmt.Fprint(os.Stderr, postUsage)
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet("post import", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), postUsage)
+		fs.PrintDefaults()
+	}
+	author := fs.String("author", "", "username of the author of the imported posts")
+	dryRun := fs.Bool("dry-run", false, "parse the files and print the posts without saving them")
+
+	cfg, err := config.Load(fs, args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *author == "" || fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	files, err := markdownFiles(fs.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	posts := make([]models.Post, 0, len(files))
+	for _, file := range files {
+		post, err := readPostFile(file)
+		if err != nil {
+			log.Fatalf("%s: %v", file, err)
+		}
+		posts = append(posts, post)
+	}
+
+	if *dryRun {
+		for i, post := range posts {
+			fmt.Printf("%s: %q (%d characters)\n", files[i], post.Title, len(post.Body))
+		}
+		return
+	}
+
+	dbConfig := mustConnect(cfg)
+	defer dbConfig.Close()
+	requireMigrated(dbConfig)
+
+	var user models.User
+	if err := dbConfig.DB.Where("username = ?", *author).First(&user).Error; err != nil {
+		log.Fatalf("User %s not found", *author)
+	}
+
+	// Import all files or none of them
+	err = dbConfig.DB.Transaction(func(tx *gorm.DB) error {
+		for i := range posts {
+			posts[i].UserID = user.ID
+			if err := tx.Create(&posts[i]).Error; err != nil {
+				return fmt.Errorf("%s: %w", files[i], err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+
+	for i, post := range posts {
+		fmt.Printf("imported %s as post %d\n", files[i], post.ID)
+	}
+}
+
+// markdownFiles expands directories in paths to the Markdown files they contain
+func markdownFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(file string, entry os.DirEntry, err error) error {
+			if err == nil && !entry.IsDir() && strings.EqualFold(filepath.Ext(file), ".md") {
+				files = append(files, file)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// readPostFile parses a Markdown file with optional front matter into a post
+func readPostFile(file string) (models.Post, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return models.Post{}, err
+	}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+
+	var meta frontMatter
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		header, body, found := strings.Cut("\n"+rest, "\n---\n")
+		if !found {
+			return models.Post{}, fmt.Errorf("front matter is not closed with ---")
+		}
+		if err := yaml.Unmarshal([]byte(header), &meta); err != nil {
+			return models.Post{}, fmt.Errorf("invalid front matter: %w", err)
+		}
+		text = body
+	}
+	text = strings.TrimSpace(text)
+
+	if meta.Title == "" {
+		if strings.HasPrefix(text, "# ") {
+			heading, body, _ := strings.Cut(text, "\n")
+			meta.Title = strings.TrimSpace(strings.TrimPrefix(heading, "# "))
+			text = strings.TrimSpace(body)
+		} else {
+			meta.Title = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		}
+	}
+	if text == "" {
+		return models.Post{}, fmt.Errorf("post body is empty")
+	}
+
+	post := models.Post{Title: meta.Title, Body: text}
+	post.CreatedAt = meta.CreatedAt
+	post.UpdatedAt = meta.UpdatedAt
+	if post.UpdatedAt.IsZero() {
+		post.UpdatedAt = post.CreatedAt
+	}
+	return post, nil
+}
+
+// summarizeStoredPosts derives the excerpt, word count and reading time of
+// the posts saved before they were stored, and returns how many it updated
+func summarizeStoredPosts(db *gorm.DB) (int, error) {
+	var posts []models.Post
+	updated := 0
+	err := db.Unscoped().Select("id", "body", "excerpt", "custom_excerpt").
+		Where("word_count = 0").
+		FindInBatches(&posts, 100, func(tx *gorm.DB, _ int) error {
+			for i := range posts {
+				posts[i].Summarize()
+				if posts[i].WordCount == 0 {
+					continue
+				}
+				if err := db.Unscoped().Model(&posts[i]).UpdateColumns(map[string]interface{}{
+					"excerpt":              posts[i].Excerpt,
+					"word_count":           posts[i].WordCount,
+					"reading_time_minutes": posts[i].ReadingTimeMinutes,
+				}).Error; err != nil {
+					return err
+				}
+				updated++
+			}
+			return nil
+		}).Error
+	return updated, err
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DatabaseStore keeps the buckets in the rate_limit_buckets table, so that
+// every server instance using the database shares them. Times are stored as
+// Unix nanoseconds so that the arithmetic is the same on every driver.
+type DatabaseStore struct {
+	db *gorm.DB
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// bucketRow is a row of rate_limit_buckets
+type bucketRow struct {
+	Bucket     string `gorm:"primaryKey"`
+	Tokens     float64
+	RefilledAt int64
+	// ExpiresAt is when the bucket is full again and the row can be deleted
+	ExpiresAt int64
+}
+
+func (bucketRow) TableName() string {
+	return "rate_limit_buckets"
+}
+
+// NewDatabaseStore returns a store that keeps the buckets in db
+func NewDatabaseStore(db *gorm.DB) *DatabaseStore {
+	return &DatabaseStore{db: db}
+}
+
+// Take implements Store. The row is locked for the transaction on Postgres;
+// SQLite serializes writing transactions on its own.
+func (s *DatabaseStore) Take(ctx context.Context, key string, policy Policy, now time.Time) (bool, float64, error) {
+	var allowed bool
+	var tokens float64
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		row := bucketRow{Bucket: key, Tokens: float64(policy.Limit), RefilledAt: now.UnixNano(), ExpiresAt: now.UnixNano()}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&row).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("bucket = ?", key).Take(&row).Error; err != nil {
+			return err
+		}
+
+		tokens = policy.refill(row.Tokens, time.Unix(0, row.RefilledAt), now)
+		allowed = tokens >= 1
+		if allowed {
+			tokens--
+		}
+		return tx.Model(&bucketRow{}).Where("bucket = ?", key).Updates(map[string]interface{}{
+			"tokens":      tokens,
+			"refilled_at": now.UnixNano(),
+			"expires_at":  now.Add(policy.untilFull(tokens)).UnixNano(),
+		}).Error
+	})
+	if err != nil {
+		return false, 0, err
+	}
+
+	s.sweep(ctx, now)
+	return allowed, tokens, nil
+}
+
+// sweep deletes the buckets that have refilled completely, at most once per
+// sweepInterval per process
+func (s *DatabaseStore) sweep(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < sweepInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+
+	if err := s.db.WithContext(ctx).Where("expires_at <= ?", now.UnixNano()).Delete(&bucketRow{}).Error; err != nil {
+		slog.WarnContext(ctx, "failed to delete expired rate limit buckets", "error", err)
+	}
+}
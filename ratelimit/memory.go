@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the in-process stores forget full buckets
+const sweepInterval = time.Minute
+
+// MemoryStore keeps the buckets in the memory of the process, so every
+// server instance counts on its own
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+type memoryBucket struct {
+	tokens     float64
+	refilledAt time.Time
+	// fullAt is when the bucket is full again and can be forgotten
+	fullAt time.Time
+}
+
+// NewMemoryStore returns an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*memoryBucket{}}
+}
+
+// Take implements Store
+func (s *MemoryStore) Take(_ context.Context, key string, policy Policy, now time.Time) (bool, float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: float64(policy.Limit), refilledAt: now}
+		s.buckets[key] = bucket
+	}
+
+	bucket.tokens = policy.refill(bucket.tokens, bucket.refilledAt, now)
+	bucket.refilledAt = now
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	}
+	bucket.fullAt = now.Add(policy.untilFull(bucket.tokens))
+	return allowed, bucket.tokens, nil
+}
+
+// sweep forgets the buckets that have refilled completely
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, bucket := range s.buckets {
+		if !now.Before(bucket.fullAt) {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Package ratelimit throttles requests with token buckets. Every identity
+// gets a bucket per policy that holds up to Limit tokens and refills steadily
+// over Period; each request takes one token and is rejected when none is left.
+package ratelimit
+
+import (
+	"TechBlog/config"
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Policy is a named token bucket size and refill period
+type Policy struct {
+	Name   string
+	Limit  int
+	Period time.Duration
+}
+
+// perSecond is how many tokens the bucket regains per second
+func (p Policy) perSecond() float64 {
+	return float64(p.Limit) / p.Period.Seconds()
+}
+
+// refill returns the tokens of a bucket that held tokens at last, as of now
+func (p Policy) refill(tokens float64, last, now time.Time) float64 {
+	if elapsed := now.Sub(last).Seconds(); elapsed > 0 {
+		tokens += elapsed * p.perSecond()
+	}
+	return math.Min(tokens, float64(p.Limit))
+}
+
+// untilFull is how long a bucket holding tokens takes to be full again. Once
+// it is full the bucket can be forgotten.
+func (p Policy) untilFull(tokens float64) time.Duration {
+	return seconds((float64(p.Limit) - tokens) / p.perSecond())
+}
+
+// Store keeps the buckets. Take refills the bucket key of policy as of now
+// and removes a token if it holds one, atomically. It reports whether a token
+// was taken and how many are left.
+type Store interface {
+	Take(ctx context.Context, key string, policy Policy, now time.Time) (allowed bool, tokens float64, err error)
+}
+
+// Result is the outcome of a request against a policy
+type Result struct {
+	Allowed bool
+	Limit   int
+	// Remaining is the number of requests that would be allowed right now
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until the next request is allowed, when this one was not
+	RetryAfter time.Duration
+}
+
+// Limiter applies named policies using a store
+type Limiter struct {
+	store    Store
+	policies map[string]Policy
+	now      func() time.Time
+}
+
+// New returns a limiter with the given policies
+func New(store Store, policies ...Policy) *Limiter {
+	l := &Limiter{store: store, policies: map[string]Policy{}, now: time.Now}
+	for _, policy := range policies {
+		l.policies[policy.Name] = policy
+	}
+	return l
+}
+
+// FromConfig returns the configured limiter, or nil when rate limiting is
+// disabled. The database store uses db.
+func FromConfig(cfg config.RateLimitConfig, db *gorm.DB) (*Limiter, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	var store Store
+	switch cfg.Store {
+	case config.RateLimitStoreMemory:
+		store = NewMemoryStore()
+	case config.RateLimitStoreDatabase:
+		store = NewDatabaseStore(db)
+	case config.RateLimitStoreRedis:
+		redisStore, err := NewRedisStore(cfg.RedisURL)
+		if err != nil {
+			return nil, err
+		}
+		store = redisStore
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %q", cfg.Store)
+	}
+
+	var policies []Policy
+	for name, policy := range cfg.Policies {
+		policies = append(policies, Policy{Name: name, Limit: policy.Limit, Period: policy.Period()})
+	}
+	return New(store, policies...), nil
+}
+
+// Policy returns the policy with the given name
+func (l *Limiter) Policy(name string) (Policy, bool) {
+	policy, ok := l.policies[name]
+	return policy, ok
+}
+
+// Store returns the store of the limiter
+func (l *Limiter) Store() Store {
+	return l.store
+}
+
+// Allow takes a token from the bucket of identity under policy
+func (l *Limiter) Allow(ctx context.Context, policy Policy, identity string) (Result, error) {
+	allowed, tokens, err := l.store.Take(ctx, "ratelimit:"+policy.Name+":"+identity, policy, l.now())
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{
+		Allowed:   allowed,
+		Limit:     policy.Limit,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     policy.untilFull(tokens),
+	}
+	if !allowed {
+		result.RetryAfter = seconds((1 - tokens) / policy.perSecond())
+	}
+	return result, nil
+}
+
+// seconds converts a number of seconds to a duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
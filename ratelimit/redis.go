@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript refills and takes from a bucket stored as a hash with the
+// fields tokens and refilled_at in one atomic step. Times and the period are
+// in microseconds, and the key expires once the bucket is full again. The
+// token count crosses the script boundary as a string because Redis
+// truncates Lua numbers to integers.
+var takeScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local per_us = limit / tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'refilled_at')
+local tokens = tonumber(state[1]) or limit
+local refilled_at = tonumber(state[2]) or now
+if now > refilled_at then
+  tokens = math.min(limit, tokens + (now - refilled_at) * per_us)
+end
+
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', string.format('%.9f', tokens), 'refilled_at', string.format('%d', now))
+redis.call('PEXPIRE', KEYS[1], math.max(1, math.ceil((limit - tokens) / per_us / 1000)))
+return {allowed, string.format('%.9f', tokens)}
+`)
+
+// RedisStore keeps the buckets in Redis or a server compatible with it, so
+// that every server instance shares them
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore returns a store on the server at url, such as
+// redis://localhost:6379/0
+func NewRedisStore(url string) (*RedisStore, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	return &RedisStore{client: redis.NewClient(options)}, nil
+}
+
+// Ping checks that the server answers
+func (s *RedisStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+// Close closes the connections to the server
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
+
+// Take implements Store
+func (s *RedisStore) Take(ctx context.Context, key string, policy Policy, now time.Time) (bool, float64, error) {
+	reply, err := takeScript.Run(ctx, s.client, []string{key}, policy.Limit, policy.Period.Microseconds(), now.UnixMicro()).Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	if len(reply) != 2 {
+		return false, 0, fmt.Errorf("unexpected reply from the rate limit script: %v", reply)
+	}
+
+	allowed, _ := reply[0].(int64)
+	text, _ := reply[1].(string)
+	tokens, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return false, 0, fmt.Errorf("unexpected token count from the rate limit script: %q", text)
+	}
+	return allowed == 1, tokens, nil
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"TechBlog/models"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// CommentRepository stores comments
+type CommentRepository interface {
+	// GetOwned returns a comment only if it was written by the given user
+	GetOwned(ctx context.Context, id, userID uint) (models.Comment, error)
+	Create(ctx context.Context, comment *models.Comment) error
+	Update(ctx context.Context, comment *models.Comment) error
+	// Delete moves a comment to the trash
+	Delete(ctx context.Context, comment *models.Comment) error
+}
+
+type commentRepository struct {
+	db *gorm.DB
+}
+
+// NewCommentRepository returns a CommentRepository backed by db
+func NewCommentRepository(db *gorm.DB) CommentRepository {
+	return &commentRepository{db: db}
+}
+
+func (r *commentRepository) GetOwned(ctx context.Context, id, userID uint) (models.Comment, error) {
+	var comment models.Comment
+	err := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&comment).Error
+	return comment, translate(err)
+}
+
+func (r *commentRepository) Create(ctx context.Context, comment *models.Comment) error {
+	return r.db.WithContext(ctx).Create(comment).Error
+}
+
+func (r *commentRepository) Update(ctx context.Context, comment *models.Comment) error {
+	return r.db.WithContext(ctx).Save(comment).Error
+}
+
+func (r *commentRepository) Delete(ctx context.Context, comment *models.Comment) error {
+	return r.db.WithContext(ctx).Delete(comment).Error
+}
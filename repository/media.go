@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"TechBlog/models"
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MediaRepository stores the records of uploaded files
+type MediaRepository interface {
+	// ListByUser returns a page of the files uploaded by a user, newest
+	// first, and how many there are in total
+	ListByUser(ctx context.Context, userID uint, offset, limit int) ([]models.Media, int64, error)
+	Get(ctx context.Context, id uint) (models.Media, error)
+	// GetMany returns the files with the given IDs that exist, in any order
+	GetMany(ctx context.Context, ids []uint) ([]models.Media, error)
+	// GetOwned returns a file only if it was uploaded by the given user
+	GetOwned(ctx context.Context, id, userID uint) (models.Media, error)
+	// GetByKey returns the file stored under key
+	GetByKey(ctx context.Context, key string) (models.Media, error)
+	Create(ctx context.Context, media *models.Media) error
+	// Delete removes the record and those of its variants for good and
+	// takes it off the posts that use it as their cover; the caller removes
+	// the files
+	Delete(ctx context.Context, media *models.Media) error
+
+	// GetVariant returns the variant stored under key
+	GetVariant(ctx context.Context, key string) (models.MediaVariant, error)
+	// CreateVariant records a variant. Recording one that already exists,
+	// because it was generated by two requests at once, is not an error.
+	CreateVariant(ctx context.Context, variant *models.MediaVariant) error
+	// VariantKeys returns the keys of the variants of a file
+	VariantKeys(ctx context.Context, mediaID uint) ([]string, error)
+}
+
+type mediaRepository struct {
+	db *gorm.DB
+}
+
+// NewMediaRepository returns a MediaRepository backed by db
+func NewMediaRepository(db *gorm.DB) MediaRepository {
+	return &mediaRepository{db: db}
+}
+
+func (r *mediaRepository) ListByUser(ctx context.Context, userID uint, offset, limit int) ([]models.Media, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.Media{}).Where("user_id = ?", userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var media []models.Media
+	err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&media).Error
+	return media, total, err
+}
+
+func (r *mediaRepository) Get(ctx context.Context, id uint) (models.Media, error) {
+	var media models.Media
+	err := r.db.WithContext(ctx).First(&media, id).Error
+	return media, translate(err)
+}
+
+func (r *mediaRepository) GetMany(ctx context.Context, ids []uint) ([]models.Media, error) {
+	var media []models.Media
+	if len(ids) == 0 {
+		return media, nil
+	}
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&media).Error
+	return media, err
+}
+
+func (r *mediaRepository) GetOwned(ctx context.Context, id, userID uint) (models.Media, error) {
+	var media models.Media
+	err := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&media).Error
+	return media, translate(err)
+}
+
+func (r *mediaRepository) GetByKey(ctx context.Context, key string) (models.Media, error) {
+	var media models.Media
+	err := r.db.WithContext(ctx).Where("key = ?", key).First(&media).Error
+	return media, translate(err)
+}
+
+func (r *mediaRepository) Create(ctx context.Context, media *models.Media) error {
+	return r.db.WithContext(ctx).Create(media).Error
+}
+
+func (r *mediaRepository) Delete(ctx context.Context, media *models.Media) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("media_id = ?", media.ID).Delete(&models.MediaVariant{}).Error; err != nil {
+			return err
+		}
+		// Posts that used the file as their cover are left without one
+		if err := tx.Unscoped().Model(&models.Post{}).Where("cover_media_id = ?", media.ID).UpdateColumn("cover_media_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(media).Error
+	})
+}
+
+func (r *mediaRepository) GetVariant(ctx context.Context, key string) (models.MediaVariant, error) {
+	var variant models.MediaVariant
+	err := r.db.WithContext(ctx).Where("key = ?", key).First(&variant).Error
+	return variant, translate(err)
+}
+
+func (r *mediaRepository) CreateVariant(ctx context.Context, variant *models.MediaVariant) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(variant).Error
+}
+
+func (r *mediaRepository) VariantKeys(ctx context.Context, mediaID uint) ([]string, error) {
+	var keys []string
+	err := r.db.WithContext(ctx).Model(&models.MediaVariant{}).Where("media_id = ?", mediaID).Order("id").Pluck("key", &keys).Error
+	return keys, err
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"TechBlog/models"
+	"TechBlog/trash"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// PostRepository stores posts
+type PostRepository interface {
+	// List returns every post with its author, newest first
+	List(ctx context.Context) ([]models.Post, error)
+	// Get returns a post with its author and comments
+	Get(ctx context.Context, id uint) (models.Post, error)
+	// ListByUser returns the posts written by a user with their author, newest first
+	ListByUser(ctx context.Context, userID uint) ([]models.Post, error)
+	// Exists reports whether a post that is not in the trash has the given ID
+	Exists(ctx context.Context, id uint) (bool, error)
+	// GetOwned returns a post only if it was written by the given user
+	GetOwned(ctx context.Context, id, userID uint) (models.Post, error)
+	Create(ctx context.Context, post *models.Post) error
+	Update(ctx context.Context, post *models.Post) error
+	// Delete moves a post and its comments to the trash
+	Delete(ctx context.Context, post *models.Post) error
+}
+
+type postRepository struct {
+	db *gorm.DB
+}
+
+// NewPostRepository returns a PostRepository backed by db
+func NewPostRepository(db *gorm.DB) PostRepository {
+	return &postRepository{db: db}
+}
+
+// withRelations loads the author and comments shown alongside a post
+func withRelations(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("User").
+		Preload("User.Posts").
+		Preload("User.Comments").
+		Preload("Comments.User")
+}
+
+func (r *postRepository) List(ctx context.Context) ([]models.Post, error) {
+	var posts []models.Post
+	err := r.db.WithContext(ctx).Preload("User").Order("created_at DESC").Find(&posts).Error
+	return posts, err
+}
+
+func (r *postRepository) Get(ctx context.Context, id uint) (models.Post, error) {
+	var post models.Post
+	err := r.db.WithContext(ctx).Scopes(withRelations).First(&post, id).Error
+	return post, translate(err)
+}
+
+func (r *postRepository) ListByUser(ctx context.Context, userID uint) ([]models.Post, error) {
+	var posts []models.Post
+	err := r.db.WithContext(ctx).Preload("User").Where("user_id = ?", userID).Order("created_at DESC").Find(&posts).Error
+	return posts, err
+}
+
+func (r *postRepository) Exists(ctx context.Context, id uint) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Post{}).Where("id = ?", id).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *postRepository) GetOwned(ctx context.Context, id, userID uint) (models.Post, error) {
+	var post models.Post
+	err := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&post).Error
+	return post, translate(err)
+}
+
+func (r *postRepository) Create(ctx context.Context, post *models.Post) error {
+	return r.db.WithContext(ctx).Create(post).Error
+}
+
+func (r *postRepository) Update(ctx context.Context, post *models.Post) error {
+	return r.db.WithContext(ctx).Save(post).Error
+}
+
+func (r *postRepository) Delete(ctx context.Context, post *models.Post) error {
+	return trash.DeletePost(r.db.WithContext(ctx), post)
+}
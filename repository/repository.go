@@ -0,0 +1,22 @@
+// Package repository holds the data access for the service layer. Each
+// repository is an interface so services can be exercised with fakes; the
+// GORM implementations are returned by the New* constructors.
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned when a lookup matches no record
+var ErrNotFound = errors.New("record not found")
+
+// translate replaces GORM's not-found error with ErrNotFound. Other errors
+// are returned unchanged so callers can still inspect database errors.
+func translate(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
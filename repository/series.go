@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"TechBlog/models"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SeriesRepository stores series and the order of their posts
+type SeriesRepository interface {
+	// Get returns a series with its posts that are not in the trash, in order
+	Get(ctx context.Context, id uint) (models.Series, error)
+	// GetOwned returns a series, without its posts, only if it belongs to the given user
+	GetOwned(ctx context.Context, id, userID uint) (models.Series, error)
+	// GetByPost returns the series that contains a post, like Get
+	GetByPost(ctx context.Context, postID uint) (models.Series, error)
+	// SeriesOf returns the IDs of the series that the given posts belong to,
+	// keyed by post ID. Posts in no series are left out.
+	SeriesOf(ctx context.Context, postIDs []uint) (map[uint]uint, error)
+	// Create stores a series together with its posts
+	Create(ctx context.Context, series *models.Series) error
+	// Update saves the title and description of a series
+	Update(ctx context.Context, series *models.Series) error
+	// SetPosts replaces the posts of a series with postIDs, in that order
+	SetPosts(ctx context.Context, seriesID uint, postIDs []uint) error
+	// Delete removes a series for good; its posts are kept
+	Delete(ctx context.Context, series *models.Series) error
+}
+
+type seriesRepository struct {
+	db *gorm.DB
+}
+
+// NewSeriesRepository returns a SeriesRepository backed by db
+func NewSeriesRepository(db *gorm.DB) SeriesRepository {
+	return &seriesRepository{db: db}
+}
+
+// withSeriesPosts loads the posts of a series in order, leaving out those in
+// the trash, which come back in their place when restored
+func withSeriesPosts(db *gorm.DB) *gorm.DB {
+	return db.Preload("Posts", func(db *gorm.DB) *gorm.DB {
+		return db.InnerJoins("Post").Order("series_posts.position")
+	})
+}
+
+func (r *seriesRepository) Get(ctx context.Context, id uint) (models.Series, error) {
+	var series models.Series
+	err := r.db.WithContext(ctx).Scopes(withSeriesPosts).First(&series, id).Error
+	return series, translate(err)
+}
+
+func (r *seriesRepository) GetOwned(ctx context.Context, id, userID uint) (models.Series, error) {
+	var series models.Series
+	err := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&series).Error
+	return series, translate(err)
+}
+
+func (r *seriesRepository) GetByPost(ctx context.Context, postID uint) (models.Series, error) {
+	var series models.Series
+	err := r.db.WithContext(ctx).Scopes(withSeriesPosts).
+		Where("id = (?)", r.db.Model(&models.SeriesPost{}).Select("series_id").Where("post_id = ?", postID)).
+		First(&series).Error
+	return series, translate(err)
+}
+
+func (r *seriesRepository) SeriesOf(ctx context.Context, postIDs []uint) (map[uint]uint, error) {
+	var members []models.SeriesPost
+	if len(postIDs) > 0 {
+		if err := r.db.WithContext(ctx).Where("post_id IN ?", postIDs).Find(&members).Error; err != nil {
+			return nil, err
+		}
+	}
+	seriesOf := make(map[uint]uint, len(members))
+	for _, member := range members {
+		seriesOf[member.PostID] = member.SeriesID
+	}
+	return seriesOf, nil
+}
+
+func (r *seriesRepository) Create(ctx context.Context, series *models.Series) error {
+	return r.db.WithContext(ctx).Omit("Posts.Post").Create(series).Error
+}
+
+func (r *seriesRepository) Update(ctx context.Context, series *models.Series) error {
+	return r.db.WithContext(ctx).Select("title", "description", "updated_at").Updates(series).Error
+}
+
+func (r *seriesRepository) SetPosts(ctx context.Context, seriesID uint, postIDs []uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Series{ID: seriesID}).Update("updated_at", time.Now()).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("series_id = ?", seriesID).Delete(&models.SeriesPost{}).Error; err != nil {
+			return err
+		}
+		if len(postIDs) == 0 {
+			return nil
+		}
+		members := make([]models.SeriesPost, len(postIDs))
+		for i, postID := range postIDs {
+			members[i] = models.SeriesPost{SeriesID: seriesID, Position: i + 1, PostID: postID}
+		}
+		return tx.Omit("Post").Create(&members).Error
+	})
+}
+
+func (r *seriesRepository) Delete(ctx context.Context, series *models.Series) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("series_id = ?", series.ID).Delete(&models.SeriesPost{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(series).Error
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"TechBlog/config"
+	"TechBlog/connect"
+	"TechBlog/controllers/routes"
+	"TechBlog/media"
+	"TechBlog/ratelimit"
+	"TechBlog/utils"
+	"log/slog"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+)
+
+// exposedHeaders are the response headers that scripts on other origins may read
+var exposedHeaders = []string{
+	"Content-Length", "Authorization",
+	"Deprecation", "Sunset", "Link",
+	"RateLimit-Policy", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After",
+}
+
+// newRouter builds the HTTP handler with its middleware, the frontend and the API routes
+func newRouter(cfg *config.Config, dbConfig *connect.DBConfig, logger *slog.Logger) (*gin.Engine, error) {
+	router := gin.New()
+	// Client IPs, which rate limits and logs rely on, are only taken from
+	// X-Forwarded-For when the request comes from a trusted proxy
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		return nil, err
+	}
+	router.Use(utils.WithRequestID(), utils.WithTracing(), utils.WithAccessLog(logger), utils.WithMetrics(), utils.WithRecovery(), utils.WithErrors())
+	router.Use(utils.WithSecurityHeaders(routes.SecurityHeaders(cfg)))
+
+	// CORS also answers preflight OPTIONS requests for every route
+	router.Use(cors.New(cors.Config{
+		AllowOrigins:     cfg.CORS.AllowOrigins,
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}, // Include OPTIONS for preflight requests
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Accept", utils.CSRFHeader},
+		ExposeHeaders:    exposedHeaders,
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	}))
+
+	store := cookie.NewStore([]byte(cfg.Session.Secret))
+	store.Options(sessions.Options{
+		Path:     "/",
+		MaxAge:   cfg.Session.MaxAgeSeconds,
+		Secure:   cfg.Session.Secure,
+		HttpOnly: cfg.Session.HttpOnly,
+		SameSite: cfg.Session.SameSiteMode(),
+	})
+	router.Use(sessions.Sessions(cfg.Session.Name, store))
+
+	router.Static("/static", cfg.Server.FrontendDir)
+	router.Static("/uploads", cfg.Storage.UploadDir)
+
+	router.NoRoute(func(c *gin.Context) {
+		c.File(cfg.Server.FrontendDir + "/index.html")
+	})
+
+	limiter, err := ratelimit.FromConfig(cfg.RateLimit, dbConfig.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	storage, err := media.FromConfig(cfg.Media, cfg.Storage.UploadDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Register routes
+	routes.RegisterRoutes(router, dbConfig, cfg, limiter, storage)
+	return router, nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"TechBlog/accounts"
+	"TechBlog/config"
+	"TechBlog/connect"
+	"TechBlog/exports"
+	"TechBlog/logging"
+	"TechBlog/mailer"
+	"TechBlog/metrics"
+	"TechBlog/tracing"
+	"TechBlog/trash"
+	"context"
+	"flag"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// worker is a background job that runs until its context is cancelled
+type worker struct {
+	name   string
+	cancel context.CancelFunc
+	done   <-chan struct{}
+}
+
+// startWorker runs a background job with its own context so that it can be stopped on its own
+func startWorker(name string, start func(ctx context.Context) <-chan struct{}) worker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return worker{name: name, cancel: cancel, done: start(ctx)}
+}
+
+// runServe implements the serve command, which starts the HTTP server and
+// shuts it down gracefully on SIGINT or SIGTERM
+func runServe(args []string) {
+	cfg, err := config.Load(flag.NewFlagSet("serve", flag.ExitOnError), args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Log as structured records from here on, including the standard log package
+	logger, err := logging.New(os.Stdout, cfg.Log)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Connect to the database
+	dbConfig := mustConnect(cfg)
+
+	// Make sure the schema is current before serving requests
+	migrator, err := newMigrator(dbConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Database.MigrateOnStart {
+		if _, err := migrator.Up(context.Background()); err != nil {
+			log.Fatalf("Database migration failed: %v", err)
+		}
+	} else {
+		requireMigrated(dbConfig)
+	}
+
+	// Posts from before excerpts and reading times were stored get theirs now
+	if summarized, err := summarizeStoredPosts(dbConfig.DB); err != nil {
+		slog.Warn("failed to summarize stored posts", "error", err)
+	} else if summarized > 0 {
+		slog.Info("summarized stored posts", "posts", summarized)
+	}
+
+	// Send mail over SMTP when a server is configured, otherwise log it
+	if cfg.Mail.SMTPHost != "" {
+		mailer.Default = mailer.SMTPMailer{
+			Host:     cfg.Mail.SMTPHost,
+			Port:     cfg.Mail.SMTPPort,
+			Username: cfg.Mail.SMTPUser,
+			Password: cfg.Mail.SMTPPassword,
+			From:     cfg.Mail.From,
+		}
+	}
+
+	if cfg.Metrics.Enabled {
+		if err := metrics.RegisterDB(dbConfig.DB); err != nil {
+			log.Fatalf("Failed to register database metrics: %v", err)
+		}
+	}
+
+	workers := []worker{
+		// Permanently delete trashed items once their retention period is over
+		startWorker("trash purger", func(ctx context.Context) <-chan struct{} {
+			return trash.StartPurger(ctx, dbConfig.DB, cfg.Accounts.TrashRetention())
+		}),
+		// Carry out confirmed account deletions once their cooling-off period is over
+		startWorker("account deletion worker", func(ctx context.Context) <-chan struct{} {
+			return accounts.StartDeletionWorker(ctx, dbConfig.DB, cfg)
+		}),
+		// Build requested personal data exports and remove expired ones
+		startWorker("data export worker", func(ctx context.Context) <-chan struct{} {
+			return exports.StartWorker(ctx, dbConfig.DB, cfg)
+		}),
+	}
+
+	if cfg.IsProduction() {
+		gin.SetMode(gin.ReleaseMode)
+	} else {
+		gin.SetMode(gin.DebugMode)
+	}
+
+	gin.DebugPrintRouteFunc = func(method, path, handler string, handlers int) {
+		logger.Debug("route registered", "method", method, "path", path, "handler", handler)
+	}
+
+	router, err := newRouter(cfg, dbConfig, logger)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	server := &http.Server{
+		Addr:              ":" + cfg.Server.Port,
+		Handler:           router,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info("listening", "addr", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	failed := false
+	select {
+	case err := <-serverErr:
+		logger.Error("server failed", "error", err)
+		failed = true
+	case <-ctx.Done():
+		logger.Info("shutting down", "timeout", cfg.Server.ShutdownTimeout().String())
+	}
+	// A second signal stops the process immediately
+	stop()
+
+	shutdown(server, workers, dbConfig, shutdownTracing, cfg.Server.ShutdownTimeout())
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// shutdown drains in-flight requests, then stops the background workers in
+// the reverse order they were started, closes the database pool and flushes
+// the remaining spans. Each phase gets up to timeout.
+func shutdown(server *http.Server, workers []worker, dbConfig *connect.DBConfig, shutdownTracing func(context.Context) error, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Warn("requests did not finish in time, closing connections", "error", err)
+		server.Close()
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for i := len(workers) - 1; i >= 0; i-- {
+		workers[i].cancel()
+		select {
+		case <-workers[i].done:
+		case <-ctx.Done():
+			slog.Warn("background worker did not stop in time", "worker", workers[i].name)
+		}
+	}
+
+	if err := dbConfig.Close(); err != nil {
+		slog.Error("failed to close the database connection", "error", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Warn("failed to flush traces", "error", err)
+	}
+	slog.Info("server stopped")
+}
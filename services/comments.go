@@ -0,0 +1,73 @@
+package services
+
+import (
+	"TechBlog/metrics"
+	"TechBlog/models"
+	"TechBlog/repository"
+	"context"
+)
+
+// CommentService manages comments. Only the author of a comment may change it.
+type CommentService struct {
+	comments repository.CommentRepository
+	posts    repository.PostRepository
+}
+
+// NewCommentService returns a CommentService storing comments in comments
+// and checking the posts they belong to in posts
+func NewCommentService(comments repository.CommentRepository, posts repository.PostRepository) *CommentService {
+	return &CommentService{comments: comments, posts: posts}
+}
+
+// Create adds a comment by userID to a post
+func (s *CommentService) Create(ctx context.Context, userID, postID uint, body string) (models.Comment, error) {
+	if _, err := required("body", body); err != nil {
+		return models.Comment{}, err
+	}
+
+	exists, err := s.posts.Exists(ctx, postID)
+	if err != nil {
+		return models.Comment{}, err
+	}
+	if !exists {
+		return models.Comment{}, ErrPostNotFound
+	}
+
+	comment := models.Comment{
+		Body:   body,
+		PostID: postID,
+		UserID: userID,
+	}
+	if err := s.comments.Create(ctx, &comment); err != nil {
+		return models.Comment{}, err
+	}
+	metrics.CommentsCreated.Inc()
+	return comment, nil
+}
+
+// Update replaces the body of a comment written by userID
+func (s *CommentService) Update(ctx context.Context, id, userID uint, body string) (models.Comment, error) {
+	if _, err := required("body", body); err != nil {
+		return models.Comment{}, err
+	}
+
+	comment, err := s.comments.GetOwned(ctx, id, userID)
+	if err != nil {
+		return models.Comment{}, notFound(err, ErrCommentNotFound)
+	}
+
+	comment.Body = body
+	if err := s.comments.Update(ctx, &comment); err != nil {
+		return models.Comment{}, err
+	}
+	return comment, nil
+}
+
+// Delete moves a comment written by userID to the trash
+func (s *CommentService) Delete(ctx context.Context, id, userID uint) error {
+	comment, err := s.comments.GetOwned(ctx, id, userID)
+	if err != nil {
+		return notFound(err, ErrCommentNotFound)
+	}
+	return s.comments.Delete(ctx, &comment)
+}
@@ -0,0 +1,267 @@
+package services
+
+import (
+	"TechBlog/models"
+	"TechBlog/repository"
+	"context"
+	"sort"
+)
+
+// The fakes in this file keep records in memory so that the services can be
+// tested without a database. They implement only what the services rely on:
+// lookups, ownership and not-found errors.
+
+// fakePosts is an in-memory PostRepository
+type fakePosts struct {
+	posts  map[uint]models.Post
+	nextID uint
+}
+
+func newFakePosts(posts ...models.Post) *fakePosts {
+	f := &fakePosts{posts: map[uint]models.Post{}}
+	for _, post := range posts {
+		f.posts[post.ID] = post
+		if post.ID > f.nextID {
+			f.nextID = post.ID
+		}
+	}
+	return f
+}
+
+func (f *fakePosts) List(_ context.Context) ([]models.Post, error) {
+	var posts []models.Post
+	for _, post := range f.posts {
+		posts = append(posts, post)
+	}
+	sort.Slice(posts, func(i, j int) bool { return posts[i].ID > posts[j].ID })
+	return posts, nil
+}
+
+func (f *fakePosts) Get(_ context.Context, id uint) (models.Post, error) {
+	post, ok := f.posts[id]
+	if !ok {
+		return models.Post{}, repository.ErrNotFound
+	}
+	return post, nil
+}
+
+func (f *fakePosts) ListByUser(ctx context.Context, userID uint) ([]models.Post, error) {
+	all, _ := f.List(ctx)
+	var posts []models.Post
+	for _, post := range all {
+		if post.UserID == userID {
+			posts = append(posts, post)
+		}
+	}
+	return posts, nil
+}
+
+func (f *fakePosts) Exists(_ context.Context, id uint) (bool, error) {
+	_, ok := f.posts[id]
+	return ok, nil
+}
+
+func (f *fakePosts) GetOwned(_ context.Context, id, userID uint) (models.Post, error) {
+	post, ok := f.posts[id]
+	if !ok || post.UserID != userID {
+		return models.Post{}, repository.ErrNotFound
+	}
+	return post, nil
+}
+
+func (f *fakePosts) Create(_ context.Context, post *models.Post) error {
+	f.nextID++
+	post.ID = f.nextID
+	f.posts[post.ID] = *post
+	return nil
+}
+
+func (f *fakePosts) Update(_ context.Context, post *models.Post) error {
+	f.posts[post.ID] = *post
+	return nil
+}
+
+func (f *fakePosts) Delete(_ context.Context, post *models.Post) error {
+	delete(f.posts, post.ID)
+	return nil
+}
+
+// fakeMedia is an in-memory MediaRepository without variants
+type fakeMedia struct {
+	files map[uint]models.Media
+}
+
+func newFakeMedia(files ...models.Media) *fakeMedia {
+	f := &fakeMedia{files: map[uint]models.Media{}}
+	for _, m := range files {
+		f.files[m.ID] = m
+	}
+	return f
+}
+
+func (f *fakeMedia) ListByUser(_ context.Context, userID uint, offset, limit int) ([]models.Media, int64, error) {
+	var files []models.Media
+	for _, m := range f.files {
+		if m.UserID == userID {
+			files = append(files, m)
+		}
+	}
+	total := int64(len(files))
+	if offset > len(files) {
+		offset = len(files)
+	}
+	files = files[offset:]
+	if limit < len(files) {
+		files = files[:limit]
+	}
+	return files, total, nil
+}
+
+func (f *fakeMedia) Get(_ context.Context, id uint) (models.Media, error) {
+	m, ok := f.files[id]
+	if !ok {
+		return models.Media{}, repository.ErrNotFound
+	}
+	return m, nil
+}
+
+func (f *fakeMedia) GetMany(_ context.Context, ids []uint) ([]models.Media, error) {
+	var files []models.Media
+	for _, id := range ids {
+		if m, ok := f.files[id]; ok {
+			files = append(files, m)
+		}
+	}
+	return files, nil
+}
+
+func (f *fakeMedia) GetOwned(_ context.Context, id, userID uint) (models.Media, error) {
+	m, ok := f.files[id]
+	if !ok || m.UserID != userID {
+		return models.Media{}, repository.ErrNotFound
+	}
+	return m, nil
+}
+
+func (f *fakeMedia) GetByKey(_ context.Context, key string) (models.Media, error) {
+	for _, m := range f.files {
+		if m.Key == key {
+			return m, nil
+		}
+	}
+	return models.Media{}, repository.ErrNotFound
+}
+
+func (f *fakeMedia) Create(_ context.Context, m *models.Media) error {
+	m.ID = uint(len(f.files) + 1)
+	f.files[m.ID] = *m
+	return nil
+}
+
+func (f *fakeMedia) Delete(_ context.Context, m *models.Media) error {
+	delete(f.files, m.ID)
+	return nil
+}
+
+func (f *fakeMedia) GetVariant(_ context.Context, _ string) (models.MediaVariant, error) {
+	return models.MediaVariant{}, repository.ErrNotFound
+}
+
+func (f *fakeMedia) CreateVariant(_ context.Context, _ *models.MediaVariant) error {
+	return nil
+}
+
+func (f *fakeMedia) VariantKeys(_ context.Context, _ uint) ([]string, error) {
+	return nil, nil
+}
+
+// fakeSeries is an in-memory SeriesRepository. Unlike the database, it
+// returns the posts of a series without loading them.
+type fakeSeries struct {
+	series map[uint]models.Series
+	nextID uint
+}
+
+func newFakeSeries(series ...models.Series) *fakeSeries {
+	f := &fakeSeries{series: map[uint]models.Series{}}
+	for _, s := range series {
+		f.series[s.ID] = s
+		if s.ID > f.nextID {
+			f.nextID = s.ID
+		}
+	}
+	return f
+}
+
+func (f *fakeSeries) Get(_ context.Context, id uint) (models.Series, error) {
+	series, ok := f.series[id]
+	if !ok {
+		return models.Series{}, repository.ErrNotFound
+	}
+	return series, nil
+}
+
+func (f *fakeSeries) GetOwned(_ context.Context, id, userID uint) (models.Series, error) {
+	series, ok := f.series[id]
+	if !ok || series.UserID != userID {
+		return models.Series{}, repository.ErrNotFound
+	}
+	series.Posts = nil
+	return series, nil
+}
+
+func (f *fakeSeries) GetByPost(ctx context.Context, postID uint) (models.Series, error) {
+	seriesOf, _ := f.SeriesOf(ctx, []uint{postID})
+	id, ok := seriesOf[postID]
+	if !ok {
+		return models.Series{}, repository.ErrNotFound
+	}
+	return f.Get(ctx, id)
+}
+
+func (f *fakeSeries) SeriesOf(_ context.Context, postIDs []uint) (map[uint]uint, error) {
+	seriesOf := map[uint]uint{}
+	for _, series := range f.series {
+		for _, member := range series.Posts {
+			for _, postID := range postIDs {
+				if member.PostID == postID {
+					seriesOf[postID] = series.ID
+				}
+			}
+		}
+	}
+	return seriesOf, nil
+}
+
+func (f *fakeSeries) Create(_ context.Context, series *models.Series) error {
+	f.nextID++
+	series.ID = f.nextID
+	for i := range series.Posts {
+		series.Posts[i].SeriesID = series.ID
+	}
+	f.series[series.ID] = *series
+	return nil
+}
+
+func (f *fakeSeries) Update(_ context.Context, series *models.Series) error {
+	stored := f.series[series.ID]
+	stored.Title = series.Title
+	stored.Description = series.Description
+	f.series[series.ID] = stored
+	return nil
+}
+
+func (f *fakeSeries) SetPosts(_ context.Context, seriesID uint, postIDs []uint) error {
+	series := f.series[seriesID]
+	series.Posts = nil
+	for i, postID := range postIDs {
+		series.Posts = append(series.Posts, models.SeriesPost{SeriesID: seriesID, Position: i + 1, PostID: postID})
+	}
+	f.series[seriesID] = series
+	return nil
+}
+
+func (f *fakeSeries) Delete(_ context.Context, series *models.Series) error {
+	delete(f.series, series.ID)
+	return nil
+}
@@ -0,0 +1,383 @@
+package services
+
+import (
+	"TechBlog/config"
+	"TechBlog/markdown"
+	"TechBlog/media"
+	"TechBlog/metrics"
+	"TechBlog/models"
+	"TechBlog/repository"
+	"TechBlog/utils"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// maxFilenameLength caps the stored original name of an upload
+const maxFilenameLength = 255
+
+// fileRoute matches the path of the API route that redirects to an upload
+var fileRoute = regexp.MustCompile(`^/api(?:/v[0-9]+)?/media/([0-9]+)/file$`)
+
+// generating lets requests for a variant that is being generated wait for it
+// rather than generate it again
+var generating singleflight.Group
+
+// UnsupportedMediaTypeError reports an upload whose content is not of an allowed type
+type UnsupportedMediaTypeError struct {
+	ContentType string
+	Allowed     []string
+}
+
+func (e *UnsupportedMediaTypeError) Error() string {
+	return "unsupported media type " + e.ContentType
+}
+
+// Upload is a file to store. File is read twice: once to detect its type
+// and once to store it.
+type Upload struct {
+	Filename string
+	File     io.ReadSeeker
+	Size     int64
+}
+
+// MediaService manages uploaded files. Only the user who uploaded a file may
+// delete it, but anyone with its URL may fetch it.
+type MediaService struct {
+	media        repository.MediaRepository
+	storage      media.Storage
+	allowed      []string
+	signedURLTTL time.Duration
+	maxSize      int64
+	// widths are the sizes images may be resized to, in ascending order
+	widths []int
+}
+
+// NewMediaService returns a MediaService keeping records in records and
+// files in storage, accepting the types and image sizes allowed by cfg
+func NewMediaService(records repository.MediaRepository, storage media.Storage, cfg config.MediaConfig) *MediaService {
+	widths := slices.Clone(cfg.ImageWidths)
+	slices.Sort(widths)
+	return &MediaService{
+		media:        records,
+		storage:      storage,
+		allowed:      cfg.AllowedTypes,
+		signedURLTTL: cfg.SignedURLTTL(),
+		maxSize:      cfg.MaxUploadBytes(),
+		widths:       slices.Compact(widths),
+	}
+}
+
+// ListByUser returns a page of the files uploaded by a user and their total
+func (s *MediaService) ListByUser(ctx context.Context, userID uint, offset, limit int) ([]models.Media, int64, error) {
+	return s.media.ListByUser(ctx, userID, offset, limit)
+}
+
+// Get returns a file record
+func (s *MediaService) Get(ctx context.Context, id uint) (models.Media, error) {
+	m, err := s.media.Get(ctx, id)
+	return m, notFound(err, ErrMediaNotFound)
+}
+
+// GetOwned returns a file record uploaded by userID
+func (s *MediaService) GetOwned(ctx context.Context, id, userID uint) (models.Media, error) {
+	m, err := s.media.GetOwned(ctx, id, userID)
+	return m, notFound(err, ErrMediaNotFound)
+}
+
+// Upload checks the type and dimensions of a file by its content and stores
+// it for userID under a new random key. Images are stored without their
+// metadata, such as the location a photo was taken at.
+func (s *MediaService) Upload(ctx context.Context, userID uint, in Upload) (models.Media, error) {
+	info, err := media.Inspect(in.File)
+	if err != nil {
+		return models.Media{}, err
+	}
+	if !slices.Contains(s.allowed, info.ContentType) {
+		return models.Media{}, &UnsupportedMediaTypeError{ContentType: info.ContentType, Allowed: s.allowed}
+	}
+	if info.IsImage() && (info.Width > utils.MaxImageDimension || info.Height > utils.MaxImageDimension) {
+		return models.Media{}, &ValidationError{Field: "file", Code: "image_too_large",
+			Message: fmt.Sprintf("must be at most %d pixels wide and high", utils.MaxImageDimension)}
+	}
+
+	var file io.Reader = in.File
+	size := in.Size
+	if info.IsImage() {
+		data, err := io.ReadAll(in.File)
+		if err != nil {
+			return models.Media{}, fmt.Errorf("read file: %w", err)
+		}
+		stripped, err := media.StripMetadata(data, info.ContentType)
+		if errors.Is(err, media.ErrMalformedImage) {
+			return models.Media{}, &ValidationError{Field: "file", Code: "invalid_image", Message: "must be a well-formed image"}
+		} else if err != nil {
+			return models.Media{}, err
+		}
+		file, size = bytes.NewReader(stripped), int64(len(stripped))
+	}
+
+	token, err := utils.NewToken()
+	if err != nil {
+		return models.Media{}, err
+	}
+	m := models.Media{
+		UserID:      userID,
+		Key:         fmt.Sprintf("media/%d/%s%s", userID, token[:32], media.Extension(info.ContentType)),
+		Filename:    cleanFilename(in.Filename, info.ContentType),
+		ContentType: info.ContentType,
+		Size:        size,
+		Width:       info.Width,
+		Height:      info.Height,
+	}
+	if err := s.storage.Put(ctx, m.Key, file, size, m.ContentType); err != nil {
+		return models.Media{}, fmt.Errorf("store file: %w", err)
+	}
+	if err := s.media.Create(ctx, &m); err != nil {
+		s.removeFile(ctx, m.Key)
+		return models.Media{}, err
+	}
+	metrics.MediaUploaded.Inc()
+	return m, nil
+}
+
+// Delete removes a file uploaded by userID and its variants. The stored
+// files are removed first, so that a failure leaves the record to retry with.
+func (s *MediaService) Delete(ctx context.Context, id, userID uint) error {
+	m, err := s.media.GetOwned(ctx, id, userID)
+	if err != nil {
+		return notFound(err, ErrMediaNotFound)
+	}
+	keys, err := s.media.VariantKeys(ctx, m.ID)
+	if err != nil {
+		return err
+	}
+	for _, key := range append(keys, m.Key) {
+		if err := s.storage.Delete(ctx, key); err != nil {
+			return fmt.Errorf("delete stored file: %w", err)
+		}
+	}
+	return s.media.Delete(ctx, &m)
+}
+
+// URL returns the permanent public URL of a file, or an empty string when
+// the storage is private and the file must be fetched through FileURL
+func (s *MediaService) URL(m models.Media) string {
+	return s.storage.PublicURL(m.Key)
+}
+
+// FileURL returns a URL to fetch a file from: its public URL, or else one
+// signed for the configured time
+func (s *MediaService) FileURL(ctx context.Context, m models.Media) (string, error) {
+	return s.fileURL(ctx, m.Key)
+}
+
+// VariantURL returns a URL to fetch a variant of an image from, like
+// FileURL. The variant is generated and stored on first request, and reused
+// afterwards.
+func (s *MediaService) VariantURL(ctx context.Context, m models.Media, t media.Transform) (string, error) {
+	if err := s.checkTransform(t); err != nil {
+		return "", err
+	}
+	if !media.CanTransform(m.ContentType) || m.Width == 0 || m.Height == 0 {
+		return "", ErrMediaNotTransformable
+	}
+
+	t = t.Resolve(m.Width, m.Height, m.ContentType)
+	key := t.Key(m.Key)
+	_, err := s.media.GetVariant(ctx, key)
+	if errors.Is(err, repository.ErrNotFound) {
+		_, err, _ = generating.Do(key, func() (interface{}, error) {
+			return nil, s.generateVariant(ctx, m, t, key)
+		})
+	}
+	if err != nil {
+		return "", err
+	}
+	return s.fileURL(ctx, key)
+}
+
+// checkTransform only lets through the sizes and formats in the allowlists,
+// which bound the number of variants that can be generated for an image
+func (s *MediaService) checkTransform(t media.Transform) error {
+	allowed := make([]string, len(s.widths))
+	for i, width := range s.widths {
+		allowed[i] = strconv.Itoa(width)
+	}
+	if t.Width != 0 && !slices.Contains(s.widths, t.Width) {
+		return &ValidationError{Field: "w", Code: "size_not_allowed", Message: "must be one of " + strings.Join(allowed, ", ")}
+	}
+	if t.Height != 0 && !slices.Contains(s.widths, t.Height) {
+		return &ValidationError{Field: "h", Code: "size_not_allowed", Message: "must be one of " + strings.Join(allowed, ", ")}
+	}
+	if t.Format != "" && !slices.Contains(media.Formats, t.Format) {
+		return &ValidationError{Field: "fmt", Code: "format_not_allowed", Message: "must be one of " + strings.Join(media.Formats, ", ")}
+	}
+	return nil
+}
+
+// generateVariant renders the variant t of m and stores it under key
+func (s *MediaService) generateVariant(ctx context.Context, m models.Media, t media.Transform, key string) error {
+	original, err := s.storage.Open(ctx, m.Key)
+	if err != nil {
+		return fmt.Errorf("open stored file: %w", err)
+	}
+	defer original.Close()
+
+	start := time.Now()
+	data, err := media.Apply(io.LimitReader(original, s.maxSize), t)
+	if err != nil {
+		return fmt.Errorf("transform image: %w", err)
+	}
+	if err := s.storage.Put(ctx, key, bytes.NewReader(data), int64(len(data)), t.ContentType()); err != nil {
+		return fmt.Errorf("store variant: %w", err)
+	}
+
+	variant := models.MediaVariant{
+		MediaID:     m.ID,
+		Key:         key,
+		ContentType: t.ContentType(),
+		Size:        int64(len(data)),
+		Width:       t.Width,
+		Height:      t.Height,
+	}
+	if err := s.media.CreateVariant(ctx, &variant); err != nil {
+		s.removeFile(ctx, key)
+		return err
+	}
+	metrics.MediaVariantDuration.WithLabelValues(t.Format).Observe(time.Since(start).Seconds())
+	return nil
+}
+
+// Images returns a resolver for the uploads that post bodies embed. Images
+// are linked at the allowed widths through the file route under prefix, such
+// as /api/v1, and PNG images are converted to WebP, which is smaller.
+func (s *MediaService) Images(ctx context.Context, prefix string) markdown.ImageResolver {
+	return func(src string) (markdown.Image, bool) {
+		m, ok := s.embedded(ctx, src)
+		if !ok {
+			return markdown.Image{}, false
+		}
+		return s.image(m, src, prefix)
+	}
+}
+
+// Covers returns the images with the given IDs, linked like those of
+// Images, keyed by ID. IDs of files that are gone or not images are left out.
+func (s *MediaService) Covers(ctx context.Context, ids []uint, prefix string) (map[uint]markdown.Image, error) {
+	files, err := s.media.GetMany(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	covers := make(map[uint]markdown.Image, len(files))
+	for _, m := range files {
+		if img, ok := s.image(m, fmt.Sprintf("%s/media/%d/file", prefix, m.ID), prefix); ok {
+			covers[m.ID] = img
+		}
+	}
+	return covers, nil
+}
+
+// image links the image m, embedded as src, at the allowed widths
+func (s *MediaService) image(m models.Media, src, prefix string) (markdown.Image, bool) {
+	if m.Width == 0 || m.Height == 0 {
+		return markdown.Image{}, false
+	}
+	if !media.CanTransform(m.ContentType) || m.ContentType == "image/gif" {
+		// Variants of GIFs would lose their animation
+		return markdown.Image{Src: src, Width: m.Width, Height: m.Height}, true
+	}
+
+	format, query := "", ""
+	if m.ContentType == "image/png" {
+		format, query = media.FormatWebP, "&fmt="+media.FormatWebP
+	}
+	var img markdown.Image
+	for _, width := range s.widths {
+		t := media.Transform{Width: width, Format: format}.Resolve(m.Width, m.Height, m.ContentType)
+		source := markdown.Source{URL: fmt.Sprintf("%s/media/%d/file?w=%d%s", prefix, m.ID, width, query), Width: t.Width}
+		img.Srcset = append(img.Srcset, source)
+		img.Src, img.Width, img.Height = source.URL, t.Width, t.Height
+		// Larger widths would only repeat the original size
+		if t.Width == m.Width {
+			break
+		}
+	}
+	return img, true
+}
+
+// embedded returns the upload that src refers to, either through the file
+// route or through its public URL
+func (s *MediaService) embedded(ctx context.Context, src string) (models.Media, bool) {
+	u, err := url.Parse(src)
+	if err != nil {
+		return models.Media{}, false
+	}
+
+	var m models.Media
+	if match := fileRoute.FindStringSubmatch(u.Path); match != nil && u.Host == "" {
+		id, parseErr := strconv.ParseUint(match[1], 10, 0)
+		if parseErr != nil {
+			return models.Media{}, false
+		}
+		m, err = s.media.Get(ctx, uint(id))
+	} else if base := s.storage.PublicURL(""); base != "" && strings.HasPrefix(src, base) {
+		m, err = s.media.GetByKey(ctx, strings.TrimPrefix(src, base))
+	} else {
+		return models.Media{}, false
+	}
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			slog.WarnContext(ctx, "failed to look up an embedded image", "url", src, "error", err)
+		}
+		return models.Media{}, false
+	}
+	return m, true
+}
+
+// fileURL returns the public URL of the file stored under key, or else one
+// signed for the configured time
+func (s *MediaService) fileURL(ctx context.Context, key string) (string, error) {
+	if public := s.storage.PublicURL(key); public != "" {
+		return public, nil
+	}
+	return s.storage.SignedURL(ctx, key, s.signedURLTTL)
+}
+
+// SignedURLTTL is how long the URLs returned by FileURL for a private storage stay valid
+func (s *MediaService) SignedURLTTL() time.Duration {
+	return s.signedURLTTL
+}
+
+// removeFile deletes a stored file whose record could not be saved
+func (s *MediaService) removeFile(ctx context.Context, key string) {
+	if err := s.storage.Delete(ctx, key); err != nil {
+		slog.WarnContext(ctx, "failed to remove an orphaned media file", "key", key, "error", err)
+	}
+}
+
+// cleanFilename keeps the base name of an uploaded file for display,
+// falling back to a name derived from its type
+func cleanFilename(name, contentType string) string {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "." || name == "/" || name == "" || !utf8.ValidString(name) {
+		return "upload" + media.Extension(contentType)
+	}
+	if runes := []rune(name); len(runes) > maxFilenameLength {
+		name = string(runes[:maxFilenameLength])
+	}
+	return name
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"TechBlog/metrics"
+	"TechBlog/models"
+	"TechBlog/repository"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// PostInput holds the editable fields of a post
+type PostInput struct {
+	Title string
+	Body  string
+	// Excerpt replaces the excerpt generated from the body, or restores it
+	// when blank. Nil keeps the current excerpt.
+	Excerpt *string
+	// CoverMediaID sets the cover image to an image uploaded by the author,
+	// or removes it when 0. Nil keeps the current cover.
+	CoverMediaID *uint
+}
+
+// validate trims the title and excerpt and checks that neither the title
+// nor the body is blank
+func (in PostInput) validate() (PostInput, error) {
+	title, err := required("title", in.Title)
+	if err != nil {
+		return in, err
+	}
+	if _, err := required("body", in.Body); err != nil {
+		return in, err
+	}
+	in.Title = title
+	if in.Excerpt != nil {
+		excerpt := strings.TrimSpace(*in.Excerpt)
+		if utf8.RuneCountInString(excerpt) > models.ExcerptLength {
+			return in, &ValidationError{Field: "excerpt", Code: "too_long",
+				Message: fmt.Sprintf("must be at most %d characters", models.ExcerptLength)}
+		}
+		in.Excerpt = &excerpt
+	}
+	return in, nil
+}
+
+// apply copies the input to post. The fields derived from the body are
+// computed when the post is saved.
+func (in PostInput) apply(post *models.Post) {
+	post.Title = in.Title
+	post.Body = in.Body
+	if in.Excerpt != nil {
+		post.Excerpt = *in.Excerpt
+		post.CustomExcerpt = *in.Excerpt != ""
+	}
+	if in.CoverMediaID != nil {
+		post.CoverMediaID = nil
+		if *in.CoverMediaID != 0 {
+			post.CoverMediaID = in.CoverMediaID
+		}
+	}
+}
+
+// PostService manages posts. Only the author of a post may change it.
+type PostService struct {
+	posts repository.PostRepository
+	media repository.MediaRepository
+}
+
+// NewPostService returns a PostService storing posts in posts, whose cover
+// images are looked up in media
+func NewPostService(posts repository.PostRepository, media repository.MediaRepository) *PostService {
+	return &PostService{posts: posts, media: media}
+}
+
+// List returns every post
+func (s *PostService) List(ctx context.Context) ([]models.Post, error) {
+	return s.posts.List(ctx)
+}
+
+// Get returns a single post
+func (s *PostService) Get(ctx context.Context, id uint) (models.Post, error) {
+	post, err := s.posts.Get(ctx, id)
+	return post, notFound(err, ErrPostNotFound)
+}
+
+// ListByUser returns the posts written by a user
+func (s *PostService) ListByUser(ctx context.Context, userID uint) ([]models.Post, error) {
+	return s.posts.ListByUser(ctx, userID)
+}
+
+// Create publishes a new post written by userID
+func (s *PostService) Create(ctx context.Context, userID uint, in PostInput) (models.Post, error) {
+	in, err := in.validate()
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	if err := s.checkCover(ctx, userID, in.CoverMediaID); err != nil {
+		return models.Post{}, err
+	}
+
+	post := models.Post{UserID: userID}
+	in.apply(&post)
+	if err := s.posts.Create(ctx, &post); err != nil {
+		return models.Post{}, err
+	}
+	metrics.PostsPublished.Inc()
+	return post, nil
+}
+
+// Update replaces the title and body of a post written by userID, and its
+// excerpt and cover when they are given
+func (s *PostService) Update(ctx context.Context, id, userID uint, in PostInput) (models.Post, error) {
+	in, err := in.validate()
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	post, err := s.posts.GetOwned(ctx, id, userID)
+	if err != nil {
+		return models.Post{}, notFound(err, ErrPostNotFound)
+	}
+
+	if err := s.checkCover(ctx, userID, in.CoverMediaID); err != nil {
+		return models.Post{}, err
+	}
+
+	in.apply(&post)
+	if err := s.posts.Update(ctx, &post); err != nil {
+		return models.Post{}, err
+	}
+	return post, nil
+}
+
+// Delete moves a post written by userID to the trash along with its comments
+func (s *PostService) Delete(ctx context.Context, id, userID uint) error {
+	post, err := s.posts.GetOwned(ctx, id, userID)
+	if err != nil {
+		return notFound(err, ErrPostNotFound)
+	}
+	return s.posts.Delete(ctx, &post)
+}
+
+// checkCover fails unless the cover to set is an image uploaded by userID
+func (s *PostService) checkCover(ctx context.Context, userID uint, id *uint) error {
+	if id == nil || *id == 0 {
+		return nil
+	}
+	m, err := s.media.GetOwned(ctx, *id, userID)
+	if errors.Is(err, repository.ErrNotFound) || err == nil && (m.Width == 0 || m.Height == 0) {
+		return &ValidationError{Field: "cover_media_id", Code: "invalid_cover", Message: "must be an image you uploaded"}
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"TechBlog/models"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+const (
+	author   uint = 1
+	stranger uint = 2
+)
+
+// validationCode returns the code of a ValidationError, or "" for other errors
+func validationCode(err error) string {
+	var validation *ValidationError
+	if errors.As(err, &validation) {
+		return validation.Code
+	}
+	return ""
+}
+
+func newTestPostService() (*PostService, *fakePosts) {
+	posts := newFakePosts(models.Post{Model: gorm.Model{ID: 1}, UserID: author, Title: "Draft", Body: "Body"})
+	files := newFakeMedia(
+		models.Media{Model: gorm.Model{ID: 1}, UserID: author, Width: 800, Height: 400},
+		models.Media{Model: gorm.Model{ID: 2}, UserID: author},
+		models.Media{Model: gorm.Model{ID: 3}, UserID: stranger, Width: 800, Height: 400},
+	)
+	return NewPostService(posts, files), posts
+}
+
+func TestPostOwnership(t *testing.T) {
+	ctx := context.Background()
+	service, posts := newTestPostService()
+	edit := PostInput{Title: "Edited", Body: "New body"}
+
+	if _, err := service.Update(ctx, 1, stranger, edit); !errors.Is(err, ErrPostNotFound) {
+		t.Fatalf("expected ErrPostNotFound when a stranger updates, got %v", err)
+	}
+	if err := service.Delete(ctx, 1, stranger); !errors.Is(err, ErrPostNotFound) {
+		t.Fatalf("expected ErrPostNotFound when a stranger deletes, got %v", err)
+	}
+	if post := posts.posts[1]; post.Title != "Draft" {
+		t.Fatalf("expected the post to be unchanged, got %+v", post)
+	}
+
+	updated, err := service.Update(ctx, 1, author, edit)
+	if err != nil || updated.Title != "Edited" || posts.posts[1].Body != "New body" {
+		t.Fatalf("expected the author to update the post, got %+v, %v", updated, err)
+	}
+	if err := service.Delete(ctx, 1, author); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Get(ctx, 1); !errors.Is(err, ErrPostNotFound) {
+		t.Fatalf("expected the post to be deleted, got %v", err)
+	}
+}
+
+func TestPostCover(t *testing.T) {
+	ctx := context.Background()
+	cover := func(id uint) *uint { return &id }
+
+	tests := []struct {
+		name  string
+		cover *uint
+		want  string
+	}{
+		{"own image", cover(1), ""},
+		{"removed", cover(0), ""},
+		{"not an image", cover(2), "invalid_cover"},
+		{"someone else's image", cover(3), "invalid_cover"},
+		{"missing", cover(99), "invalid_cover"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service, _ := newTestPostService()
+			_, err := service.Update(ctx, 1, author, PostInput{Title: "Draft", Body: "Body", CoverMediaID: test.cover})
+			if got := validationCode(err); got != test.want || got == "" && err != nil {
+				t.Errorf("expected %q, got %v", test.want, err)
+			}
+		})
+	}
+}
+
+func TestPostValidation(t *testing.T) {
+	ctx := context.Background()
+	service, posts := newTestPostService()
+
+	if _, err := service.Create(ctx, author, PostInput{Title: "  ", Body: "Body"}); validationCode(err) != "required" {
+		t.Errorf("expected a blank title to be required, got %v", err)
+	}
+	long := strings.Repeat("a", models.ExcerptLength+1)
+	if _, err := service.Create(ctx, author, PostInput{Title: "Title", Body: "Body", Excerpt: &long}); validationCode(err) != "too_long" {
+		t.Errorf("expected a long excerpt to be rejected, got %v", err)
+	}
+
+	post, err := service.Create(ctx, author, PostInput{Title: " Title ", Body: "Body"})
+	if err != nil || post.Title != "Title" || post.UserID != author || len(posts.posts) != 2 {
+		t.Fatalf("expected a trimmed post by the author, got %+v, %v", post, err)
+	}
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"TechBlog/models"
+	"TechBlog/repository"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxSeriesPosts caps the number of posts in a series
+const maxSeriesPosts = 100
+
+// SeriesInput holds the editable fields of a series
+type SeriesInput struct {
+	Title       string
+	Description string
+}
+
+// validate trims the fields and checks that the title is not blank
+func (in SeriesInput) validate() (SeriesInput, error) {
+	title, err := required("title", in.Title)
+	if err != nil {
+		return in, err
+	}
+	return SeriesInput{Title: title, Description: strings.TrimSpace(in.Description)}, nil
+}
+
+// SeriesService manages series of posts. Only the author of a series may
+// change it, and a series only holds posts written by its author.
+type SeriesService struct {
+	series repository.SeriesRepository
+	posts  repository.PostRepository
+}
+
+// NewSeriesService returns a SeriesService storing series in series and
+// checking their members in posts
+func NewSeriesService(series repository.SeriesRepository, posts repository.PostRepository) *SeriesService {
+	return &SeriesService{series: series, posts: posts}
+}
+
+// Get returns a series with its posts in order
+func (s *SeriesService) Get(ctx context.Context, id uint) (models.Series, error) {
+	series, err := s.series.Get(ctx, id)
+	return series, notFound(err, ErrSeriesNotFound)
+}
+
+// GetByPost returns the series that contains a post, with its posts in order
+func (s *SeriesService) GetByPost(ctx context.Context, postID uint) (models.Series, error) {
+	series, err := s.series.GetByPost(ctx, postID)
+	return series, notFound(err, ErrSeriesNotFound)
+}
+
+// Create starts a series by userID made of postIDs, in that order
+func (s *SeriesService) Create(ctx context.Context, userID uint, in SeriesInput, postIDs []uint) (models.Series, error) {
+	in, err := in.validate()
+	if err != nil {
+		return models.Series{}, err
+	}
+	if err := s.checkPosts(ctx, userID, 0, postIDs); err != nil {
+		return models.Series{}, err
+	}
+
+	series := models.Series{UserID: userID, Title: in.Title, Description: in.Description}
+	for i, postID := range postIDs {
+		series.Posts = append(series.Posts, models.SeriesPost{Position: i + 1, PostID: postID})
+	}
+	if err := s.series.Create(ctx, &series); err != nil {
+		return models.Series{}, err
+	}
+	return s.Get(ctx, series.ID)
+}
+
+// Update replaces the title and description of a series by userID
+func (s *SeriesService) Update(ctx context.Context, id, userID uint, in SeriesInput) (models.Series, error) {
+	in, err := in.validate()
+	if err != nil {
+		return models.Series{}, err
+	}
+	series, err := s.series.GetOwned(ctx, id, userID)
+	if err != nil {
+		return models.Series{}, notFound(err, ErrSeriesNotFound)
+	}
+
+	series.Title = in.Title
+	series.Description = in.Description
+	if err := s.series.Update(ctx, &series); err != nil {
+		return models.Series{}, err
+	}
+	return s.Get(ctx, series.ID)
+}
+
+// SetPosts replaces the posts of a series by userID with postIDs, in that
+// order. It adds, removes and reorders posts at once.
+func (s *SeriesService) SetPosts(ctx context.Context, id, userID uint, postIDs []uint) (models.Series, error) {
+	series, err := s.series.GetOwned(ctx, id, userID)
+	if err != nil {
+		return models.Series{}, notFound(err, ErrSeriesNotFound)
+	}
+	if err := s.checkPosts(ctx, userID, series.ID, postIDs); err != nil {
+		return models.Series{}, err
+	}
+	if err := s.series.SetPosts(ctx, series.ID, postIDs); err != nil {
+		return models.Series{}, err
+	}
+	return s.Get(ctx, series.ID)
+}
+
+// Delete removes a series by userID, keeping its posts
+func (s *SeriesService) Delete(ctx context.Context, id, userID uint) error {
+	series, err := s.series.GetOwned(ctx, id, userID)
+	if err != nil {
+		return notFound(err, ErrSeriesNotFound)
+	}
+	return s.series.Delete(ctx, &series)
+}
+
+// checkPosts fails unless postIDs are distinct posts written by userID that
+// belong to no series other than seriesID
+func (s *SeriesService) checkPosts(ctx context.Context, userID, seriesID uint, postIDs []uint) error {
+	if len(postIDs) > maxSeriesPosts {
+		return &ValidationError{Field: "post_ids", Code: "too_many_posts",
+			Message: fmt.Sprintf("must list at most %d posts", maxSeriesPosts)}
+	}
+
+	seen := make(map[uint]bool, len(postIDs))
+	for _, postID := range postIDs {
+		if seen[postID] {
+			return &ValidationError{Field: "post_ids", Code: "duplicate_post",
+				Message: fmt.Sprintf("lists post %d more than once", postID)}
+		}
+		seen[postID] = true
+
+		if _, err := s.posts.GetOwned(ctx, postID, userID); errors.Is(err, repository.ErrNotFound) {
+			return &ValidationError{Field: "post_ids", Code: "invalid_post",
+				Message: fmt.Sprintf("must only list posts you wrote, but post %d is not one", postID)}
+		} else if err != nil {
+			return err
+		}
+	}
+
+	seriesOf, err := s.series.SeriesOf(ctx, postIDs)
+	if err != nil {
+		return err
+	}
+	for _, postID := range postIDs {
+		if other, ok := seriesOf[postID]; ok && other != seriesID {
+			return &ValidationError{Field: "post_ids", Code: "post_in_other_series",
+				Message: fmt.Sprintf("lists post %d, which is part of another series", postID)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"TechBlog/models"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// newTestSeriesService returns a service with posts 1 to 4 by the author,
+// post 5 by a stranger, and series 1 holding posts 1 and 2 and series 2
+// holding post 3, both by the author
+func newTestSeriesService() (*SeriesService, *fakeSeries) {
+	var posts []models.Post
+	for id := uint(1); id <= 5; id++ {
+		userID := author
+		if id == 5 {
+			userID = stranger
+		}
+		posts = append(posts, models.Post{Model: gorm.Model{ID: id}, UserID: userID, Title: "Post", Body: "Body"})
+	}
+	series := newFakeSeries(
+		models.Series{ID: 1, UserID: author, Title: "Tutorial", Posts: []models.SeriesPost{
+			{SeriesID: 1, Position: 1, PostID: 1},
+			{SeriesID: 1, Position: 2, PostID: 2},
+		}},
+		models.Series{ID: 2, UserID: author, Title: "Other", Posts: []models.SeriesPost{
+			{SeriesID: 2, Position: 1, PostID: 3},
+		}},
+	)
+	return NewSeriesService(series, newFakePosts(posts...)), series
+}
+
+// postIDs returns the IDs of the posts of a series, checking that their
+// positions count up from 1
+func postIDs(t *testing.T, series models.Series) []uint {
+	t.Helper()
+	ids := []uint{}
+	for i, member := range series.Posts {
+		if member.Position != i+1 {
+			t.Fatalf("expected position %d, got %+v", i+1, member)
+		}
+		ids = append(ids, member.PostID)
+	}
+	return ids
+}
+
+func TestSeriesSetPosts(t *testing.T) {
+	ctx := context.Background()
+
+	for _, ids := range [][]uint{{2, 1}, {4, 1}, {1, 2, 4}, {}} {
+		service, _ := newTestSeriesService()
+		series, err := service.SetPosts(ctx, 1, author, ids)
+		if err != nil {
+			t.Fatalf("SetPosts(%v): %v", ids, err)
+		}
+		if got := postIDs(t, series); !reflect.DeepEqual(got, ids) {
+			t.Errorf("SetPosts(%v) left posts %v", ids, got)
+		}
+	}
+}
+
+func TestSeriesSetPostsRejected(t *testing.T) {
+	ctx := context.Background()
+	tooMany := make([]uint, maxSeriesPosts+1)
+	for i := range tooMany {
+		tooMany[i] = uint(i + 1)
+	}
+
+	tests := []struct {
+		name string
+		ids  []uint
+		want string
+	}{
+		{"too many", tooMany, "too_many_posts"},
+		{"duplicate", []uint{1, 4, 1}, "duplicate_post"},
+		{"someone else's post", []uint{1, 5}, "invalid_post"},
+		{"missing post", []uint{1, 99}, "invalid_post"},
+		{"post in another series", []uint{1, 3}, "post_in_other_series"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service, series := newTestSeriesService()
+			if _, err := service.SetPosts(ctx, 1, author, test.ids); validationCode(err) != test.want {
+				t.Fatalf("expected %q, got %v", test.want, err)
+			}
+			if got := postIDs(t, series.series[1]); !reflect.DeepEqual(got, []uint{1, 2}) {
+				t.Errorf("expected the posts to be unchanged, got %v", got)
+			}
+		})
+	}
+}
+
+func TestSeriesOwnership(t *testing.T) {
+	ctx := context.Background()
+	service, series := newTestSeriesService()
+
+	if _, err := service.SetPosts(ctx, 1, stranger, []uint{5}); !errors.Is(err, ErrSeriesNotFound) {
+		t.Errorf("expected ErrSeriesNotFound when a stranger sets the posts, got %v", err)
+	}
+	if _, err := service.Update(ctx, 1, stranger, SeriesInput{Title: "Mine"}); !errors.Is(err, ErrSeriesNotFound) {
+		t.Errorf("expected ErrSeriesNotFound when a stranger updates, got %v", err)
+	}
+	if err := service.Delete(ctx, 1, stranger); !errors.Is(err, ErrSeriesNotFound) {
+		t.Errorf("expected ErrSeriesNotFound when a stranger deletes, got %v", err)
+	}
+	if stored := series.series[1]; stored.Title != "Tutorial" || len(stored.Posts) != 2 {
+		t.Fatalf("expected the series to be unchanged, got %+v", stored)
+	}
+
+	if _, err := service.Create(ctx, stranger, SeriesInput{Title: "Stolen"}, []uint{1}); validationCode(err) != "invalid_post" {
+		t.Errorf("expected a stranger not to collect the author's posts, got %v", err)
+	}
+	created, err := service.Create(ctx, stranger, SeriesInput{Title: " Mine "}, []uint{5})
+	if err != nil || created.Title != "Mine" || created.UserID != stranger {
+		t.Fatalf("expected a series by the stranger, got %+v, %v", created, err)
+	}
+}
@@ -0,0 +1,51 @@
+// Package services implements the blog's business rules on top of the
+// repositories. Services know nothing about HTTP; handlers translate their
+// errors into API responses.
+package services
+
+import (
+	"TechBlog/repository"
+	"errors"
+	"strings"
+)
+
+var (
+	ErrPostNotFound    = errors.New("post not found")
+	ErrCommentNotFound = errors.New("comment not found")
+	ErrUserNotFound    = errors.New("user not found")
+	ErrMediaNotFound   = errors.New("media not found")
+	ErrSeriesNotFound  = errors.New("series not found")
+	// ErrMediaNotTransformable is returned for variants of files that are
+	// not images in a format that can be decoded
+	ErrMediaNotTransformable = errors.New("media cannot be transformed")
+	ErrInvalidCredentials    = errors.New("invalid username or password")
+)
+
+// ValidationError reports input that breaks a business rule. Code names the
+// rule, Message describes it.
+type ValidationError struct {
+	Field   string
+	Code    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// notFound replaces repository.ErrNotFound with the service's own error
+func notFound(err, replacement error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return replacement
+	}
+	return err
+}
+
+// required trims value and fails when nothing is left
+func required(field, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", &ValidationError{Field: field, Code: "required", Message: "is required"}
+	}
+	return value, nil
+}
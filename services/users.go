@@ -0,0 +1,73 @@
+package services
+
+import (
+	"TechBlog/metrics"
+	"TechBlog/models"
+	"TechBlog/repository"
+	"context"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UserService manages user accounts and logins
+type UserService struct {
+	users repository.UserRepository
+}
+
+// NewUserService returns a UserService storing users in users
+func NewUserService(users repository.UserRepository) *UserService {
+	return &UserService{users: users}
+}
+
+// List returns every user
+func (s *UserService) List(ctx context.Context) ([]models.User, error) {
+	return s.users.List(ctx)
+}
+
+// Get returns a single user
+func (s *UserService) Get(ctx context.Context, id uint) (models.User, error) {
+	user, err := s.users.Get(ctx, id)
+	return user, notFound(err, ErrUserNotFound)
+}
+
+// Register creates a user account. The password is hashed when the user is stored.
+func (s *UserService) Register(ctx context.Context, username, email, password string) (models.User, error) {
+	username, err := required("username", username)
+	if err != nil {
+		return models.User{}, err
+	}
+	if strings.EqualFold(username, models.DeletedUsername) {
+		return models.User{}, &ValidationError{Field: "username", Code: "reserved", Message: "is reserved"}
+	}
+
+	user := models.User{
+		Username: username,
+		Email:    email,
+	}
+	user.SetPassword(password)
+	if err := s.users.Create(ctx, &user); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// Authenticate returns the user with the given username if password matches.
+// Failed attempts are counted in metrics.LoginsFailed.
+func (s *UserService) Authenticate(ctx context.Context, username, password string) (models.User, error) {
+	user, err := s.users.GetByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			metrics.LoginsFailed.Inc()
+			return models.User{}, ErrUserNotFound
+		}
+		return models.User{}, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		metrics.LoginsFailed.Inc()
+		return models.User{}, ErrInvalidCredentials
+	}
+	return user, nil
+}
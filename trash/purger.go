@@ -0,0 +1,40 @@
+package trash
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// purgeInterval is how often the purger looks for expired trash
+const purgeInterval = time.Hour
+
+// StartPurger permanently deletes trashed items once they are older than
+// retention. It runs until ctx is cancelled and closes the returned
+// channel once the current run has finished.
+func StartPurger(ctx context.Context, db *gorm.DB, retention time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(purgeInterval)
+		defer ticker.Stop()
+
+		for {
+			purged, err := Purge(db, time.Now().Add(-retention))
+			if err != nil {
+				slog.Error("trash purge failed", "error", err)
+			} else if purged > 0 {
+				slog.Info("trash purge finished", "removed", purged)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
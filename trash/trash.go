@@ -0,0 +1,124 @@
+package trash
+
+import (
+	"TechBlog/models"
+	"TechBlog/utils"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrParentDeleted is returned when restoring an item whose post is still in
+// the trash or whose author is deleted
+var ErrParentDeleted = errors.New("parent item is still in the trash")
+
+// batch is a deletion: an item and everything deleted along with it share
+// its ID, by which they are restored together. Matching on the deletion
+// time instead would depend on the precision the database stores it with.
+type batch struct {
+	id        string
+	deletedAt time.Time
+}
+
+// newBatch starts a deletion
+func newBatch() (batch, error) {
+	token, err := utils.NewToken()
+	if err != nil {
+		return batch{}, err
+	}
+	return batch{id: token[:32], deletedAt: time.Now()}, nil
+}
+
+// DeletePost moves a post and its comments to the trash
+func DeletePost(db *gorm.DB, post *models.Post) error {
+	b, err := newBatch()
+	if err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := softDelete(tx, &models.Post{}, b, "id = ?", post.ID); err != nil {
+			return err
+		}
+		return softDelete(tx, &models.Comment{}, b, "post_id = ?", post.ID)
+	})
+}
+
+// RestorePost restores a trashed post together with the comments that were
+// deleted with it. Comments deleted on their own stay in the trash.
+func RestorePost(db *gorm.DB, post *models.Post) error {
+	var owner models.User
+	if err := db.Unscoped().First(&owner, post.UserID).Error; err != nil {
+		return err
+	}
+	if owner.DeletedAt.Valid {
+		return ErrParentDeleted
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := restore(tx, &models.Post{}, "id = ?", post.ID); err != nil {
+			return err
+		}
+		return restoreBatch(tx, &models.Comment{}, post.TrashBatch, "post_id = ?", post.ID)
+	})
+}
+
+// RestoreComment restores a trashed comment as long as its post is not in the trash
+func RestoreComment(db *gorm.DB, comment *models.Comment) error {
+	var count int64
+	if err := db.Model(&models.Post{}).Where("id = ?", comment.PostID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrParentDeleted
+	}
+	if err := db.Model(&models.User{}).Where("id = ?", comment.UserID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrParentDeleted
+	}
+
+	return restore(db, &models.Comment{}, "id = ?", comment.ID)
+}
+
+// Purge permanently deletes everything that has been in the trash since before
+// cutoff. Users are no longer moved to the trash, as accounts are deleted
+// through account deletions, but users deleted before that are purged too.
+func Purge(db *gorm.DB, cutoff time.Time) (int64, error) {
+	var purged int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&models.Comment{}, &models.Post{}, &models.User{}} {
+			result := tx.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Delete(model)
+			if result.Error != nil {
+				return result.Error
+			}
+			purged += result.RowsAffected
+		}
+		return nil
+	})
+	return purged, err
+}
+
+// softDelete moves the live rows matching the condition to the trash as part of b
+func softDelete(tx *gorm.DB, model interface{}, b batch, query string, args ...interface{}) error {
+	return tx.Model(model).Where(query, args...).
+		Updates(map[string]interface{}{"deleted_at": b.deletedAt, "trash_batch": b.id}).Error
+}
+
+// restore takes the trashed rows matching the condition out of the trash
+func restore(tx *gorm.DB, model interface{}, query string, args ...interface{}) error {
+	return tx.Unscoped().Model(model).
+		Where(query, args...).
+		Where("deleted_at IS NOT NULL").
+		Updates(map[string]interface{}{"deleted_at": nil, "trash_batch": ""}).Error
+}
+
+// restoreBatch restores the rows matching the condition that were deleted in
+// batch batchID. Rows deleted on their own have no batch and stay in the trash.
+func restoreBatch(tx *gorm.DB, model interface{}, batchID string, query string, args ...interface{}) error {
+	if batchID == "" {
+		return nil
+	}
+	return restore(tx, model, "("+query+") AND trash_batch = ?", append(args, batchID)...)
+}
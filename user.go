@@ -0,0 +1,177 @@
+package main
+
+import (
+	"TechBlog/config"
+	"TechBlog/models"
+	"TechBlog/utils"
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"net/mail"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const userUsage = `Usage:
+  techblog user create [flags] -username <name> -email <address> [-admin]
+  techblog user reset-password [flags] <username>
+
+The password is read from the terminal, or from the first line of standard
+input when it is not a terminal. reset-password -generate prints a random
+password instead.
+
+Flags:
+`
+
+// runUser implements the user commands
+func runUser(args []string) {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, userUsage)
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "create":
+		runUserCreate(args[1:])
+	case "reset-password":
+		runUserResetPassword(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown user command %q\n\n%s", args[0], userUsage)
+		os.Exit(2)
+	}
+}
+
+// runUserCreate creates a user with a verified email address
+func runUserCreate(args []string) {
+	fs := flag.NewFlagSet("user create", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), userUsage)
+		fs.PrintDefaults()
+	}
+	username := fs.String("username", "", "username of the new user")
+	email := fs.String("email", "", "email address of the new user")
+	admin := fs.Bool("admin", false, "make the user an administrator")
+
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *username == "" || *email == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if _, err := mail.ParseAddress(*email); err != nil {
+		log.Fatalf("Invalid email address %q", *email)
+	}
+	if strings.EqualFold(*username, models.DeletedUsername) {
+		log.Fatalf("The username %q is reserved", *username)
+	}
+
+	password, err := readPassword("Password for " + *username + ": ")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dbConfig := mustConnect(cfg)
+	defer dbConfig.Close()
+	requireMigrated(dbConfig)
+
+	user := models.User{
+		Username:      *username,
+		Email:         *email,
+		IsAdmin:       *admin,
+		EmailVerified: true,
+	}
+	user.SetPassword(password)
+	if err := dbConfig.DB.Create(&user).Error; err != nil {
+		log.Fatalf("Failed to create user: %v", err)
+	}
+
+	role := "user"
+	if user.IsAdmin {
+		role = "administrator"
+	}
+	fmt.Printf("created %s %s with ID %d\n", role, user.Username, user.ID)
+}
+
+// runUserResetPassword sets a new password for an existing user
+func runUserResetPassword(args []string) {
+	fs := flag.NewFlagSet("user reset-password", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), userUsage)
+		fs.PrintDefaults()
+	}
+	generate := fs.Bool("generate", false, "generate a random password and print it")
+
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	username := fs.Arg(0)
+
+	dbConfig := mustConnect(cfg)
+	defer dbConfig.Close()
+	requireMigrated(dbConfig)
+
+	var user models.User
+	if err := dbConfig.DB.Where("username = ?", username).First(&user).Error; err != nil {
+		log.Fatalf("User %s not found", username)
+	}
+
+	var password string
+	if *generate {
+		token, err := utils.NewToken()
+		if err != nil {
+			log.Fatal(err)
+		}
+		password = token[:20]
+	} else if password, err = readPassword("New password for " + username + ": "); err != nil {
+		log.Fatal(err)
+	}
+
+	// BeforeSave hashes the new password; the new session version signs
+	// the user out everywhere
+	user.SetPassword(password)
+	if err := dbConfig.DB.Model(&user).Select("password", "session_version").Updates(&user).Error; err != nil {
+		log.Fatalf("Failed to reset password: %v", err)
+	}
+
+	if *generate {
+		fmt.Printf("new password for %s: %s\n", username, password)
+	} else {
+		fmt.Printf("password for %s updated\n", username)
+	}
+}
+
+// readPassword reads a password without echoing it when standard input is a
+// terminal, and applies the same length rules as the API
+func readPassword(prompt string) (string, error) {
+	var password string
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, prompt)
+		input, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		password = string(input)
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("failed to read password from standard input: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	if len(password) < 8 || len(password) > 72 {
+		return "", fmt.Errorf("password must be between 8 and 72 characters")
+	}
+	return password, nil
+}
@@ -1,19 +1,48 @@
 package utils
 
 import (
+	"TechBlog/apierror"
+	"TechBlog/connect"
+	"TechBlog/models"
+	"errors"
+
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
-	"net/http"
+	"gorm.io/gorm"
 )
 
-func WithAuth() gin.HandlerFunc {
+// SessionVersionKey is the session key holding the user's session version
+// at the time the session started
+const SessionVersionKey = "session_version"
+
+func WithAuth(dbConfig *connect.DBConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		session := sessions.Default(c)
-		userID := session.Get("user_id")
+		userID, ok := session.Get("user_id").(uint)
 
 		// If user_id is not in session, redirect to login
-		if userID == nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized. Please log in."})
+		if !ok {
+			c.Error(apierror.Unauthorized())
+			c.Abort()
+			return
+		}
+
+		// Sessions started before the password last changed are signed out.
+		// Sessions from before versions were recorded count as version 0.
+		version, _ := session.Get(SessionVersionKey).(uint)
+		var user models.User
+		err := dbConfig.DB.WithContext(c.Request.Context()).Select("id", "session_version").First(&user, userID).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apierror.Internal("Failed to load session user", err))
+			c.Abort()
+			return
+		}
+		if err != nil || user.SessionVersion != version {
+			session.Clear()
+			if err := session.Save(); err != nil {
+				Logger(c).Warn("failed to clear session", "error", err)
+			}
+			c.Error(apierror.Unauthorized())
 			c.Abort()
 			return
 		}
@@ -22,3 +51,31 @@ func WithAuth() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// SessionUserID returns the ID of the logged-in user stored in the session
+func SessionUserID(c *gin.Context) (uint, bool) {
+	session := sessions.Default(c)
+	userID, ok := session.Get("user_id").(uint)
+	return userID, ok
+}
+
+// WithAdmin only lets logged-in administrators through. It must run after WithAuth.
+func WithAdmin(dbConfig *connect.DBConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := SessionUserID(c)
+		if !ok {
+			c.Error(apierror.Unauthorized())
+			c.Abort()
+			return
+		}
+
+		var user models.User
+		if err := dbConfig.DB.WithContext(c.Request.Context()).Select("id", "is_admin").First(&user, userID).Error; err != nil || !user.IsAdmin {
+			c.Error(apierror.Forbidden("Administrator access required."))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
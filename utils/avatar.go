@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// AvatarSizes lists the edge lengths, in pixels, of the stored avatar renditions
+var AvatarSizes = []int{64, 128, 256}
+
+// SaveAvatar crops img to a square and writes one JPEG per avatar size below uploadDir
+func SaveAvatar(uploadDir string, img image.Image, key string) error {
+	dir := filepath.Join(uploadDir, "avatars")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	square := CropSquare(img)
+	for _, size := range AvatarSizes {
+		path := filepath.Join(dir, avatarFile(key, size))
+		if err := writeJPEG(path, Resize(square, size, size)); err != nil {
+			RemoveAvatar(uploadDir, key)
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveAvatar deletes every stored rendition of an avatar below uploadDir
+func RemoveAvatar(uploadDir string, key string) {
+	if key == "" {
+		return
+	}
+	for _, size := range AvatarSizes {
+		os.Remove(filepath.Join(uploadDir, "avatars", avatarFile(key, size)))
+	}
+}
+
+// AvatarURLs maps each avatar size to the URL it is served from
+func AvatarURLs(key string) map[string]string {
+	if key == "" {
+		return nil
+	}
+	urls := make(map[string]string, len(AvatarSizes))
+	for _, size := range AvatarSizes {
+		urls[strconv.Itoa(size)] = "/uploads/avatars/" + avatarFile(key, size)
+	}
+	return urls
+}
+
+func avatarFile(key string, size int) string {
+	return fmt.Sprintf("%s-%d.jpg", key, size)
+}
+
+func writeJPEG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: 85}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
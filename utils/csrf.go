@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"TechBlog/apierror"
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// CSRFHeader carries the CSRF token of the session on state-changing requests
+const CSRFHeader = "X-CSRF-Token"
+
+// csrfSessionKey stores the CSRF token in the session
+const csrfSessionKey = "csrf_token"
+
+// CSRFToken returns the CSRF token of the session, creating and saving one
+// when the session has none yet
+func CSRFToken(c *gin.Context) (string, error) {
+	session := sessions.Default(c)
+	if token, ok := session.Get(csrfSessionKey).(string); ok && token != "" {
+		return token, nil
+	}
+
+	token, err := NewToken()
+	if err != nil {
+		return "", err
+	}
+	session.Set(csrfSessionKey, token)
+	if err := session.Save(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ResetCSRFToken removes the CSRF token from the session, so that a new one
+// is issued. It is called when the session changes hands, such as on login.
+// The caller saves the session.
+func ResetCSRFToken(session sessions.Session) {
+	session.Delete(csrfSessionKey)
+}
+
+// WithCSRF rejects state-changing requests that do not repeat the CSRF token
+// of their session in the X-CSRF-Token header. Other sites can make a browser
+// send the session cookie but cannot read the token. Every request is
+// checked, whatever other headers it carries, since the session is what
+// authenticates it.
+func WithCSRF() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		expected, _ := sessions.Default(c).Get(csrfSessionKey).(string)
+		sent := c.GetHeader(CSRFHeader)
+		if expected == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(expected)) != 1 {
+			c.Error(apierror.New(http.StatusForbidden, "invalid_csrf_token",
+				"The CSRF token is missing or invalid. Fetch one from /api/v1/csrf and send it in the "+CSRFHeader+" header."))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"TechBlog/apierror"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProblemContentType is the media type of error responses (RFC 7807)
+const ProblemContentType = "application/problem+json"
+
+// WithErrors writes the last error a handler attached with c.Error as an
+// RFC 7807 problem document, unless the handler already wrote a response.
+// Errors that are not *apierror.Error are reported as internal errors.
+// Server errors are logged together with their cause.
+func WithErrors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		var apiErr *apierror.Error
+		if !errors.As(err, &apiErr) {
+			apiErr = apierror.Internal("An unexpected error occurred.", err)
+		}
+
+		if apiErr.Status >= http.StatusInternalServerError {
+			Logger(c).Error(apiErr.Message, "code", apiErr.Code, "error", apiErr.Err)
+		}
+		WriteProblem(c, apiErr)
+	}
+}
+
+// WriteProblem writes err as an RFC 7807 problem document. The message is
+// also sent as "message", which clients written before problem documents read.
+func WriteProblem(c *gin.Context, err *apierror.Error) {
+	body := gin.H{
+		"type":     "/problems/" + err.Code,
+		"title":    http.StatusText(err.Status),
+		"status":   err.Status,
+		"detail":   err.Message,
+		"instance": c.Request.URL.Path,
+		"code":     err.Code,
+		"message":  err.Message,
+	}
+	if id := RequestID(c); id != "" {
+		body["request_id"] = id
+	}
+	if len(err.Fields) > 0 {
+		body["errors"] = err.Fields
+	}
+	for key, value := range err.Extra {
+		body[key] = value
+	}
+
+	// c.JSON keeps a Content-Type that is already set
+	c.Header("Content-Type", ProblemContentType)
+	c.JSON(err.Status, body)
+}
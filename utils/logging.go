@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"TechBlog/apierror"
+	"TechBlog/logging"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the ID that ties together the log lines of one request
+const RequestIDHeader = "X-Request-ID"
+
+// Keys of the values the middlewares store in the gin context
+const (
+	requestIDKey = "request_id"
+	loggerKey    = "logger"
+)
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// WithRequestID gives every request an ID. A well-formed X-Request-ID header
+// from a proxy is kept, otherwise a new ID is generated. The ID is echoed in
+// the X-Request-ID response header.
+func WithRequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			token, err := NewToken()
+			if err != nil {
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			id = token[:32]
+		}
+
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// RequestID returns the ID of the current request
+func RequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// WithAccessLog attaches a logger carrying the request ID and trace ID to the
+// request, for handlers to fetch with Logger, and logs every request once it
+// is done. It must run after WithRequestID and WithTracing.
+func WithAccessLog(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		requestLogger := logger.With("request_id", RequestID(c))
+		if span := trace.SpanContextFromContext(c.Request.Context()); span.IsValid() {
+			requestLogger = requestLogger.With("trace_id", span.TraceID().String())
+		}
+		c.Set(loggerKey, requestLogger)
+		c.Request = c.Request.WithContext(logging.NewContext(c.Request.Context(), requestLogger))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+			slog.String("user_agent", c.Request.UserAgent()),
+		}
+		if _, ok := c.Get(sessions.DefaultKey); ok {
+			if userID, ok := SessionUserID(c); ok {
+				attrs = append(attrs, slog.Uint64("user_id", uint64(userID)))
+			}
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", c.Errors.String()))
+		}
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		requestLogger.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// WithRecovery turns panics in handlers into 500 problem responses and logs
+// them with the stack trace. It runs outside WithErrors, which a panic skips,
+// so it writes the problem itself.
+func WithRecovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		Logger(c).Error("panic recovered", "panic", recovered, "stack", string(debug.Stack()))
+		apiErr := apierror.Internal("An unexpected error occurred.", fmt.Errorf("panic: %v", recovered))
+		c.Error(apiErr)
+		if !c.Writer.Written() {
+			WriteProblem(c, apiErr)
+		}
+		c.Abort()
+	})
+}
+
+// Logger returns the logger of the current request, which includes its request ID
+func Logger(c *gin.Context) *slog.Logger {
+	if logger, ok := c.Get(loggerKey); ok {
+		return logger.(*slog.Logger)
+	}
+	return logging.FromContext(c.Request.Context())
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"TechBlog/apierror"
+	"TechBlog/metrics"
+	"TechBlog/ratelimit"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WithRateLimit throttles requests with the policy of their route. The keys
+// of routes are a method and a route path relative to prefix, such as
+// "POST /login", and the values are policy names; other routes use the
+// policy named fallback. Responses carry the RateLimit-* headers, and
+// rejected requests get a 429 response with Retry-After. When the store
+// fails the request is let through, so that an outage of the store does not
+// take the API down with it.
+func WithRateLimit(limiter *ratelimit.Limiter, prefix string, routes map[string]string, fallback string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name, ok := routes[c.Request.Method+" "+strings.TrimPrefix(c.FullPath(), prefix)]
+		if !ok {
+			name = fallback
+		}
+		policy, ok := limiter.Policy(name)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		result, err := limiter.Allow(c.Request.Context(), policy, rateLimitIdentity(c))
+		if err != nil {
+			Logger(c).Warn("rate limit store failed, letting the request through", "policy", policy.Name, "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Policy", strconv.Itoa(policy.Limit)+";w="+strconv.Itoa(int(policy.Period.Seconds())))
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		if !result.Allowed {
+			retryAfter := ceilSeconds(result.RetryAfter)
+			metrics.RateLimited.WithLabelValues(policy.Name).Inc()
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.Error(apierror.TooManyRequests("Too many requests. Please try again later.").With("retry_after", retryAfter))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// rateLimitIdentity names whom a request is counted against: the logged-in
+// user, otherwise the client IP. Headers that nothing verifies, such as an
+// Authorization header, must not pick the bucket, or a client could get a
+// fresh one with every request.
+func rateLimitIdentity(c *gin.Context) string {
+	if userID, ok := SessionUserID(c); ok {
+		return "user:" + strconv.FormatUint(uint64(userID), 10)
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// ceilSeconds rounds a duration up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
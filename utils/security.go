@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NoncePlaceholder stands for the nonce of the request in a
+// Content-Security-Policy, as in "script-src 'nonce-{nonce}'"
+const NoncePlaceholder = "{nonce}"
+
+// cspNonceKey stores the nonce of the request in the gin context
+const cspNonceKey = "csp_nonce"
+
+// cspReportGroup names the reporting endpoint in Reporting-Endpoints and report-to
+const cspReportGroup = "csp-endpoint"
+
+// SecurityHeaders are the security headers of the responses of a route group
+type SecurityHeaders struct {
+	// HSTSMaxAge is in seconds; 0 leaves Strict-Transport-Security out
+	HSTSMaxAge            int
+	HSTSIncludeSubdomains bool
+	// CSP is the Content-Security-Policy, without frame-ancestors and
+	// reporting, which are added from the fields below
+	CSP        string
+	ReportOnly bool
+	// ReportURI receives the violation reports, if set
+	ReportURI string
+	// FrameAncestors is the source list of the frame-ancestors directive.
+	// It also sets X-Frame-Options for browsers that ignore the directive.
+	FrameAncestors    string
+	ReferrerPolicy    string
+	PermissionsPolicy string
+}
+
+// WithCSP returns a copy of h with a different Content-Security-Policy, for
+// route groups that serve something other than the frontend
+func (h SecurityHeaders) WithCSP(csp string) SecurityHeaders {
+	h.CSP = csp
+	return h
+}
+
+// WithSecurityHeaders sets the security headers of h on every response. A
+// group can replace the headers set for the whole router by using it again
+// with different headers. When the policy contains NoncePlaceholder, every
+// request gets a fresh nonce, which handlers read with CSPNonce.
+func WithSecurityHeaders(h SecurityHeaders) gin.HandlerFunc {
+	var hsts string
+	if h.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(h.HSTSMaxAge)
+		if h.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+	}
+
+	var frameOptions string
+	switch h.FrameAncestors {
+	case "'none'":
+		frameOptions = "DENY"
+	case "'self'":
+		frameOptions = "SAMEORIGIN"
+	}
+
+	var directives []string
+	if h.CSP != "" {
+		directives = append(directives, strings.TrimSuffix(strings.TrimSpace(h.CSP), ";"))
+	}
+	if h.FrameAncestors != "" {
+		directives = append(directives, "frame-ancestors "+h.FrameAncestors)
+	}
+	if len(directives) > 0 && h.ReportURI != "" {
+		directives = append(directives, "report-uri "+h.ReportURI, "report-to "+cspReportGroup)
+	}
+	csp := strings.Join(directives, "; ")
+	cspHeader, otherCSPHeader := "Content-Security-Policy", "Content-Security-Policy-Report-Only"
+	if h.ReportOnly {
+		cspHeader, otherCSPHeader = otherCSPHeader, cspHeader
+	}
+	withNonce := strings.Contains(csp, NoncePlaceholder)
+
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		setOrDelete(header, "Strict-Transport-Security", hsts)
+		setOrDelete(header, "X-Frame-Options", frameOptions)
+		header.Set("X-Content-Type-Options", "nosniff")
+		setOrDelete(header, "Referrer-Policy", h.ReferrerPolicy)
+		setOrDelete(header, "Permissions-Policy", h.PermissionsPolicy)
+
+		header.Del(otherCSPHeader)
+		header.Del("Reporting-Endpoints")
+		if csp == "" {
+			header.Del(cspHeader)
+			c.Next()
+			return
+		}
+		policy := csp
+		if withNonce {
+			nonce, err := newNonce()
+			if err != nil {
+				// Without a nonce, inline content stays blocked rather than allowed
+				Logger(c).Error("failed to create a CSP nonce", "error", err)
+			}
+			c.Set(cspNonceKey, nonce)
+			policy = strings.ReplaceAll(policy, NoncePlaceholder, nonce)
+		}
+		header.Set(cspHeader, policy)
+		if h.ReportURI != "" {
+			header.Set("Reporting-Endpoints", cspReportGroup+`="`+h.ReportURI+`"`)
+		}
+		c.Next()
+	}
+}
+
+// CSPNonce returns the nonce of the request's Content-Security-Policy, for
+// the nonce attribute of inline scripts and styles
+func CSPNonce(c *gin.Context) string {
+	return c.GetString(cspNonceKey)
+}
+
+// setOrDelete sets the header key to value, or deletes it when value is empty
+func setOrDelete(header http.Header, key, value string) {
+	if value == "" {
+		header.Del(key)
+		return
+	}
+	header.Set(key, value)
+}
+
+// newNonce returns 128 random bits encoded for a CSP nonce
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
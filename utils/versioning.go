@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WithDeprecation marks every response of a deprecated route group. It sets
+// the Deprecation header (RFC 9745) to deprecatedAt, the Sunset header
+// (RFC 8594) to sunset unless it is zero, and links to the same path under
+// successor, which replaces prefix.
+func WithDeprecation(prefix, successor string, deprecatedAt, sunset time.Time) gin.HandlerFunc {
+	deprecation := "@" + strconv.FormatInt(deprecatedAt.Unix(), 10)
+	return func(c *gin.Context) {
+		c.Header("Deprecation", deprecation)
+		if !sunset.IsZero() {
+			c.Header("Sunset", sunset.UTC().Format(http.TimeFormat))
+		}
+		link := successor + strings.TrimPrefix(c.Request.URL.Path, prefix)
+		c.Header("Link", "<"+link+`>; rel="successor-version"`)
+		c.Next()
+	}
+}
+
+// WithOverrides replaces the handlers of individual routes in a group. The
+// keys of overrides are a method and a route path relative to prefix, such
+// as "GET /posts/:postId". It must be the last middleware of the group so that
+// an override runs after the group's checks, such as WithAuth.
+func WithOverrides(prefix string, overrides map[string]gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		handler, ok := overrides[c.Request.Method+" "+strings.TrimPrefix(c.FullPath(), prefix)]
+		if !ok {
+			c.Next()
+			return
+		}
+		handler(c)
+		c.Abort()
+	}
+}